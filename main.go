@@ -12,9 +12,13 @@ import (
 	"time"
 
 	"botex/pkg/auth"
+	"botex/pkg/cli"
 	"botex/pkg/commands"
 	"botex/pkg/config"
+	"botex/pkg/groupinfo"
+	"botex/pkg/identity"
 	"botex/pkg/logger"
+	"botex/pkg/ratelimit"
 	"botex/pkg/timing"
 	_ "github.com/mattn/go-sqlite3"
 	"github.com/mdp/qrterminal/v3"
@@ -27,6 +31,8 @@ const (
 	maxIdleConns    = 5
 	connMaxLifetime = 3600 // seconds
 	connMaxIdleTime = 1800 // seconds
+
+	auditPruneInterval = 1 * time.Hour
 )
 
 var ErrQRLoginTimeout = errors.New("QR login timed out")
@@ -39,6 +45,9 @@ type Bot struct {
 	loggerFactory  *logger.Factory
 	shutdownSignal chan os.Signal
 	authService    auth.Auth
+	timeTracker    *timing.Tracker
+	expiryCleaner  *ratelimit.AutoCleaner
+	auditCleaner   *ratelimit.AutoCleaner
 	db             *sql.DB
 }
 
@@ -65,27 +74,101 @@ func NewBot(cfg *config.Config, loggerFactory *logger.Factory) (*Bot, error) {
 
 	ctx := context.Background()
 
-	appLogger.Info("Initializing database schema", nil)
+	appLogger.Info("Migrating database schema", nil)
 
-	err = auth.InitSchema(ctx, database)
+	applied, err := auth.Migrate(ctx, database)
 	if err != nil {
-		return nil, fmt.Errorf("failed to initialize database schema: %w", err)
+		return nil, fmt.Errorf("failed to migrate database schema: %w", err)
 	}
 
-	appLogger.Info("Database schema initialization completed", nil)
+	appLogger.Info("Database schema migration completed", map[string]interface{}{
+		"applied": applied,
+	})
+
+	if applied > 0 {
+		warnMissingAdminCommands(ctx, database, appLogger)
+	}
+
+	if cfg.Auth.ValidateSchema {
+		err = auth.ValidateSchema(ctx, database)
+		if err != nil {
+			return nil, fmt.Errorf("database schema validation failed: %w", err)
+		}
+	}
 
 	client, err := setupWhatsAppClient(cfg, loggerFactory)
 	if err != nil {
 		return nil, fmt.Errorf("failed to setup WhatsApp client: %w", err)
 	}
 
+	timeTracker := timing.NewTrackerFromConfig(cfg, loggerFactory.GetLogger("timing"))
+
 	authService := auth.New(database)
 
-	commandHandler, err := setupCommands(client, cfg, loggerFactory, authService)
+	if cfg.Auth.CacheTTL > 0 {
+		err = authService.EnableCache(cfg.Auth.CacheTTL, cfg.Auth.CacheSize, timeTracker)
+		if err != nil {
+			return nil, fmt.Errorf("failed to enable auth cache: %w", err)
+		}
+	}
+
+	// the same person can reach the bot as a phone-number JID, with a device
+	// suffix or as a LID; map them all to one stored identity.
+	authService.SetIdentityResolver(identity.NewResolver(client.Store.LIDs, loggerFactory.GetLogger("identity")))
+
+	canonicalized, err := authService.CanonicalizeUsers(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to canonicalize stored user IDs: %w", err)
+	}
+
+	if canonicalized > 0 {
+		appLogger.Info("Stored user IDs canonicalized", map[string]interface{}{
+			"count": canonicalized,
+		})
+	}
+
+	err = authService.SetRequestPolicy(cfg.Auth.RequestTTL, cfg.Auth.RequestCooldown)
+	if err != nil {
+		return nil, fmt.Errorf("invalid access request settings: %w", err)
+	}
+
+	err = authService.SetUnrankedAuditCooldown(cfg.Auth.DenialCooldown)
+	if err != nil {
+		return nil, fmt.Errorf("invalid BOTEX_AUTH_DENIAL_COOLDOWN: %w", err)
+	}
+
+	if cfg.Auth.AutoRegister {
+		err = authService.EnableAutoRegister(ctx, cfg.Auth.DefaultUserRank)
+		if err != nil {
+			return nil, fmt.Errorf("invalid BOTEX_AUTH_DEFAULT_RANK %q: %w", cfg.Auth.DefaultUserRank, err)
+		}
+
+		appLogger.Info("Auto-registration enabled", map[string]interface{}{
+			"rank": cfg.Auth.DefaultUserRank,
+		})
+	}
+
+	err = setupOwners(ctx, cfg, authService, appLogger)
+	if err != nil {
+		return nil, err
+	}
+
+	if cfg.Auth.EnableWhatsAppAdmin {
+		groupCache := groupinfo.NewCache(client, cfg.Auth.GroupInfoTTL, loggerFactory.GetLogger("group-info"))
+		authService.SetGroupAdminChecker(groupCache)
+		client.AddEventHandler(groupCache.HandleEvent)
+
+		appLogger.Info("WhatsApp group admins act as admins in their groups", nil)
+	}
+
+	commandHandler, err := setupCommands(client, cfg, loggerFactory, authService, timeTracker, newRateLimitStore(cfg, database))
 	if err != nil {
 		return nil, fmt.Errorf("failed to setup commands: %w", err)
 	}
 
+	expiryCleaner := setupExpirySweeper(cfg, client, authService, loggerFactory)
+	auditCleaner := setupAuditPruner(cfg, authService, loggerFactory)
+
 	successfulInit = true
 
 	return &Bot{
@@ -96,10 +179,68 @@ func NewBot(cfg *config.Config, loggerFactory *logger.Factory) (*Bot, error) {
 		loggerFactory:  loggerFactory,
 		shutdownSignal: make(chan os.Signal, 1),
 		authService:    authService,
+		timeTracker:    timeTracker,
+		expiryCleaner:  expiryCleaner,
+		auditCleaner:   auditCleaner,
 		db:             database,
 	}, nil
 }
 
+// setupExpirySweeper periodically settles time-limited grants and DMs users
+// ahead of expiry, reusing the rate limiter's cleaner for scheduling.
+func setupExpirySweeper(
+	cfg *config.Config,
+	client *whatsmeow.Client,
+	authService *auth.Service,
+	loggerFactory *logger.Factory,
+) *ratelimit.AutoCleaner {
+	sweepLogger := loggerFactory.GetLogger("expiry-sweeper")
+
+	sweeper := auth.NewExpirySweeper(authService, cfg.Auth.ExpiryNotice,
+		commands.NewExpiryNotice(client, loggerFactory),
+		func(err error) {
+			sweepLogger.Error("Failed to process expiring grants", map[string]interface{}{
+				"error": err.Error(),
+			})
+		})
+
+	cleaner := ratelimit.NewAutoCleaner(cfg.Auth.ExpiryCheckInterval)
+	cleaner.Register(sweeper)
+
+	return cleaner
+}
+
+// setupAuditPruner periodically deletes audit events older than the retention
+// period. It returns nil when events are kept forever.
+func setupAuditPruner(cfg *config.Config, authService *auth.Service, loggerFactory *logger.Factory) *ratelimit.AutoCleaner {
+	if cfg.Auth.AuditRetention == 0 {
+		return nil
+	}
+
+	pruneLogger := loggerFactory.GetLogger("audit-pruner")
+
+	pruner := auth.NewAuditPruner(authService, cfg.Auth.AuditRetention, func(err error) {
+		pruneLogger.Error("Failed to prune audit events", map[string]interface{}{
+			"error": err.Error(),
+		})
+	})
+
+	cleaner := ratelimit.NewAutoCleaner(auditPruneInterval)
+	cleaner.Register(pruner)
+
+	return cleaner
+}
+
+// newRateLimitStore keeps rate limit state in the database so quotas survive
+// restarts, unless persistence is turned off.
+func newRateLimitStore(cfg *config.Config, database *sql.DB) ratelimit.Store {
+	if !cfg.RateLimit.Persist {
+		return ratelimit.NewMemoryStore()
+	}
+
+	return ratelimit.NewSQLiteStore(database)
+}
+
 func setupDatabase(cfg *config.Config, appLogger *logger.Logger) (*sql.DB, error) {
 	dbPath := cfg.DBPath
 
@@ -134,6 +275,71 @@ func setupDatabase(cfg *config.Config, appLogger *logger.Logger) (*sql.DB, error
 	return database, nil
 }
 
+// warnMissingAdminCommands points out management commands the migrations
+// could not grant the admin rank because it had been edited.
+func warnMissingAdminCommands(ctx context.Context, database *sql.DB, appLogger *logger.Logger) {
+	missing, err := auth.MissingAdminCommands(ctx, database)
+	if err != nil {
+		appLogger.Error("Failed to check the admin rank's commands", map[string]interface{}{
+			"error": err.Error(),
+		})
+
+		return
+	}
+
+	if len(missing) > 0 {
+		appLogger.Warn("Admin rank was edited, so migrations did not grant it some commands; add them with !rank if wanted", map[string]interface{}{
+			"missing": auth.JoinCommands(missing),
+		})
+	}
+}
+
+// setupOwners registers BOTEX_OWNER_JIDS as owners and, while the bot has no
+// owner, prints a one-time code that makes whoever sends "!claim <code>" one.
+func setupOwners(ctx context.Context, cfg *config.Config, authService *auth.Service, appLogger *logger.Logger) error {
+	ownerIDs := make([]string, 0, len(cfg.Auth.OwnerJIDs))
+
+	for _, raw := range cfg.Auth.OwnerJIDs {
+		ownerID, err := cli.ParseUserID(raw)
+		if err != nil {
+			return fmt.Errorf("invalid BOTEX_OWNER_JIDS entry %q: %w", raw, err)
+		}
+
+		ownerIDs = append(ownerIDs, ownerID)
+	}
+
+	if len(ownerIDs) > 0 {
+		changed, err := authService.BootstrapOwners(ctx, ownerIDs)
+		if err != nil {
+			return fmt.Errorf("failed to bootstrap owners: %w", err)
+		}
+
+		appLogger.Info("Configured owners registered", map[string]interface{}{
+			"owners":  ownerIDs,
+			"changed": changed,
+		})
+	}
+
+	if !cfg.Auth.ClaimCode {
+		return nil
+	}
+
+	code, err := authService.NewClaimCode(ctx)
+	if errors.Is(err, auth.ErrOwnerExists) {
+		return nil
+	}
+
+	if err != nil {
+		return fmt.Errorf("failed to create claim code: %w", err)
+	}
+
+	appLogger.Warn("No owner registered yet. Send this to the bot in a private chat to become owner", map[string]interface{}{
+		"command": "!claim " + code,
+	})
+
+	return nil
+}
+
 func setupWhatsAppClient(cfg *config.Config, loggerFactory *logger.Factory) (*whatsmeow.Client, error) {
 	dbPath := cfg.DBPath
 
@@ -155,24 +361,77 @@ func setupWhatsAppClient(cfg *config.Config, loggerFactory *logger.Factory) (*wh
 	return client, nil
 }
 
-func setupCommands(client *whatsmeow.Client, cfg *config.Config, loggerFactory *logger.Factory, authService auth.Auth) (*commands.CommandHandler, error) {
+func setupCommands(
+	client *whatsmeow.Client,
+	cfg *config.Config,
+	loggerFactory *logger.Factory,
+	authService *auth.Service,
+	timeTracker *timing.Tracker,
+	rateStore ratelimit.Store,
+) (*commands.CommandHandler, error) {
 	registry := commands.NewCommandRegistry(loggerFactory)
 
-	timeLogger := loggerFactory.GetLogger("timing")
-	timeTracker := timing.NewTrackerFromConfig(cfg, timeLogger)
-
 	helpCmd := commands.NewHelpCommand(client, cfg, loggerFactory)
 	latexCmd := commands.NewLaTeXCommand(client, cfg, timeTracker, loggerFactory)
+	registerUserCmd := commands.NewRegisterUserCommand(client, cfg, authService, loggerFactory)
+	registerGroupCmd := commands.NewRegisterGroupCommand(client, cfg, authService, loggerFactory)
+	promoteCmd := commands.NewPromoteCommand(client, cfg, authService, loggerFactory)
+	demoteCmd := commands.NewDemoteCommand(client, cfg, authService, loggerFactory)
+	banCmd := commands.NewBanCommand(client, cfg, authService, loggerFactory)
+	unbanCmd := commands.NewUnbanCommand(client, cfg, authService, loggerFactory)
+	unregisterGroupCmd := commands.NewUnregisterGroupCommand(client, cfg, authService, loggerFactory)
+	rankCmd := commands.NewRankCommand(client, cfg, authService, loggerFactory)
+	groupCmd := commands.NewGroupCommand(client, cfg, authService, loggerFactory)
+	auditCmd := commands.NewAuditCommand(client, cfg, authService, loggerFactory)
+	claimCmd := commands.NewClaimCommand(client, cfg, authService, loggerFactory)
+	exportAuthCmd := commands.NewExportAuthCommand(client, cfg, authService, loggerFactory)
+	inviteCmd := commands.NewInviteCommand(client, cfg, authService, loggerFactory)
+	joinCmd := commands.NewJoinCommand(client, cfg, authService, loggerFactory)
+	requestAccessCmd := commands.NewRequestAccessCommand(client, cfg, authService, loggerFactory)
+	approveCmd := commands.NewApproveCommand(client, cfg, authService, loggerFactory)
+	denyCmd := commands.NewDenyCommand(client, cfg, authService, loggerFactory)
+	penaltiesCmd := commands.NewPenaltiesCommand(client, cfg, authService, loggerFactory)
+
+	// claiming, joining and asking are how people get registered in the first
+	// place.
+	authService.SetOpenCommands(claimCmd.Name(), joinCmd.Name(), requestAccessCmd.Name())
+
+	// group policies cannot open these to everyone.
+	authService.SetManagementCommands(
+		registerUserCmd.Name(), registerGroupCmd.Name(), promoteCmd.Name(), demoteCmd.Name(),
+		banCmd.Name(), unbanCmd.Name(), unregisterGroupCmd.Name(), rankCmd.Name(), groupCmd.Name(),
+		auditCmd.Name(), exportAuthCmd.Name(), inviteCmd.Name(), approveCmd.Name(), denyCmd.Name(),
+		penaltiesCmd.Name(),
+	)
 
 	registry.Register(helpCmd)
 	registry.Register(latexCmd)
-
-	commandHandler, err := commands.NewCommandHandler(client, cfg, registry, loggerFactory, authService)
+	registry.Register(registerUserCmd)
+	registry.Register(registerGroupCmd)
+	registry.Register(promoteCmd)
+	registry.Register(demoteCmd)
+	registry.Register(banCmd)
+	registry.Register(unbanCmd)
+	registry.Register(unregisterGroupCmd)
+	registry.Register(rankCmd)
+	registry.Register(groupCmd)
+	registry.Register(auditCmd)
+	registry.Register(claimCmd)
+	registry.Register(exportAuthCmd)
+	registry.Register(inviteCmd)
+	registry.Register(joinCmd)
+	registry.Register(requestAccessCmd)
+	registry.Register(approveCmd)
+	registry.Register(denyCmd)
+	registry.Register(penaltiesCmd)
+
+	commandHandler, err := commands.NewCommandHandler(client, cfg, registry, loggerFactory, authService, rateStore)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create command handler: %w", err)
 	}
 
 	helpCmd.SetHandler(commandHandler)
+	penaltiesCmd.SetPenalties(commandHandler.Penalties())
 
 	return commandHandler, nil
 }
@@ -195,6 +454,14 @@ func (b *Bot) Start() error {
 func (b *Bot) Shutdown() {
 	b.logger.Info("Initiating graceful shutdown", nil)
 
+	if b.expiryCleaner != nil {
+		b.expiryCleaner.Stop()
+	}
+
+	if b.auditCleaner != nil {
+		b.auditCleaner.Stop()
+	}
+
 	if b.commandHandler != nil {
 		b.commandHandler.Close()
 	}
@@ -203,6 +470,10 @@ func (b *Bot) Shutdown() {
 		b.client.Disconnect()
 	}
 
+	if b.timeTracker != nil {
+		b.timeTracker.LogCounters()
+	}
+
 	if b.db != nil {
 		err := b.db.Close()
 		if err != nil {
@@ -264,6 +535,15 @@ func main() {
 		log.Fatalf("Failed to load configuration: %v", err)
 	}
 
+	if cli.IsCommand(os.Args[1:]) {
+		err = cli.Run(context.Background(), cfg, os.Args[1:], os.Stdout)
+		if err != nil {
+			log.Fatalf("Error: %v", err)
+		}
+
+		return
+	}
+
 	loggerFactory, err := logger.NewFactory(cfg.Logging)
 	if err != nil {
 		log.Fatalf("Failed to create logger factory: %v", err)
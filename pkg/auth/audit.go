@@ -0,0 +1,74 @@
+package auth
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+const (
+	DefaultAuditLimit = 10
+	MaxAuditLimit     = 100
+)
+
+// ListAuditEvents returns the most recent audit events matching the filter,
+// newest first.
+func (s *Service) ListAuditEvents(ctx context.Context, filter AuditFilter) ([]*AuditEvent, error) {
+	filter.UserID = s.canonicalID(ctx, filter.UserID)
+
+	switch {
+	case filter.Limit <= 0:
+		filter.Limit = DefaultAuditLimit
+	case filter.Limit > MaxAuditLimit:
+		filter.Limit = MaxAuditLimit
+	}
+
+	return s.repo.ListAuditEvents(ctx, filter)
+}
+
+// PruneAuditEvents deletes the events recorded before the given time and
+// returns how many were deleted.
+func (s *Service) PruneAuditEvents(ctx context.Context, before time.Time) (int64, error) {
+	return s.repo.DeleteAuditEventsBefore(ctx, before)
+}
+
+// AuditPruner periodically deletes audit events older than the retention
+// period. Like ExpirySweeper, it implements ratelimit.Cleanable.
+type AuditPruner struct {
+	service   *Service
+	retention time.Duration
+	onError   func(error)
+}
+
+func NewAuditPruner(service *Service, retention time.Duration, onError func(error)) *AuditPruner {
+	return &AuditPruner{
+		service:   service,
+		retention: retention,
+		onError:   onError,
+	}
+}
+
+func (p *AuditPruner) Cleanup() {
+	_, err := p.service.PruneAuditEvents(context.Background(), time.Now().Add(-p.retention))
+	if err != nil && p.onError != nil {
+		p.onError(err)
+	}
+}
+
+// audit records an event. Events without a group are attributed to the group
+// the request came from (see WithGroup).
+func (s *Service) audit(ctx context.Context, event *AuditEvent) error {
+	event.ActorID = s.canonicalID(ctx, event.ActorID)
+	event.TargetID = s.canonicalID(ctx, event.TargetID)
+
+	if event.GroupID == "" {
+		event.GroupID = GroupFromContext(ctx)
+	}
+
+	err := s.repo.InsertAuditEvent(ctx, event)
+	if err != nil {
+		return fmt.Errorf("record %s: %w", event.Action, err)
+	}
+
+	return nil
+}
@@ -3,16 +3,47 @@ package auth
 import (
 	"context"
 	"database/sql"
+	"time"
 )
 
 type Auth interface {
 	CheckPermission(ctx context.Context, userID, groupID, command string) (*PermissionResult, error)
-	RegisterUser(ctx context.Context, userID, rank, registeredBy string) error
+	RegisterUser(ctx context.Context, userID, rank string, expiresAt *time.Time, registeredBy string) error
 	RegisterGroup(ctx context.Context, groupID, registeredBy string) error
+	SetUserRank(ctx context.Context, userID, rank string, expiresAt *time.Time, actorID string) error
+	PromoteUser(ctx context.Context, userID, actorID string) (*Rank, error)
+	DemoteUser(ctx context.Context, userID, actorID string) (*Rank, error)
+	DeactivateUser(ctx context.Context, userID, actorID string) error
+	ReactivateUser(ctx context.Context, userID, actorID string) error
+	BanUser(ctx context.Context, userID, reason string, expiresAt *time.Time, actorID string) error
+	Penalizable(ctx context.Context, userID string) bool
+	DeactivateGroup(ctx context.Context, groupID, actorID string) error
 	GetUser(ctx context.Context, userID string) (*User, error)
+	CanonicalID(ctx context.Context, userID string) string
 	GetRank(ctx context.Context, rankName string) (*Rank, error)
 	GetGroup(ctx context.Context, groupID string) (*Group, error)
 	ListRanks(ctx context.Context) ([]*Rank, error)
+	ListUsers(ctx context.Context) ([]*User, error)
+	ListGroups(ctx context.Context) ([]*Group, error)
+	CreateRank(ctx context.Context, rank *Rank, actorID string) error
+	UpdateRankCommands(ctx context.Context, rankName string, commands []string, actorID string) error
+	SetRankLevel(ctx context.Context, rankName string, level int, actorID string) error
+	DeleteRank(ctx context.Context, rankName, actorID string) error
+	SetGroupPolicy(ctx context.Context, policy *GroupPolicy, actorID string) error
+	DeleteGroupPolicy(ctx context.Context, groupID, command, actorID string) error
+	ListGroupPolicies(ctx context.Context, groupID string) ([]*GroupPolicy, error)
+	ListAuditEvents(ctx context.Context, filter AuditFilter) ([]*AuditEvent, error)
+	ClaimOwner(ctx context.Context, userID, code string) error
+	Export(ctx context.Context) (*Snapshot, error)
+	CreateInvite(ctx context.Context, rank string, maxUses int, expiresAt *time.Time, actorID string) (*Invite, error)
+	RedeemInvite(ctx context.Context, userID, code string) (*Invite, error)
+	ListInvites(ctx context.Context, actorID string) ([]*Invite, error)
+	RevokeInvite(ctx context.Context, code, actorID string) error
+	RequestAccess(ctx context.Context, userID, reason string) (*AccessRequest, error)
+	ApproveRequest(ctx context.Context, id int64, rank, actorID string) (*AccessRequest, error)
+	DenyRequest(ctx context.Context, id int64, actorID string) (*AccessRequest, error)
+	ListPendingRequests(ctx context.Context) ([]*AccessRequest, error)
+	RequestReviewers(ctx context.Context, rank string) ([]*User, error)
 }
 
 func New(db *sql.DB) *Service {
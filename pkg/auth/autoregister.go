@@ -0,0 +1,64 @@
+package auth
+
+import (
+	"context"
+	"errors"
+)
+
+// AutoRegisterActor is recorded in registered_by for users the bot
+// registered on its own, so audits can tell them apart.
+const AutoRegisterActor = SystemActor + ":auto"
+
+// EnableAutoRegister makes unregistered senders in registered groups join
+// with the given rank on their first command. The rank must exist.
+func (s *Service) EnableAutoRegister(ctx context.Context, rankName string) error {
+	err := ValidateRankName(rankName)
+	if err != nil {
+		return err
+	}
+
+	_, err = s.repo.GetRank(ctx, rankName)
+	if err != nil {
+		return err
+	}
+
+	s.autoRegisterRank = rankName
+
+	return nil
+}
+
+// autoRegister registers an unknown sender when auto-registration is on and
+// the request comes from a registered group. It returns ErrUserNotFound when
+// the user should stay unregistered, including users an admin deactivated.
+func (s *Service) autoRegister(ctx context.Context, userID, groupID string) (*Rank, error) {
+	if s.autoRegisterRank == "" || groupID == "" || userID == "" {
+		return nil, ErrUserNotFound
+	}
+
+	registered, err := s.groupRegistered(ctx, groupID)
+	if err != nil {
+		return nil, err
+	}
+
+	if !registered {
+		return nil, ErrUserNotFound
+	}
+
+	_, err = s.repo.GetUserRecord(ctx, userID)
+	if err == nil {
+		// the row exists, so someone removed this user on purpose or their
+		// registration expired.
+		return nil, ErrUserNotFound
+	}
+
+	if !errors.Is(err, ErrUserNotFound) {
+		return nil, err
+	}
+
+	err = s.RegisterUser(ctx, userID, s.autoRegisterRank, nil, AutoRegisterActor)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.lookupRank(ctx, s.autoRegisterRank)
+}
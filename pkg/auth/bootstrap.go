@@ -0,0 +1,131 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base32"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+const (
+	// ownerRank is the seeded top rank that bootstrap hands out.
+	ownerRank = "owner"
+
+	// claimCodeBytes gives 80 bits of entropy (16 base32 characters).
+	claimCodeBytes = 10
+)
+
+// ClaimActor is recorded as the actor for owners created with a claim code.
+const ClaimActor = SystemActor + ":claim"
+
+// BootstrapOwners makes sure every listed user is an active owner, registering
+// or promoting them as needed. It returns how many users were changed.
+func (s *Service) BootstrapOwners(ctx context.Context, userIDs []string) (int, error) {
+	changed := 0
+
+	for _, userID := range userIDs {
+		done, err := s.ensureOwner(ctx, s.canonicalID(ctx, userID), SystemActor)
+		if err != nil {
+			return changed, fmt.Errorf("bootstrap owner %s: %w", userID, err)
+		}
+
+		if done {
+			changed++
+		}
+	}
+
+	return changed, nil
+}
+
+// NewClaimCode generates the one-time code that lets the first person to
+// present it become owner (see ClaimOwner). It fails with ErrOwnerExists once
+// the bot has an owner, so a code is only ever issued to a fresh install.
+func (s *Service) NewClaimCode(ctx context.Context) (string, error) {
+	owners, err := s.repo.CountUsersWithRank(ctx, ownerRank)
+	if err != nil {
+		return "", err
+	}
+
+	if owners > 0 {
+		return "", ErrOwnerExists
+	}
+
+	code, err := randomCode(claimCodeBytes)
+	if err != nil {
+		return "", fmt.Errorf("generate claim code: %w", err)
+	}
+
+	s.claimMu.Lock()
+	s.claimCode = code
+	s.claimMu.Unlock()
+
+	return code, nil
+}
+
+// ClaimOwner makes the user an owner if code matches the current claim code.
+// The code is invalidated on success, so it works exactly once.
+func (s *Service) ClaimOwner(ctx context.Context, userID, code string) error {
+	userID = s.canonicalID(ctx, userID)
+
+	if userID == "" {
+		return ErrInvalidInput
+	}
+
+	s.claimMu.Lock()
+	defer s.claimMu.Unlock()
+
+	code = strings.ToUpper(strings.TrimSpace(code))
+	if s.claimCode == "" || subtle.ConstantTimeCompare([]byte(code), []byte(s.claimCode)) != 1 {
+		return ErrInvalidClaimCode
+	}
+
+	_, err := s.ensureOwner(ctx, userID, ClaimActor)
+	if err != nil {
+		return err
+	}
+
+	s.claimCode = ""
+
+	return nil
+}
+
+// randomCode returns size random bytes as unpadded base32, which is
+// case-insensitive and avoids easily confused characters like 0/O and 1/l.
+func randomCode(size int) (string, error) {
+	raw := make([]byte, size)
+
+	_, err := rand.Read(raw)
+	if err != nil {
+		return "", err
+	}
+
+	return base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString(raw), nil
+}
+
+// ensureOwner registers the user as owner, or moves an existing registration
+// to owner. It reports false when the user already was one.
+func (s *Service) ensureOwner(ctx context.Context, userID, actorID string) (bool, error) {
+	err := s.RegisterUser(ctx, userID, ownerRank, nil, actorID)
+	if !errors.Is(err, ErrUserExists) {
+		return err == nil, err
+	}
+
+	user, err := s.repo.GetUser(ctx, userID)
+	if err != nil {
+		return false, err
+	}
+
+	if user.Rank == ownerRank {
+		return false, nil
+	}
+
+	err = s.SetUserRank(ctx, userID, ownerRank, nil, actorID)
+	if err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
@@ -0,0 +1,305 @@
+package auth
+
+import (
+	"container/list"
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+// Metrics receives named counter increments; timing.Tracker implements it.
+type Metrics interface {
+	IncCounter(name string)
+}
+
+// cacheEntry holds a looked-up value, or records that it does not exist
+// (found == false), so unknown senders do not hit the database either.
+type cacheEntry[V any] struct {
+	key     string
+	value   V
+	found   bool
+	expires time.Time
+}
+
+// lruCache is a size-bounded, expiring cache. Writes that raced with an
+// invalidation are dropped using a generation counter, so a lookup that
+// started before a change can never reinsert the old value.
+type lruCache[V any] struct {
+	mu         sync.Mutex
+	ttl        time.Duration
+	capacity   int
+	items      map[string]*list.Element
+	order      *list.List
+	generation uint64
+}
+
+func newLRUCache[V any](ttl time.Duration, capacity int) *lruCache[V] {
+	return &lruCache[V]{
+		ttl:      ttl,
+		capacity: capacity,
+		items:    make(map[string]*list.Element),
+		order:    list.New(),
+	}
+}
+
+func (c *lruCache[V]) get(key string) (*cacheEntry[V], bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	elem, ok := c.items[key]
+	if !ok {
+		return nil, false
+	}
+
+	entry, _ := elem.Value.(*cacheEntry[V])
+	if time.Now().After(entry.expires) {
+		c.order.Remove(elem)
+		delete(c.items, key)
+
+		return nil, false
+	}
+
+	c.order.MoveToFront(elem)
+
+	return entry, true
+}
+
+// currentGeneration must be read before loading a value that will be put.
+func (c *lruCache[V]) currentGeneration() uint64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.generation
+}
+
+func (c *lruCache[V]) put(key string, value V, found bool, generation uint64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if generation != c.generation {
+		return
+	}
+
+	entry := &cacheEntry[V]{key: key, value: value, found: found, expires: time.Now().Add(c.ttl)}
+
+	if elem, ok := c.items[key]; ok {
+		elem.Value = entry
+		c.order.MoveToFront(elem)
+
+		return
+	}
+
+	c.items[key] = c.order.PushFront(entry)
+
+	if c.order.Len() > c.capacity {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+
+		evicted, _ := oldest.Value.(*cacheEntry[V])
+		delete(c.items, evicted.key)
+	}
+}
+
+func (c *lruCache[V]) remove(key string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.generation++
+
+	if elem, ok := c.items[key]; ok {
+		c.order.Remove(elem)
+		delete(c.items, key)
+	}
+}
+
+// authCache fronts the lookups CheckPermission makes on every message. All
+// methods are no-ops on a nil cache, which is what a Service without
+// EnableCache has.
+type authCache struct {
+	users    *lruCache[*User]
+	ranks    *lruCache[*Rank]
+	groups   *lruCache[struct{}]
+	bans     *lruCache[*Ban]
+	policies *lruCache[*GroupPolicy]
+	metrics  Metrics
+}
+
+// EnableCache keeps users, ranks, group registrations, bans and group
+// policies in memory for up to ttl, holding at most size entries of each.
+// Every method of the Service that changes one of them drops it from the
+// cache immediately. Hits and misses are counted on metrics when it is not
+// nil.
+func (s *Service) EnableCache(ttl time.Duration, size int, metrics Metrics) error {
+	if ttl <= 0 || size <= 0 {
+		return ErrInvalidInput
+	}
+
+	s.cache = &authCache{
+		users:    newLRUCache[*User](ttl, size),
+		ranks:    newLRUCache[*Rank](ttl, size),
+		groups:   newLRUCache[struct{}](ttl, size),
+		bans:     newLRUCache[*Ban](ttl, size),
+		policies: newLRUCache[*GroupPolicy](ttl, size),
+		metrics:  metrics,
+	}
+
+	return nil
+}
+
+// cachedLookup serves key from the cache or loads it, remembering notFound
+// results as well as found ones.
+func cachedLookup[V any](
+	c *authCache, cache *lruCache[V], kind, key string, notFound error, load func() (V, error),
+) (V, error) {
+	var zero V
+
+	if entry, ok := cache.get(key); ok {
+		c.count(kind, "hits")
+
+		if !entry.found {
+			return zero, notFound
+		}
+
+		return entry.value, nil
+	}
+
+	c.count(kind, "misses")
+
+	generation := cache.currentGeneration()
+
+	value, err := load()
+
+	switch {
+	case err == nil:
+		cache.put(key, value, true, generation)
+	case errors.Is(err, notFound):
+		cache.put(key, zero, false, generation)
+	}
+
+	return value, err
+}
+
+func (c *authCache) count(kind, outcome string) {
+	if c.metrics != nil {
+		c.metrics.IncCounter("auth_cache_" + kind + "_" + outcome)
+	}
+}
+
+func (c *authCache) forgetUser(userID string) {
+	if c != nil {
+		c.users.remove(userID)
+	}
+}
+
+func (c *authCache) forgetRank(name string) {
+	if c != nil {
+		c.ranks.remove(name)
+	}
+}
+
+func (c *authCache) forgetGroup(groupID string) {
+	if c != nil {
+		c.groups.remove(groupID)
+	}
+}
+
+func (c *authCache) forgetBan(userID string) {
+	if c != nil {
+		c.bans.remove(userID)
+	}
+}
+
+func (c *authCache) forgetPolicy(groupID, command string) {
+	if c != nil {
+		c.policies.remove(policyKey(groupID, command))
+	}
+}
+
+// policyKey joins a group and a command; neither can contain a space.
+func policyKey(groupID, command string) string {
+	return groupID + " " + command
+}
+
+// lookupUser returns an active user, or ErrUserNotFound.
+func (s *Service) lookupUser(ctx context.Context, userID string) (*User, error) {
+	if s.cache == nil {
+		return s.repo.GetUser(ctx, userID)
+	}
+
+	return cachedLookup(s.cache, s.cache.users, "user", userID, ErrUserNotFound, func() (*User, error) {
+		return s.repo.GetUser(ctx, userID)
+	})
+}
+
+// lookupRank returns an active rank, or ErrRankNotFound.
+func (s *Service) lookupRank(ctx context.Context, name string) (*Rank, error) {
+	if s.cache == nil {
+		return s.repo.GetRank(ctx, name)
+	}
+
+	return cachedLookup(s.cache, s.cache.ranks, "rank", name, ErrRankNotFound, func() (*Rank, error) {
+		return s.repo.GetRank(ctx, name)
+	})
+}
+
+// groupRegistered reports whether a group is registered and active.
+func (s *Service) groupRegistered(ctx context.Context, groupID string) (bool, error) {
+	if s.cache == nil {
+		return s.repo.GroupExists(ctx, groupID)
+	}
+
+	_, err := cachedLookup(s.cache, s.cache.groups, "group", groupID, ErrGroupNotRegistered, func() (struct{}, error) {
+		exists, err := s.repo.GroupExists(ctx, groupID)
+		if err == nil && !exists {
+			err = ErrGroupNotRegistered
+		}
+
+		return struct{}{}, err
+	})
+
+	switch {
+	case err == nil:
+		return true, nil
+	case errors.Is(err, ErrGroupNotRegistered):
+		return false, nil
+	default:
+		return false, err
+	}
+}
+
+// lookupBan returns the user's ban if it is still in force at now, or
+// ErrNotBanned.
+func (s *Service) lookupBan(ctx context.Context, userID string, now time.Time) (*Ban, error) {
+	if s.cache == nil {
+		return s.repo.GetActiveBan(ctx, userID, now)
+	}
+
+	ban, err := cachedLookup(s.cache, s.cache.bans, "ban", userID, ErrNotBanned, func() (*Ban, error) {
+		return s.repo.GetActiveBan(ctx, userID, now)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// a cached ban may have run out since it was loaded.
+	if ban.ExpiresAt != nil && !ban.ExpiresAt.After(now) {
+		return nil, ErrNotBanned
+	}
+
+	return ban, nil
+}
+
+// lookupGroupPolicy returns a group's policy for a command, or
+// ErrPolicyNotFound.
+func (s *Service) lookupGroupPolicy(ctx context.Context, groupID, command string) (*GroupPolicy, error) {
+	if s.cache == nil {
+		return s.repo.GetGroupPolicy(ctx, groupID, command)
+	}
+
+	key := policyKey(groupID, command)
+
+	return cachedLookup(s.cache, s.cache.policies, "policy", key, ErrPolicyNotFound, func() (*GroupPolicy, error) {
+		return s.repo.GetGroupPolicy(ctx, groupID, command)
+	})
+}
@@ -0,0 +1,85 @@
+package auth
+
+import (
+	"strings"
+	"time"
+)
+
+// DenialCode says why CheckPermission refused a command. Callers should
+// switch on the code rather than on PermissionResult.Reason, which is meant
+// for people and may change; codes are stable and also end up in the audit
+// log. Handle unknown codes with a generic message, new ones may be added.
+type DenialCode string
+
+const (
+	DenialBanned             DenialCode = "banned"
+	DenialNotRegistered      DenialCode = "not_registered"
+	DenialExpired            DenialCode = "expired"
+	DenialGroupNotRegistered DenialCode = "group_not_registered"
+	DenialGroupPolicy        DenialCode = "group_policy"
+	DenialRankLacksCommand   DenialCode = "rank_lacks_command"
+)
+
+var denialReasons = map[DenialCode]string{
+	DenialBanned:             "User banned",
+	DenialNotRegistered:      "User not registered",
+	DenialExpired:            "Registration expired",
+	DenialGroupNotRegistered: "Group not registered",
+	DenialGroupPolicy:        "Denied by group policy",
+	DenialRankLacksCommand:   "Command not allowed for your rank",
+}
+
+// DenialDetails carries what is known about a denial besides its code. Only
+// the fields that apply to the code are set.
+type DenialDetails struct {
+	// BanReason and BannedUntil describe the ban (DenialBanned); BannedUntil
+	// is nil for permanent bans.
+	BanReason   string     `json:"banReason,omitempty"`
+	BannedUntil *time.Time `json:"bannedUntil,omitempty"`
+
+	// ExpiredAt is when the user's time-limited grant ran out (DenialExpired).
+	ExpiredAt *time.Time `json:"expiredAt,omitempty"`
+
+	// MinRank is the rank a group policy requires; empty when the policy
+	// disables the command outright (DenialGroupPolicy).
+	MinRank string `json:"minRank,omitempty"`
+}
+
+func denied(code DenialCode, details *DenialDetails) *PermissionResult {
+	return &PermissionResult{
+		Allowed: false,
+		Code:    code,
+		Reason:  denialReasons[code],
+		Details: details,
+	}
+}
+
+// auditReason is the denial as recorded in the audit log: the code, followed
+// by the details that matter for it.
+func (r *PermissionResult) auditReason() string {
+	reason := string(r.Code)
+	if r.Details == nil {
+		return reason
+	}
+
+	var notes []string
+
+	switch {
+	case r.Details.MinRank != "":
+		notes = append(notes, "min rank "+r.Details.MinRank)
+	case r.Details.ExpiredAt != nil:
+		notes = append(notes, "at "+r.Details.ExpiredAt.UTC().Format(time.DateTime))
+	case r.Details.BannedUntil != nil:
+		notes = append(notes, "until "+r.Details.BannedUntil.UTC().Format(time.DateTime))
+	}
+
+	if r.Details.BanReason != "" {
+		notes = append(notes, r.Details.BanReason)
+	}
+
+	if len(notes) == 0 {
+		return reason
+	}
+
+	return reason + " (" + strings.Join(notes, ", ") + ")"
+}
@@ -8,6 +8,22 @@ var (
 	ErrGroupNotRegistered = errors.New("group not registered")
 	ErrGroupExists        = errors.New("group already registered")
 	ErrRankNotFound       = errors.New("rank not found")
+	ErrRankExists         = errors.New("rank already exists")
+	ErrRankInUse          = errors.New("rank still assigned to users")
 	ErrInvalidInput       = errors.New("invalid input")
 	ErrPermissionDenied   = errors.New("permission denied")
+	ErrRankEscalation     = errors.New("rank at or above actor's own level")
+	ErrRankBoundary       = errors.New("no rank beyond the current one")
+	ErrNotBanned          = errors.New("user not banned")
+	ErrPolicyNotFound     = errors.New("group policy not found")
+	ErrPolicyManagement   = errors.New("management commands cannot be opened to everyone")
+	ErrOwnerExists        = errors.New("an owner is already registered")
+	ErrInvalidClaimCode   = errors.New("invalid or already used claim code")
+	ErrSchemaDrift        = errors.New("database schema does not match the expected schema")
+	ErrInviteNotFound     = errors.New("invite code not found")
+	ErrInviteInvalid      = errors.New("invite code expired, used up or revoked")
+	ErrRequestNotFound    = errors.New("access request not found")
+	ErrRequestClosed      = errors.New("access request already decided or expired")
+	ErrRequestPending     = errors.New("access request already pending")
+	ErrRequestTooSoon     = errors.New("access requested too recently")
 )
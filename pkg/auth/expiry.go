@@ -0,0 +1,174 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// ExpiryActor is recorded as the actor when a time-limited grant runs out.
+const ExpiryActor = SystemActor + ":expiry"
+
+// ExpiryNotifier tells users that their grant is about to run out.
+type ExpiryNotifier interface {
+	NotifyExpiring(ctx context.Context, user *User) error
+}
+
+// validateExpiry rejects expiry times that are already in the past.
+func validateExpiry(expiresAt *time.Time) error {
+	if expiresAt != nil && !expiresAt.After(time.Now()) {
+		return fmt.Errorf("%w: expiry %s is in the past", ErrInvalidInput, expiresAt.Format(time.DateTime))
+	}
+
+	return nil
+}
+
+func untilSuffix(expiresAt *time.Time) string {
+	if expiresAt == nil {
+		return ""
+	}
+
+	return " until " + expiresAt.UTC().Format(time.DateTime)
+}
+
+// ExpireGrants settles every grant that ran out by now: users with a previous
+// rank return to it permanently, everyone else is deactivated. It returns how
+// many users were changed.
+func (s *Service) ExpireGrants(ctx context.Context, now time.Time) (int, error) {
+	users, err := s.repo.ListExpiredUsers(ctx, now)
+	if err != nil {
+		return 0, err
+	}
+
+	for i, user := range users {
+		err = s.expireGrant(ctx, user)
+		if err != nil {
+			return i, fmt.Errorf("expire grant of %s: %w", user.ID, err)
+		}
+	}
+
+	return len(users), nil
+}
+
+func (s *Service) expireGrant(ctx context.Context, user *User) error {
+	restore, err := s.canRestore(ctx, user)
+	if err != nil {
+		return err
+	}
+
+	reason := "registration expired"
+
+	if restore {
+		reason = user.Rank + " -> " + user.PreviousRank
+		err = s.repo.SetUserGrant(ctx, user.ID, user.PreviousRank, "", nil)
+	} else {
+		err = s.repo.DeactivateUser(ctx, user.ID, ExpiryActor)
+	}
+
+	if err != nil {
+		return err
+	}
+
+	s.cache.forgetUser(user.ID)
+
+	return s.audit(ctx, &AuditEvent{
+		Action:   AuditGrantExpired,
+		ActorID:  ExpiryActor,
+		TargetID: user.ID,
+		Reason:   reason,
+	})
+}
+
+// canRestore reports whether the user has a previous rank to fall back to
+// that still exists.
+func (s *Service) canRestore(ctx context.Context, user *User) (bool, error) {
+	if user.PreviousRank == "" {
+		return false, nil
+	}
+
+	_, err := s.repo.GetRank(ctx, user.PreviousRank)
+
+	switch {
+	case err == nil:
+		return true, nil
+	case errors.Is(err, ErrRankNotFound):
+		return false, nil
+	default:
+		return false, err
+	}
+}
+
+// NotifyExpiringGrants hands every user whose grant runs out before the
+// given time to notifier, once per grant. Grants that already ran out are
+// left to ExpireGrants. A failed notice is retried on the next call.
+func (s *Service) NotifyExpiringGrants(ctx context.Context, before time.Time, notifier ExpiryNotifier) (int, error) {
+	users, err := s.repo.ListUsersExpiringBefore(ctx, before)
+	if err != nil {
+		return 0, err
+	}
+
+	now := time.Now()
+	sent := 0
+
+	for _, user := range users {
+		if user.Expired(now) {
+			continue
+		}
+
+		err = notifier.NotifyExpiring(ctx, user)
+		if err != nil {
+			return sent, fmt.Errorf("notify %s: %w", user.ID, err)
+		}
+
+		err = s.repo.MarkExpiryNotified(ctx, user.ID)
+		if err != nil {
+			return sent, err
+		}
+
+		sent++
+	}
+
+	return sent, nil
+}
+
+// ExpirySweeper periodically warns users about expiring grants and settles
+// expired ones. It implements ratelimit.Cleanable, so an AutoCleaner can
+// drive it.
+type ExpirySweeper struct {
+	service  *Service
+	notice   time.Duration
+	notifier ExpiryNotifier
+	onError  func(error)
+}
+
+// NewExpirySweeper warns users notice ahead of expiry through notifier (no
+// warnings when either is zero) and reports failures to onError.
+func NewExpirySweeper(service *Service, notice time.Duration, notifier ExpiryNotifier, onError func(error)) *ExpirySweeper {
+	return &ExpirySweeper{
+		service:  service,
+		notice:   notice,
+		notifier: notifier,
+		onError:  onError,
+	}
+}
+
+func (e *ExpirySweeper) Cleanup() {
+	err := e.Sweep(context.Background(), time.Now())
+	if err != nil && e.onError != nil {
+		e.onError(err)
+	}
+}
+
+// Sweep sends due notices and then expires grants as of now.
+func (e *ExpirySweeper) Sweep(ctx context.Context, now time.Time) error {
+	var notifyErr error
+
+	if e.notifier != nil && e.notice > 0 {
+		_, notifyErr = e.service.NotifyExpiringGrants(ctx, now.Add(e.notice), e.notifier)
+	}
+
+	_, err := e.service.ExpireGrants(ctx, now)
+
+	return errors.Join(notifyErr, err)
+}
@@ -0,0 +1,326 @@
+package auth
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// SnapshotVersion is the format version written by Export. Import rejects
+// snapshots with any other version.
+const SnapshotVersion = 1
+
+type SnapshotFormat string
+
+const (
+	FormatJSON SnapshotFormat = "json"
+	FormatYAML SnapshotFormat = "yaml"
+)
+
+// Snapshot is a portable copy of the permission setup: every active rank,
+// user and group.
+type Snapshot struct {
+	Version    int       `json:"version"    yaml:"version"`
+	ExportedAt time.Time `json:"exportedAt" yaml:"exportedAt"`
+	Ranks      []*Rank   `json:"ranks"      yaml:"ranks"`
+	Users      []*User   `json:"users"      yaml:"users"`
+	Groups     []*Group  `json:"groups"     yaml:"groups"`
+}
+
+type ImportAction string
+
+const (
+	ImportCreate ImportAction = "create"
+	ImportUpdate ImportAction = "update"
+)
+
+// ImportChange is one step Import takes (or would take, on a dry run).
+type ImportChange struct {
+	Kind   string       `json:"kind"`
+	ID     string       `json:"id"`
+	Action ImportAction `json:"action"`
+	Detail string       `json:"detail,omitempty"`
+
+	apply func(ctx context.Context) error
+}
+
+func (c *ImportChange) String() string {
+	text := fmt.Sprintf("%s %s %s", c.Action, c.Kind, c.ID)
+	if c.Detail != "" {
+		text += " (" + c.Detail + ")"
+	}
+
+	return text
+}
+
+// ParseSnapshotFormat maps a format name or file extension ("yml") to a
+// SnapshotFormat.
+func ParseSnapshotFormat(name string) (SnapshotFormat, error) {
+	switch strings.ToLower(strings.TrimPrefix(name, ".")) {
+	case "json":
+		return FormatJSON, nil
+	case "yaml", "yml":
+		return FormatYAML, nil
+	default:
+		return "", fmt.Errorf("%w: unknown snapshot format %q", ErrInvalidInput, name)
+	}
+}
+
+func MarshalSnapshot(snapshot *Snapshot, format SnapshotFormat) ([]byte, error) {
+	var (
+		data []byte
+		err  error
+	)
+
+	switch format {
+	case FormatJSON:
+		data, err = json.MarshalIndent(snapshot, "", "  ")
+	case FormatYAML:
+		data, err = yaml.Marshal(snapshot)
+	default:
+		return nil, fmt.Errorf("%w: unknown snapshot format %q", ErrInvalidInput, format)
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("encode snapshot: %w", err)
+	}
+
+	return data, nil
+}
+
+func UnmarshalSnapshot(data []byte, format SnapshotFormat) (*Snapshot, error) {
+	var (
+		snapshot Snapshot
+		err      error
+	)
+
+	switch format {
+	case FormatJSON:
+		err = json.Unmarshal(data, &snapshot)
+	case FormatYAML:
+		err = yaml.Unmarshal(data, &snapshot)
+	default:
+		return nil, fmt.Errorf("%w: unknown snapshot format %q", ErrInvalidInput, format)
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("%w: decode snapshot: %w", ErrInvalidInput, err)
+	}
+
+	if snapshot.Version != SnapshotVersion {
+		return nil, fmt.Errorf("%w: snapshot version %d, expected %d", ErrInvalidInput, snapshot.Version, SnapshotVersion)
+	}
+
+	return &snapshot, nil
+}
+
+// Export collects every active rank, user and group.
+func (s *Service) Export(ctx context.Context) (*Snapshot, error) {
+	ranks, err := s.repo.ListRanks(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	users, err := s.repo.ListUsers(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	groups, err := s.repo.ListGroups(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Snapshot{
+		Version:    SnapshotVersion,
+		ExportedAt: time.Now().UTC(),
+		Ranks:      ranks,
+		Users:      users,
+		Groups:     groups,
+	}, nil
+}
+
+// Import brings the database in line with a snapshot: missing ranks, users
+// and groups are created and ranks or users that differ are updated. Nothing
+// is ever removed, so importing the same snapshot twice changes nothing the
+// second time. Changes go through the regular Service methods as actorID,
+// which is recorded in registered_by and the audit log. With dryRun set it
+// only returns the changes it would make.
+func (s *Service) Import(ctx context.Context, snapshot *Snapshot, actorID string, dryRun bool) ([]*ImportChange, error) {
+	changes, err := s.planImport(ctx, snapshot, actorID)
+	if err != nil || dryRun {
+		return changes, err
+	}
+
+	for i, change := range changes {
+		err = change.apply(ctx)
+		if err != nil {
+			return changes[:i], fmt.Errorf("%s: %w", change, err)
+		}
+	}
+
+	return changes, nil
+}
+
+// planImport diffs the snapshot against the database. Ranks come first so
+// users can be given them.
+func (s *Service) planImport(ctx context.Context, snapshot *Snapshot, actorID string) ([]*ImportChange, error) {
+	rankChanges, err := s.planRanks(ctx, snapshot.Ranks, actorID)
+	if err != nil {
+		return nil, err
+	}
+
+	userChanges, err := s.planUsers(ctx, snapshot.Users, actorID)
+	if err != nil {
+		return nil, err
+	}
+
+	groupChanges, err := s.planGroups(ctx, snapshot.Groups, actorID)
+	if err != nil {
+		return nil, err
+	}
+
+	return slices.Concat(rankChanges, userChanges, groupChanges), nil
+}
+
+func (s *Service) planRanks(ctx context.Context, ranks []*Rank, actorID string) ([]*ImportChange, error) {
+	existing, err := s.repo.ListRanks(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var changes []*ImportChange
+
+	for _, rank := range ranks {
+		idx := slices.IndexFunc(existing, func(r *Rank) bool { return r.Name == rank.Name })
+		if idx < 0 {
+			changes = append(changes, &ImportChange{
+				Kind: "rank", ID: rank.Name, Action: ImportCreate,
+				Detail: fmt.Sprintf("level %d: %s", rank.Level, JoinCommands(rank.Commands)),
+				apply: func(ctx context.Context) error {
+					return s.CreateRank(ctx, rank, actorID)
+				},
+			})
+
+			continue
+		}
+
+		current := existing[idx]
+
+		if current.Level != rank.Level {
+			changes = append(changes, &ImportChange{
+				Kind: "rank", ID: rank.Name, Action: ImportUpdate,
+				Detail: fmt.Sprintf("level %d -> %d", current.Level, rank.Level),
+				apply: func(ctx context.Context) error {
+					return s.SetRankLevel(ctx, rank.Name, rank.Level, actorID)
+				},
+			})
+		}
+
+		if JoinCommands(current.Commands) != JoinCommands(rank.Commands) {
+			changes = append(changes, &ImportChange{
+				Kind: "rank", ID: rank.Name, Action: ImportUpdate,
+				Detail: "commands: " + JoinCommands(rank.Commands),
+				apply: func(ctx context.Context) error {
+					return s.UpdateRankCommands(ctx, rank.Name, rank.Commands, actorID)
+				},
+			})
+		}
+	}
+
+	return changes, nil
+}
+
+func (s *Service) planUsers(ctx context.Context, users []*User, actorID string) ([]*ImportChange, error) {
+	var changes []*ImportChange
+
+	now := time.Now()
+
+	for _, user := range users {
+		if user.Expired(now) {
+			// the grant ran out since the export: import what it fell back to.
+			if user.PreviousRank == "" {
+				continue
+			}
+
+			user = &User{ID: user.ID, Rank: user.PreviousRank}
+		}
+
+		current, err := s.repo.GetUser(ctx, user.ID)
+
+		switch {
+		case errors.Is(err, ErrUserNotFound):
+			changes = append(changes, &ImportChange{
+				Kind: "user", ID: user.ID, Action: ImportCreate, Detail: "rank " + user.Rank + untilSuffix(user.ExpiresAt),
+				apply: func(ctx context.Context) error {
+					return s.importUser(ctx, user, actorID)
+				},
+			})
+		case err != nil:
+			return nil, err
+		case current.Rank != user.Rank || !sameExpiry(current.ExpiresAt, user.ExpiresAt):
+			changes = append(changes, &ImportChange{
+				Kind: "user", ID: user.ID, Action: ImportUpdate,
+				Detail: current.Rank + " -> " + user.Rank + untilSuffix(user.ExpiresAt),
+				apply: func(ctx context.Context) error {
+					return s.SetUserRank(ctx, user.ID, user.Rank, user.ExpiresAt, actorID)
+				},
+			})
+		}
+	}
+
+	return changes, nil
+}
+
+// importUser registers a user; a temporary grant on top of a previous rank is
+// recreated as that rank plus the grant.
+func (s *Service) importUser(ctx context.Context, user *User, actorID string) error {
+	if user.ExpiresAt == nil || user.PreviousRank == "" {
+		return s.RegisterUser(ctx, user.ID, user.Rank, user.ExpiresAt, actorID)
+	}
+
+	err := s.RegisterUser(ctx, user.ID, user.PreviousRank, nil, actorID)
+	if err != nil {
+		return err
+	}
+
+	return s.SetUserRank(ctx, user.ID, user.Rank, user.ExpiresAt, actorID)
+}
+
+func sameExpiry(a, b *time.Time) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+
+	return a.Equal(*b)
+}
+
+func (s *Service) planGroups(ctx context.Context, groups []*Group, actorID string) ([]*ImportChange, error) {
+	var changes []*ImportChange
+
+	for _, group := range groups {
+		exists, err := s.repo.GroupExists(ctx, group.ID)
+		if err != nil {
+			return nil, err
+		}
+
+		if exists {
+			continue
+		}
+
+		changes = append(changes, &ImportChange{
+			Kind: "group", ID: group.ID, Action: ImportCreate,
+			apply: func(ctx context.Context) error {
+				return s.RegisterGroup(ctx, group.ID, actorID)
+			},
+		})
+	}
+
+	return changes, nil
+}
@@ -0,0 +1,79 @@
+package auth
+
+import (
+	"context"
+	"errors"
+)
+
+// whatsAppAdminRank is the rank WhatsApp group admins act with inside their
+// own (registered) group when a GroupAdminChecker is configured.
+const whatsAppAdminRank = "admin"
+
+// GroupAdminChecker reports whether a user administers a WhatsApp group. As
+// with IdentityResolver, the bot plugs in an implementation backed by group
+// metadata. Implementations report false when the metadata cannot be fetched,
+// so an outage never blocks registered users.
+type GroupAdminChecker interface {
+	IsGroupAdmin(ctx context.Context, groupID, userID string) bool
+}
+
+type groupContextKey struct{}
+
+// WithGroup records the group a request comes from, so audit events written
+// while handling it are attributed to the group.
+func WithGroup(ctx context.Context, groupID string) context.Context {
+	return context.WithValue(ctx, groupContextKey{}, groupID)
+}
+
+// GroupFromContext returns the group stored by WithGroup, or "".
+func GroupFromContext(ctx context.Context) string {
+	if groupID, ok := ctx.Value(groupContextKey{}).(string); ok {
+		return groupID
+	}
+
+	return ""
+}
+
+// SetGroupAdminChecker enables treating WhatsApp group admins as at least
+// the admin rank inside their own group. Passing nil disables it.
+func (s *Service) SetGroupAdminChecker(checker GroupAdminChecker) {
+	s.groupAdmins = checker
+}
+
+// effectiveRank raises rank (nil for unregistered users) to the admin rank
+// when the user administers the registered group the request comes from.
+func (s *Service) effectiveRank(ctx context.Context, rank *Rank, userID, groupID string) (*Rank, error) {
+	if s.groupAdmins == nil || groupID == "" {
+		return rank, nil
+	}
+
+	adminRank, err := s.lookupRank(ctx, whatsAppAdminRank)
+	if err != nil {
+		if errors.Is(err, ErrRankNotFound) {
+			return rank, nil
+		}
+
+		return nil, err
+	}
+
+	if rank != nil && rank.Level <= adminRank.Level {
+		return rank, nil
+	}
+
+	// anyone can create a WhatsApp group, so only groups an admin already
+	// registered confer anything.
+	registered, err := s.groupRegistered(ctx, groupID)
+	if err != nil {
+		return nil, err
+	}
+
+	if !registered {
+		return rank, nil
+	}
+
+	if !s.groupAdmins.IsGroupAdmin(ctx, groupID, userID) {
+		return rank, nil
+	}
+
+	return adminRank, nil
+}
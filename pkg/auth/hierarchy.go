@@ -0,0 +1,126 @@
+package auth
+
+import (
+	"context"
+	"strings"
+)
+
+// SystemActor is recorded as the actor for changes the bot makes on its own
+// (bootstrap, automatic registration). Actors named "system" or "system:<x>"
+// act above every rank.
+const SystemActor = "system"
+
+// RateLimitActor is recorded for the temporary bans the rate limiter hands
+// out to repeat offenders. Unlike other system actors it acts at owner level,
+// so it can never ban an owner.
+const RateLimitActor = SystemActor + ":ratelimit"
+
+const (
+	// ownerLevel is the level of the owner rank, the top of the hierarchy.
+	ownerLevel = 0
+
+	// systemLevel sits above the owner rank.
+	systemLevel = ownerLevel - 1
+)
+
+func IsSystemActor(actorID string) bool {
+	return actorID == SystemActor || strings.HasPrefix(actorID, SystemActor+":")
+}
+
+// actorLevel returns the rank level an actor operates with.
+// Lower level means more privilege.
+func (s *Service) actorLevel(ctx context.Context, actorID string) (int, error) {
+	rank, err := s.actorRank(ctx, actorID)
+	if err != nil {
+		return 0, err
+	}
+
+	return rank.Level, nil
+}
+
+// actorRank returns the rank an actor operates with. System actors get a
+// synthetic rank above owner with every command. WhatsApp group admin status
+// is deliberately not taken into account: it only applies to permission
+// checks in the admin's own group, never to bot-wide changes.
+func (s *Service) actorRank(ctx context.Context, actorID string) (*Rank, error) {
+	if actorID == RateLimitActor {
+		return &Rank{Name: RateLimitActor, Level: ownerLevel, Commands: []string{wildcard}}, nil
+	}
+
+	if IsSystemActor(actorID) {
+		return &Rank{Name: SystemActor, Level: systemLevel, Commands: []string{wildcard}}, nil
+	}
+
+	return s.userRank(ctx, s.canonicalID(ctx, actorID))
+}
+
+// Penalizable reports whether the rate limiter may strike and ban a user.
+// Owners are spared: a ban on them could lock out everyone able to lift it.
+func (s *Service) Penalizable(ctx context.Context, userID string) bool {
+	rank, err := s.userRank(ctx, s.canonicalID(ctx, userID))
+	if err != nil {
+		return true
+	}
+
+	return rank.Level > ownerLevel
+}
+
+// canGrant reports whether an actor may hand out a rank: only ranks strictly
+// below the actor's own level can be granted.
+func canGrant(actorLevel int, rank *Rank) error {
+	if rank.Level <= actorLevel {
+		return ErrRankEscalation
+	}
+
+	return nil
+}
+
+// canManage reports whether an actor may change or revoke a target user:
+// the target must currently hold a rank strictly below the actor's.
+func (s *Service) canManage(ctx context.Context, actorLevel int, target *User) error {
+	rank, err := s.repo.GetRank(ctx, target.Rank)
+	if err != nil {
+		return err
+	}
+
+	return canGrant(actorLevel, rank)
+}
+
+// adjacentRank finds the nearest rank above (step < 0) or below (step > 0)
+// the given level. Ranks come from ListRanks ordered by level.
+func adjacentRank(ranks []*Rank, level, step int) (*Rank, error) {
+	if step < 0 {
+		for i := len(ranks) - 1; i >= 0; i-- {
+			if ranks[i].Level < level {
+				return ranks[i], nil
+			}
+		}
+
+		return nil, ErrRankBoundary
+	}
+
+	for _, rank := range ranks {
+		if rank.Level > level {
+			return rank, nil
+		}
+	}
+
+	return nil, ErrRankBoundary
+}
+
+// canDelegate reports whether an actor may put commands on a rank: nobody can
+// hand out a command their own rank does not have ("*" and wildcards
+// included). Negations only take commands away, so anyone may add them.
+func canDelegate(actor *Rank, commands []string) error {
+	for _, cmd := range commands {
+		if strings.HasPrefix(cmd, negationPrefix) {
+			continue
+		}
+
+		if !actor.coversPattern(cmd) {
+			return ErrRankEscalation
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,140 @@
+package auth
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+// IdentityActor is recorded when stored user IDs are rewritten to their
+// canonical form.
+const IdentityActor = SystemActor + ":identity"
+
+const (
+	userServer       = "s.whatsapp.net"
+	legacyUserServer = "c.us"
+	lidServer        = "lid"
+)
+
+// IdentityResolver maps a WhatsApp LID ("<n>@lid", the identity groups in
+// LID addressing mode use) to the person's phone-number JID. The auth
+// package stays free of whatsmeow; the bot plugs in an implementation backed
+// by the WhatsApp store. It returns "" when the mapping is unknown.
+type IdentityResolver interface {
+	PhoneNumberForLID(ctx context.Context, lid string) string
+}
+
+// SetIdentityResolver enables mapping LIDs to phone-number JIDs. Passing nil
+// disables it; LIDs are then treated as identities of their own.
+func (s *Service) SetIdentityResolver(resolver IdentityResolver) {
+	s.identities = resolver
+}
+
+// CanonicalUserID returns the form user IDs are stored in: the device and
+// agent parts are dropped ("51999888777:12@s.whatsapp.net" becomes
+// "51999888777@s.whatsapp.net") and the legacy "c.us" server is replaced.
+// IDs without a server, like system actors, are returned unchanged.
+func CanonicalUserID(userID string) string {
+	userID = strings.TrimSpace(userID)
+
+	user, server, ok := cutLast(userID, "@")
+	if !ok {
+		return userID
+	}
+
+	server = strings.ToLower(server)
+	if server == legacyUserServer {
+		server = userServer
+	}
+
+	if server == userServer || server == lidServer {
+		user, _, _ = strings.Cut(user, ":")
+		user, _, _ = strings.Cut(user, ".")
+	}
+
+	return user + "@" + server
+}
+
+// CanonicalID returns the ID a user is stored under, for components that keep
+// their own state per person, like the rate limiter.
+func (s *Service) CanonicalID(ctx context.Context, userID string) string {
+	return s.canonicalID(ctx, userID)
+}
+
+// canonicalID is CanonicalUserID plus, when a resolver is set, mapping known
+// LIDs to phone-number JIDs, so a person has one identity however WhatsApp
+// addresses them.
+func (s *Service) canonicalID(ctx context.Context, userID string) string {
+	userID = CanonicalUserID(userID)
+
+	if s.identities == nil || !strings.HasSuffix(userID, "@"+lidServer) {
+		return userID
+	}
+
+	phone := s.identities.PhoneNumberForLID(ctx, userID)
+	if phone == "" {
+		return userID
+	}
+
+	return CanonicalUserID(phone)
+}
+
+// CanonicalizeUsers rewrites user IDs stored in another form (with a device
+// part, or as a LID the resolver knows) to their canonical ID, in users, bans
+// and access requests. When the canonical user or ban already exists, it is
+// kept and the duplicate dropped; the audit event names the dropped rank.
+// Audit history keeps the IDs it was written with. It returns how many IDs
+// were rewritten.
+func (s *Service) CanonicalizeUsers(ctx context.Context) (int, error) {
+	ids, err := s.repo.ListStoredUserIDs(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	changed := 0
+
+	for _, id := range ids {
+		canonical := s.canonicalID(ctx, id)
+		if canonical == id {
+			continue
+		}
+
+		dropped, renameErr := s.repo.RenameUserID(ctx, id, canonical)
+		if renameErr != nil {
+			return changed, fmt.Errorf("canonicalize %s: %w", id, renameErr)
+		}
+
+		s.cache.forgetUser(id)
+		s.cache.forgetUser(canonical)
+		s.cache.forgetBan(id)
+		s.cache.forgetBan(canonical)
+
+		reason := "was " + id
+		if dropped != "" {
+			reason += ", dropped duplicate with rank " + dropped
+		}
+
+		err = s.audit(ctx, &AuditEvent{
+			Action:   AuditIdentityCanonicalized,
+			ActorID:  IdentityActor,
+			TargetID: canonical,
+			Reason:   reason,
+		})
+		if err != nil {
+			return changed, err
+		}
+
+		changed++
+	}
+
+	return changed, nil
+}
+
+func cutLast(s, sep string) (string, string, bool) {
+	i := strings.LastIndex(s, sep)
+	if i < 0 {
+		return s, "", false
+	}
+
+	return s[:i], s[i+len(sep):], true
+}
@@ -0,0 +1,324 @@
+package auth
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+const (
+	// inviteCodeBytes gives 40 bits of entropy (8 base32 characters), short
+	// enough to type and, with uses and expiry bounded, too many to guess.
+	inviteCodeBytes = 5
+
+	// MaxInviteUses bounds how many people one invite can register.
+	MaxInviteUses = 1000
+
+	// inviteRefPrefix marks an invite reference as opposed to a code.
+	inviteRefPrefix = "#"
+	inviteRefBytes  = 3
+)
+
+// InviteActor is recorded as registered_by for users who joined with an
+// invite code. The audit log links them to the code and its creator.
+const InviteActor = SystemActor + ":invite"
+
+// CreateInvite mints a code that registers up to maxUses users with the
+// given rank until expiresAt (nil = until revoked or used up). The actor
+// must be able to grant the rank directly.
+func (s *Service) CreateInvite(
+	ctx context.Context, rankName string, maxUses int, expiresAt *time.Time, actorID string,
+) (*Invite, error) {
+	err := ValidateRankName(rankName)
+	if err != nil {
+		return nil, err
+	}
+
+	if maxUses <= 0 || maxUses > MaxInviteUses {
+		return nil, fmt.Errorf("%w: uses must be between 1 and %d", ErrInvalidInput, MaxInviteUses)
+	}
+
+	err = validateExpiry(expiresAt)
+	if err != nil {
+		return nil, err
+	}
+
+	rank, err := s.repo.GetRank(ctx, rankName)
+	if err != nil {
+		return nil, err
+	}
+
+	actorLevel, err := s.actorLevel(ctx, actorID)
+	if err != nil {
+		return nil, err
+	}
+
+	err = canGrant(actorLevel, rank)
+	if err != nil {
+		return nil, err
+	}
+
+	code, err := randomCode(inviteCodeBytes)
+	if err != nil {
+		return nil, fmt.Errorf("generate invite code: %w", err)
+	}
+
+	invite := &Invite{
+		Code:      code,
+		Rank:      rank.Name,
+		MaxUses:   maxUses,
+		ExpiresAt: expiresAt,
+		CreatedBy: actorID,
+		CreatedAt: time.Now(),
+	}
+
+	err = s.repo.CreateInvite(ctx, invite)
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.audit(ctx, &AuditEvent{
+		Action:   AuditInviteCreated,
+		ActorID:  actorID,
+		TargetID: InviteRef(code),
+		Reason:   fmt.Sprintf("rank %s, %d use(s)%s", rank.Name, maxUses, untilSuffix(expiresAt)),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return invite, nil
+}
+
+// RedeemInvite registers userID with the invite's rank. It fails with
+// ErrInviteNotFound for unknown codes, ErrInviteInvalid for expired, used up
+// or revoked ones, and ErrUserExists when the user is already registered (in
+// which case no use is taken).
+func (s *Service) RedeemInvite(ctx context.Context, userID, code string) (*Invite, error) {
+	userID = s.canonicalID(ctx, userID)
+
+	if userID == "" {
+		return nil, ErrInvalidInput
+	}
+
+	code = normalizeInviteCode(code)
+
+	invite, err := s.repo.GetInvite(ctx, code)
+	if err != nil {
+		return nil, err
+	}
+
+	exists, err := s.repo.UserExists(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	if exists {
+		return nil, ErrUserExists
+	}
+
+	err = s.checkInviteCreator(ctx, invite)
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.repo.UseInvite(ctx, code, time.Now())
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.RegisterUser(ctx, userID, invite.Rank, nil, InviteActor)
+	if err != nil {
+		releaseErr := s.repo.ReleaseInvite(ctx, code)
+		if releaseErr != nil {
+			return nil, fmt.Errorf("%w (releasing invite: %w)", err, releaseErr)
+		}
+
+		return nil, err
+	}
+
+	invite.Uses++
+
+	err = s.audit(ctx, &AuditEvent{
+		Action:   AuditInviteRedeemed,
+		ActorID:  userID,
+		TargetID: InviteRef(code),
+		Reason:   "rank " + invite.Rank + ", invited by " + invite.CreatedBy,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return invite, nil
+}
+
+// checkInviteCreator fails with ErrInviteInvalid when the invite's creator
+// could no longer grant its rank directly: their codes stop working once they
+// are demoted, deactivated or banned.
+func (s *Service) checkInviteCreator(ctx context.Context, invite *Invite) error {
+	rank, err := s.repo.GetRank(ctx, invite.Rank)
+	if err != nil {
+		return err
+	}
+
+	creatorLevel, err := s.actorLevel(ctx, invite.CreatedBy)
+	if errors.Is(err, ErrUserNotFound) {
+		return fmt.Errorf("%w: its creator is no longer registered", ErrInviteInvalid)
+	}
+
+	if err != nil {
+		return err
+	}
+
+	if canGrant(creatorLevel, rank) != nil {
+		return fmt.Errorf("%w: its creator can no longer grant %s", ErrInviteInvalid, rank.Name)
+	}
+
+	if IsSystemActor(invite.CreatedBy) {
+		return nil
+	}
+
+	_, err = s.lookupBan(ctx, s.canonicalID(ctx, invite.CreatedBy), time.Now())
+	if err == nil {
+		return fmt.Errorf("%w: its creator is banned", ErrInviteInvalid)
+	}
+
+	if !errors.Is(err, ErrNotBanned) {
+		return err
+	}
+
+	return nil
+}
+
+// ListInvites returns the invites that are neither revoked nor expired and
+// whose rank the actor could grant, plus those for ranks that no longer
+// exist. Codes are replaced by their InviteRef, so a listing never reveals a
+// usable code.
+func (s *Service) ListInvites(ctx context.Context, actorID string) ([]*Invite, error) {
+	actorLevel, err := s.actorLevel(ctx, actorID)
+	if err != nil {
+		return nil, err
+	}
+
+	invites, err := s.repo.ListInvites(ctx, time.Now())
+	if err != nil {
+		return nil, err
+	}
+
+	ranks, err := s.repo.ListRanks(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	byName := make(map[string]*Rank, len(ranks))
+	for _, rank := range ranks {
+		byName[rank.Name] = rank
+	}
+
+	visible := make([]*Invite, 0, len(invites))
+
+	for _, invite := range invites {
+		rank, ok := byName[invite.Rank]
+		if ok && canGrant(actorLevel, rank) != nil {
+			continue
+		}
+
+		invite.Code = InviteRef(invite.Code)
+		visible = append(visible, invite)
+	}
+
+	return visible, nil
+}
+
+// RevokeInvite stops a code from being redeemed. It takes the code or its
+// InviteRef. The actor must be able to grant the invite's rank.
+func (s *Service) RevokeInvite(ctx context.Context, code, actorID string) error {
+	invite, err := s.findInvite(ctx, code)
+	if err != nil {
+		return err
+	}
+
+	code = invite.Code
+
+	actorLevel, err := s.actorLevel(ctx, actorID)
+	if err != nil {
+		return err
+	}
+
+	// an invite for a rank that no longer exists is dead anyway, so anyone
+	// who may manage invites can clean it up.
+	rank, err := s.repo.GetRank(ctx, invite.Rank)
+
+	switch {
+	case err == nil:
+		err = canGrant(actorLevel, rank)
+	case errors.Is(err, ErrRankNotFound):
+		err = nil
+	}
+
+	if err != nil {
+		return err
+	}
+
+	err = s.repo.RevokeInvite(ctx, code)
+	if err != nil {
+		return err
+	}
+
+	return s.audit(ctx, &AuditEvent{
+		Action:   AuditInviteRevoked,
+		ActorID:  actorID,
+		TargetID: InviteRef(code),
+		Reason:   fmt.Sprintf("rank %s, used %d/%d", invite.Rank, invite.Uses, invite.MaxUses),
+	})
+}
+
+// findInvite looks an invite up by code or, for "#<ref>", among the live
+// invites by InviteRef.
+func (s *Service) findInvite(ctx context.Context, codeOrRef string) (*Invite, error) {
+	codeOrRef = strings.TrimSpace(codeOrRef)
+	if !strings.HasPrefix(codeOrRef, inviteRefPrefix) {
+		return s.repo.GetInvite(ctx, normalizeInviteCode(codeOrRef))
+	}
+
+	invites, err := s.repo.ListInvites(ctx, time.Now())
+	if err != nil {
+		return nil, err
+	}
+
+	var found *Invite
+
+	for _, invite := range invites {
+		if !strings.EqualFold(InviteRef(invite.Code), codeOrRef) {
+			continue
+		}
+
+		if found != nil {
+			return nil, fmt.Errorf("%w: %s matches several invites, use the code", ErrInvalidInput, codeOrRef)
+		}
+
+		found = invite
+	}
+
+	if found == nil {
+		return nil, ErrInviteNotFound
+	}
+
+	return found, nil
+}
+
+// InviteRef names an invite without revealing its code: "#" and the start of
+// the code's SHA-256 in hex. Listings and audit events use it.
+func InviteRef(code string) string {
+	sum := sha256.Sum256([]byte(code))
+
+	return inviteRefPrefix + hex.EncodeToString(sum[:inviteRefBytes])
+}
+
+func normalizeInviteCode(code string) string {
+	return strings.ToUpper(strings.TrimSpace(code))
+}
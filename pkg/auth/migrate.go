@@ -0,0 +1,202 @@
+package auth
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+)
+
+// adminCommands is what the migrations leave the seeded admin rank with.
+// Each migration that adds a management command only extends admin while it
+// still holds exactly the list the previous one left, so an operator's edits
+// are kept, and the new command is then not granted.
+var adminCommands = []string{
+	"help", "latex", "register_user", "register_group", "promote", "demote", "ban", "unban",
+	"unregister_group", "rank", "group", "audit", "invite", "approve", "deny", "penalties",
+}
+
+// Migrate brings the auth tables up to date by applying every embedded
+// migration newer than the database, each in its own transaction. It returns
+// how many migrations were applied.
+func Migrate(ctx context.Context, database *sql.DB) (int, error) {
+	migrations, err := loadMigrations()
+	if err != nil {
+		return 0, err
+	}
+
+	_, err = database.ExecContext(ctx, schemaVersionTable)
+	if err != nil {
+		return 0, fmt.Errorf("create schema_version: %w", err)
+	}
+
+	current, err := SchemaVersion(ctx, database)
+	if err != nil {
+		return 0, err
+	}
+
+	if current == 0 {
+		current, err = adoptLegacySchema(ctx, database, migrations)
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	if len(migrations) > 0 && current > migrations[len(migrations)-1].version {
+		return 0, fmt.Errorf("%w: database at version %d is newer than this binary", ErrSchemaDrift, current)
+	}
+
+	applied := 0
+
+	for _, m := range migrations {
+		if m.version <= current {
+			continue
+		}
+
+		err = applyMigration(ctx, database, m)
+		if err != nil {
+			return applied, err
+		}
+
+		applied++
+	}
+
+	return applied, nil
+}
+
+func applyMigration(ctx context.Context, database *sql.DB, m migration) (err error) {
+	tx, err := database.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("begin migration %s: %w", m.name, err)
+	}
+
+	defer func() {
+		if err != nil {
+			rerr := tx.Rollback()
+			if rerr != nil {
+				err = fmt.Errorf("%w (rollback: %w)", err, rerr)
+			}
+		}
+	}()
+
+	_, err = tx.ExecContext(ctx, m.sql)
+	if err != nil {
+		return fmt.Errorf("apply migration %s: %w", m.name, err)
+	}
+
+	err = recordMigration(ctx, tx, m)
+	if err != nil {
+		return err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return fmt.Errorf("commit migration %s: %w", m.name, err)
+	}
+
+	return nil
+}
+
+func recordMigration(ctx context.Context, tx *sql.Tx, m migration) error {
+	_, err := tx.ExecContext(ctx, "INSERT INTO schema_version (version, name) VALUES (?, ?)", m.version, m.name)
+	if err != nil {
+		return fmt.Errorf("record migration %s: %w", m.name, err)
+	}
+
+	return nil
+}
+
+// MissingAdminCommands returns the commands the migrations grant the admin
+// rank that it does not have, because it was edited before they ran. It
+// returns nil when there is no active admin rank.
+func MissingAdminCommands(ctx context.Context, database *sql.DB) ([]string, error) {
+	var raw string
+
+	err := database.QueryRowContext(ctx, "SELECT commands FROM ranks WHERE name = 'admin' AND active = 1").Scan(&raw)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("read admin rank: %w", err)
+	}
+
+	own := ParseCommands(raw)
+
+	var missing []string
+
+	for _, command := range adminCommands {
+		if !matchCommands(own, command) {
+			missing = append(missing, command)
+		}
+	}
+
+	return missing, nil
+}
+
+// adoptLegacySchema records the migrations a database created before
+// versioning already contains, so they are not applied twice. It returns the
+// detected version, 0 for an empty database.
+func adoptLegacySchema(ctx context.Context, database *sql.DB, migrations []migration) (version int, err error) {
+	version, err = detectLegacyVersion(ctx, database)
+	if err != nil || version == 0 {
+		return version, err
+	}
+
+	tx, err := database.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, fmt.Errorf("begin adopting legacy schema: %w", err)
+	}
+
+	defer func() {
+		if err != nil {
+			rerr := tx.Rollback()
+			if rerr != nil {
+				err = fmt.Errorf("%w (rollback: %w)", err, rerr)
+			}
+		}
+	}()
+
+	for _, m := range migrations {
+		if m.version > version {
+			break
+		}
+
+		err = recordMigration(ctx, tx, m)
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return 0, fmt.Errorf("commit legacy schema version: %w", err)
+	}
+
+	return version, nil
+}
+
+// legacyMarkers holds, for each of the first migrations in order, a table it
+// introduced. Databases created before versioning are matched against them.
+var legacyMarkers = []string{"users", "user_bans", "group_policies"}
+
+// detectLegacyVersion infers which migrations an unversioned database
+// already has.
+func detectLegacyVersion(ctx context.Context, database *sql.DB) (int, error) {
+	version := 0
+
+	for i, table := range legacyMarkers {
+		columns, err := tableColumns(ctx, database, table)
+		if err != nil {
+			return 0, err
+		}
+
+		if len(columns) == 0 {
+			break
+		}
+
+		version = i + 1
+	}
+
+	return version, nil
+}
@@ -0,0 +1,298 @@
+package auth
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"slices"
+	"testing"
+
+	_ "github.com/mattn/go-sqlite3"
+)
+
+func openMemoryDB(t *testing.T) *sql.DB {
+	t.Helper()
+
+	database, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatalf("open database: %v", err)
+	}
+
+	// every connection to :memory: is a separate database.
+	database.SetMaxOpenConns(1)
+
+	t.Cleanup(func() {
+		database.Close()
+	})
+
+	return database
+}
+
+func mustLoadMigrations(t *testing.T) []migration {
+	t.Helper()
+
+	migrations, err := loadMigrations()
+	if err != nil {
+		t.Fatalf("load migrations: %v", err)
+	}
+
+	if len(migrations) == 0 {
+		t.Fatal("no migrations embedded")
+	}
+
+	return migrations
+}
+
+// applyRaw runs migration files the way databases were set up before
+// versioning: without recording them in schema_version.
+func applyRaw(t *testing.T, database *sql.DB, migrations []migration) {
+	t.Helper()
+
+	for _, m := range migrations {
+		_, err := database.Exec(m.sql)
+		if err != nil {
+			t.Fatalf("apply %s: %v", m.name, err)
+		}
+	}
+}
+
+func schemaVersion(t *testing.T, database *sql.DB) int {
+	t.Helper()
+
+	version, err := SchemaVersion(context.Background(), database)
+	if err != nil {
+		t.Fatalf("schema version: %v", err)
+	}
+
+	return version
+}
+
+func TestMigrateFresh(t *testing.T) {
+	ctx := context.Background()
+	database := openMemoryDB(t)
+	migrations := mustLoadMigrations(t)
+
+	applied, err := Migrate(ctx, database)
+	if err != nil {
+		t.Fatalf("Migrate: %v", err)
+	}
+
+	if applied != len(migrations) {
+		t.Errorf("applied %d migrations, want %d", applied, len(migrations))
+	}
+
+	latest, err := LatestSchemaVersion()
+	if err != nil {
+		t.Fatalf("LatestSchemaVersion: %v", err)
+	}
+
+	if got := schemaVersion(t, database); got != latest {
+		t.Errorf("schema version %d, want %d", got, latest)
+	}
+
+	err = ValidateSchema(ctx, database)
+	if err != nil {
+		t.Errorf("ValidateSchema: %v", err)
+	}
+
+	var raw string
+
+	err = database.QueryRow("SELECT commands FROM ranks WHERE name = 'admin'").Scan(&raw)
+	if err != nil {
+		t.Fatalf("read admin rank: %v", err)
+	}
+
+	if got := ParseCommands(raw); !slices.Equal(got, adminCommands) {
+		t.Errorf("admin commands %q, want adminCommands %q", got, adminCommands)
+	}
+}
+
+func TestMigrateTwice(t *testing.T) {
+	ctx := context.Background()
+	database := openMemoryDB(t)
+
+	_, err := Migrate(ctx, database)
+	if err != nil {
+		t.Fatalf("first Migrate: %v", err)
+	}
+
+	before := schemaVersion(t, database)
+
+	applied, err := Migrate(ctx, database)
+	if err != nil {
+		t.Fatalf("second Migrate: %v", err)
+	}
+
+	if applied != 0 {
+		t.Errorf("second Migrate applied %d migrations, want 0", applied)
+	}
+
+	if after := schemaVersion(t, database); after != before {
+		t.Errorf("schema version moved from %d to %d", before, after)
+	}
+}
+
+func TestMigrateAdoptsLegacySchema(t *testing.T) {
+	migrations := mustLoadMigrations(t)
+
+	for version := 1; version <= len(legacyMarkers); version++ {
+		t.Run(migrations[version-1].name, func(t *testing.T) {
+			ctx := context.Background()
+			database := openMemoryDB(t)
+			applyRaw(t, database, migrations[:version])
+
+			applied, err := Migrate(ctx, database)
+			if err != nil {
+				t.Fatalf("Migrate: %v", err)
+			}
+
+			if want := len(migrations) - version; applied != want {
+				t.Errorf("applied %d migrations, want %d", applied, want)
+			}
+
+			var recorded int
+
+			err = database.QueryRow("SELECT COUNT(*) FROM schema_version").Scan(&recorded)
+			if err != nil {
+				t.Fatalf("count schema_version: %v", err)
+			}
+
+			if recorded != len(migrations) {
+				t.Errorf("schema_version has %d rows, want %d", recorded, len(migrations))
+			}
+
+			err = ValidateSchema(ctx, database)
+			if err != nil {
+				t.Errorf("ValidateSchema: %v", err)
+			}
+		})
+	}
+}
+
+func TestMigrateRefusesNewerDatabase(t *testing.T) {
+	ctx := context.Background()
+	database := openMemoryDB(t)
+
+	_, err := Migrate(ctx, database)
+	if err != nil {
+		t.Fatalf("Migrate: %v", err)
+	}
+
+	_, err = database.Exec("INSERT INTO schema_version (version, name) VALUES (9999, 'from_the_future')")
+	if err != nil {
+		t.Fatalf("insert version: %v", err)
+	}
+
+	_, err = Migrate(ctx, database)
+	if !errors.Is(err, ErrSchemaDrift) {
+		t.Errorf("Migrate on a newer database = %v, want ErrSchemaDrift", err)
+	}
+}
+
+func TestValidateSchemaDetectsDrift(t *testing.T) {
+	tests := []struct {
+		name  string
+		drift string
+	}{
+		{"missing table", "DROP TABLE invite_codes"},
+		{"missing column", "ALTER TABLE users DROP COLUMN previous_rank"},
+		{"pending migration", "DELETE FROM schema_version WHERE version = (SELECT MAX(version) FROM schema_version)"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			database := openMemoryDB(t)
+
+			_, err := Migrate(ctx, database)
+			if err != nil {
+				t.Fatalf("Migrate: %v", err)
+			}
+
+			_, err = database.Exec(tt.drift)
+			if err != nil {
+				t.Fatalf("%s: %v", tt.drift, err)
+			}
+
+			err = ValidateSchema(ctx, database)
+			if !errors.Is(err, ErrSchemaDrift) {
+				t.Errorf("ValidateSchema = %v, want ErrSchemaDrift", err)
+			}
+		})
+	}
+}
+
+func TestMissingAdminCommands(t *testing.T) {
+	ctx := context.Background()
+	migrations := mustLoadMigrations(t)
+
+	t.Run("seeded admin", func(t *testing.T) {
+		database := openMemoryDB(t)
+
+		_, err := Migrate(ctx, database)
+		if err != nil {
+			t.Fatalf("Migrate: %v", err)
+		}
+
+		missing, err := MissingAdminCommands(ctx, database)
+		if err != nil {
+			t.Fatalf("MissingAdminCommands: %v", err)
+		}
+
+		if len(missing) != 0 {
+			t.Errorf("missing %q, want none", missing)
+		}
+	})
+
+	t.Run("edited admin", func(t *testing.T) {
+		database := openMemoryDB(t)
+		applyRaw(t, database, migrations[:1])
+
+		// an operator trimmed admin before the grants ran.
+		_, err := database.Exec("UPDATE ranks SET commands = 'help,latex,ban' WHERE name = 'admin'")
+		if err != nil {
+			t.Fatalf("edit admin: %v", err)
+		}
+
+		_, err = Migrate(ctx, database)
+		if err != nil {
+			t.Fatalf("Migrate: %v", err)
+		}
+
+		missing, err := MissingAdminCommands(ctx, database)
+		if err != nil {
+			t.Fatalf("MissingAdminCommands: %v", err)
+		}
+
+		want := slices.DeleteFunc(slices.Clone(adminCommands), func(command string) bool {
+			return command == "help" || command == "latex" || command == "ban"
+		})
+
+		if !slices.Equal(missing, want) {
+			t.Errorf("missing %q, want %q", missing, want)
+		}
+	})
+
+	t.Run("wildcard admin", func(t *testing.T) {
+		database := openMemoryDB(t)
+
+		_, err := Migrate(ctx, database)
+		if err != nil {
+			t.Fatalf("Migrate: %v", err)
+		}
+
+		_, err = database.Exec("UPDATE ranks SET commands = '*' WHERE name = 'admin'")
+		if err != nil {
+			t.Fatalf("edit admin: %v", err)
+		}
+
+		missing, err := MissingAdminCommands(ctx, database)
+		if err != nil {
+			t.Fatalf("MissingAdminCommands: %v", err)
+		}
+
+		if len(missing) != 0 {
+			t.Errorf("missing %q, want none", missing)
+		}
+	})
+}
@@ -0,0 +1,21 @@
+-- Who deactivated a user or group, and when
+ALTER TABLE users ADD COLUMN deactivated_at DATETIME;
+ALTER TABLE users ADD COLUMN deactivated_by TEXT;
+ALTER TABLE registered_groups ADD COLUMN deactivated_at DATETIME;
+ALTER TABLE registered_groups ADD COLUMN deactivated_by TEXT;
+
+-- Bans table (a ban applies whether or not the user is registered)
+CREATE TABLE IF NOT EXISTS user_bans (
+    user_id TEXT PRIMARY KEY,
+    reason TEXT,
+    banned_by TEXT NOT NULL,
+    banned_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    expires_at DATETIME
+);
+
+CREATE INDEX IF NOT EXISTS idx_bans_expires ON user_bans(expires_at);
+
+-- Give the seeded admin rank the management commands, unless it was edited
+UPDATE ranks
+SET commands = 'help,latex,register_user,register_group,promote,demote,ban,unban,unregister_group,rank'
+WHERE name = 'admin' AND commands = 'help,latex,register_user,register_group';
@@ -0,0 +1,15 @@
+-- Per-group overrides of rank permissions
+CREATE TABLE IF NOT EXISTS group_policies (
+    group_id TEXT NOT NULL,
+    command TEXT NOT NULL,
+    effect TEXT NOT NULL DEFAULT 'inherit',
+    min_rank TEXT,
+    updated_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    updated_by TEXT NOT NULL,
+    PRIMARY KEY (group_id, command)
+);
+
+UPDATE ranks
+SET commands = commands || ',group'
+WHERE name = 'admin'
+  AND commands = 'help,latex,register_user,register_group,promote,demote,ban,unban,unregister_group,rank';
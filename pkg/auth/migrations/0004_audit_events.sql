@@ -0,0 +1,20 @@
+-- Who did what to whom: registrations, rank changes, bans and denials
+CREATE TABLE IF NOT EXISTS audit_events (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    action TEXT NOT NULL,
+    actor_id TEXT,
+    target_id TEXT,
+    command TEXT,
+    group_id TEXT,
+    reason TEXT,
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_audit_actor ON audit_events(actor_id);
+CREATE INDEX IF NOT EXISTS idx_audit_target ON audit_events(target_id);
+CREATE INDEX IF NOT EXISTS idx_audit_group ON audit_events(group_id);
+
+UPDATE ranks
+SET commands = commands || ',audit'
+WHERE name = 'admin'
+  AND commands = 'help,latex,register_user,register_group,promote,demote,ban,unban,unregister_group,rank,group';
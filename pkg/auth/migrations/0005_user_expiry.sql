@@ -0,0 +1,6 @@
+-- Time-limited registrations and rank grants
+ALTER TABLE users ADD COLUMN expires_at DATETIME;
+ALTER TABLE users ADD COLUMN previous_rank TEXT;
+ALTER TABLE users ADD COLUMN expiry_notified_at DATETIME;
+
+CREATE INDEX IF NOT EXISTS idx_users_expires ON users(expires_at);
@@ -0,0 +1,16 @@
+-- Self-service registration codes minted by admins
+CREATE TABLE IF NOT EXISTS invite_codes (
+    code TEXT PRIMARY KEY,
+    rank TEXT NOT NULL,
+    max_uses INTEGER NOT NULL,
+    uses INTEGER NOT NULL DEFAULT 0,
+    expires_at DATETIME,
+    created_by TEXT NOT NULL,
+    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+    revoked_at DATETIME
+);
+
+UPDATE ranks
+SET commands = commands || ',invite'
+WHERE name = 'admin'
+  AND commands = 'help,latex,register_user,register_group,promote,demote,ban,unban,unregister_group,rank,group,audit';
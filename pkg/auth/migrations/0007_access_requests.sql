@@ -0,0 +1,19 @@
+-- Registration requests from unregistered users, decided by admins
+CREATE TABLE IF NOT EXISTS access_requests (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    user_id TEXT NOT NULL,
+    reason TEXT,
+    status TEXT NOT NULL DEFAULT 'pending',
+    rank TEXT,
+    created_at DATETIME NOT NULL,
+    expires_at DATETIME NOT NULL,
+    decided_by TEXT,
+    decided_at DATETIME
+);
+
+CREATE INDEX IF NOT EXISTS idx_access_requests_user ON access_requests(user_id, created_at);
+
+UPDATE ranks
+SET commands = commands || ',approve,deny'
+WHERE name = 'admin'
+  AND commands = 'help,latex,register_user,register_group,promote,demote,ban,unban,unregister_group,rank,group,audit,invite';
@@ -0,0 +1,8 @@
+-- Rate limiter and notifier state, saved periodically so limits survive
+-- restarts (see pkg/ratelimit). Each row is one key of one component.
+CREATE TABLE IF NOT EXISTS rate_limit_state (
+    component TEXT NOT NULL,
+    key TEXT NOT NULL,
+    state TEXT NOT NULL,
+    PRIMARY KEY (component, key)
+);
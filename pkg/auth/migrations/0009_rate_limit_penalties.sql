@@ -0,0 +1,5 @@
+-- Admins can review and clear rate limit penalties (see pkg/ratelimit)
+UPDATE ranks
+SET commands = commands || ',penalties'
+WHERE name = 'admin'
+  AND commands = 'help,latex,register_user,register_group,promote,demote,ban,unban,unregister_group,rank,group,audit,invite,approve,deny';
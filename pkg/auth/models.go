@@ -5,40 +5,178 @@ import (
 	"time"
 )
 
+// User is a registration. A user with ExpiresAt holds Rank only until then;
+// afterwards they fall back to PreviousRank, or count as unregistered when
+// it is empty.
 type User struct {
-	ID           string    `json:"id"`
-	Rank         string    `json:"rank"`
-	RegisteredAt time.Time `json:"registeredAt"`
-	RegisteredBy string    `json:"registeredBy"`
+	ID           string     `json:"id"                     yaml:"id"`
+	Rank         string     `json:"rank"                   yaml:"rank"`
+	RegisteredAt time.Time  `json:"registeredAt"           yaml:"registeredAt"`
+	RegisteredBy string     `json:"registeredBy"           yaml:"registeredBy"`
+	Active       bool       `json:"active"                 yaml:"active"`
+	ExpiresAt    *time.Time `json:"expiresAt,omitempty"    yaml:"expiresAt,omitempty"`
+	PreviousRank string     `json:"previousRank,omitempty" yaml:"previousRank,omitempty"`
+}
+
+// Expired reports whether the user's time-limited grant has run out.
+func (u *User) Expired(now time.Time) bool {
+	return u.ExpiresAt != nil && !now.Before(*u.ExpiresAt)
 }
 
 type Rank struct {
-	Name     string   `json:"name"`
-	Level    int      `json:"level"`
-	Commands []string `json:"commands"`
+	Name        string   `json:"name"                  yaml:"name"`
+	Level       int      `json:"level"                 yaml:"level"`
+	Commands    []string `json:"commands"              yaml:"commands"`
+	Description string   `json:"description,omitempty" yaml:"description,omitempty"`
 }
 
 type Group struct {
-	ID           string    `json:"id"`
-	RegisteredAt time.Time `json:"registeredAt"`
-	RegisteredBy string    `json:"registeredBy"`
+	ID           string    `json:"id"           yaml:"id"`
+	RegisteredAt time.Time `json:"registeredAt" yaml:"registeredAt"`
+	RegisteredBy string    `json:"registeredBy" yaml:"registeredBy"`
+}
+
+// Ban blocks a user from every command until ExpiresAt (nil = permanent).
+type Ban struct {
+	UserID    string     `json:"userId"`
+	Reason    string     `json:"reason,omitempty"`
+	BannedBy  string     `json:"bannedBy"`
+	BannedAt  time.Time  `json:"bannedAt"`
+	ExpiresAt *time.Time `json:"expiresAt,omitempty"`
+}
+
+// Invite lets up to MaxUses people register themselves with Rank until
+// ExpiresAt (nil = no expiry).
+type Invite struct {
+	Code      string     `json:"code"`
+	Rank      string     `json:"rank"`
+	MaxUses   int        `json:"maxUses"`
+	Uses      int        `json:"uses"`
+	ExpiresAt *time.Time `json:"expiresAt,omitempty"`
+	CreatedBy string     `json:"createdBy"`
+	CreatedAt time.Time  `json:"createdAt"`
+	Revoked   bool       `json:"revoked"`
+}
+
+// Usable reports whether the invite can still be redeemed.
+func (i *Invite) Usable(now time.Time) bool {
+	return !i.Revoked && i.Uses < i.MaxUses && (i.ExpiresAt == nil || now.Before(*i.ExpiresAt))
+}
+
+type RequestStatus string
+
+const (
+	RequestPending  RequestStatus = "pending"
+	RequestApproved RequestStatus = "approved"
+	RequestDenied   RequestStatus = "denied"
+)
+
+// AccessRequest is an unregistered user's request to be registered.
+type AccessRequest struct {
+	ID        int64         `json:"id"`
+	UserID    string        `json:"userId"`
+	Reason    string        `json:"reason,omitempty"`
+	Status    RequestStatus `json:"status"`
+	Rank      string        `json:"rank,omitempty"`
+	CreatedAt time.Time     `json:"createdAt"`
+	ExpiresAt time.Time     `json:"expiresAt"`
+	DecidedBy string        `json:"decidedBy,omitempty"`
+	DecidedAt *time.Time    `json:"decidedAt,omitempty"`
+}
+
+// Open reports whether the request still awaits a decision.
+func (r *AccessRequest) Open(now time.Time) bool {
+	return r.Status == RequestPending && now.Before(r.ExpiresAt)
 }
 
+type PolicyEffect string
+
+const (
+	// PolicyInherit leaves the decision to the user's rank.
+	PolicyInherit PolicyEffect = "inherit"
+	// PolicyAllow opens the command to everyone in the group, registered or not,
+	// unless it is a management command (see SetManagementCommands).
+	PolicyAllow PolicyEffect = "allow"
+	// PolicyDeny disables the command in the group for every rank.
+	PolicyDeny PolicyEffect = "deny"
+)
+
+// GroupPolicy overrides rank permissions for one command inside one group.
+// MinRank, when set, additionally requires users to hold that rank or a
+// higher one.
+type GroupPolicy struct {
+	GroupID   string       `json:"groupId"`
+	Command   string       `json:"command"`
+	Effect    PolicyEffect `json:"effect"`
+	MinRank   string       `json:"minRank,omitempty"`
+	UpdatedAt time.Time    `json:"updatedAt"`
+	UpdatedBy string       `json:"updatedBy"`
+}
+
+type AuditAction string
+
+const (
+	AuditPermissionDenied      AuditAction = "permission_denied"
+	AuditUserRegistered        AuditAction = "user_registered"
+	AuditUserDeactivated       AuditAction = "user_deactivated"
+	AuditUserReactivated       AuditAction = "user_reactivated"
+	AuditUserBanned            AuditAction = "user_banned"
+	AuditRankChanged           AuditAction = "rank_changed"
+	AuditGroupRegistered       AuditAction = "group_registered"
+	AuditGroupDeactivated      AuditAction = "group_deactivated"
+	AuditRankCreated           AuditAction = "rank_created"
+	AuditRankUpdated           AuditAction = "rank_updated"
+	AuditRankDeleted           AuditAction = "rank_deleted"
+	AuditGroupPolicySet        AuditAction = "group_policy_set"
+	AuditGroupPolicyDeleted    AuditAction = "group_policy_deleted"
+	AuditGrantExpired          AuditAction = "grant_expired"
+	AuditInviteCreated         AuditAction = "invite_created"
+	AuditInviteRedeemed        AuditAction = "invite_redeemed"
+	AuditInviteRevoked         AuditAction = "invite_revoked"
+	AuditAccessRequested       AuditAction = "access_requested"
+	AuditAccessApproved        AuditAction = "access_approved"
+	AuditAccessDenied          AuditAction = "access_denied"
+	AuditIdentityCanonicalized AuditAction = "identity_canonicalized"
+)
+
+// AuditEvent records one authorization decision or administrative change.
+// TargetID is the user, group or rank acted upon; GroupID is the group the
+// request came from, if any.
+type AuditEvent struct {
+	ID        int64       `json:"id"`
+	Action    AuditAction `json:"action"`
+	ActorID   string      `json:"actorId"`
+	TargetID  string      `json:"targetId,omitempty"`
+	Command   string      `json:"command,omitempty"`
+	GroupID   string      `json:"groupId,omitempty"`
+	Reason    string      `json:"reason,omitempty"`
+	CreatedAt time.Time   `json:"createdAt"`
+}
+
+// AuditFilter narrows ListAuditEvents. UserID matches events where the user
+// is either the actor or the target; GroupID matches events that happened in
+// or to the group. Limit defaults to DefaultAuditLimit.
+type AuditFilter struct {
+	UserID  string
+	GroupID string
+	Limit   int
+}
+
+// PermissionResult is the outcome of a permission check. Denials carry a Code
+// (see denials.go) and, where there is more to say, Details; Reason is a
+// human-readable summary for logs.
 type PermissionResult struct {
-	Allowed  bool   `json:"allowed"`
-	Reason   string `json:"reason"`
-	UserRank string `json:"userRank,omitempty"`
+	Allowed  bool           `json:"allowed"`
+	Code     DenialCode     `json:"code,omitempty"`
+	Reason   string         `json:"reason"`
+	UserRank string         `json:"userRank,omitempty"`
+	Details  *DenialDetails `json:"details,omitempty"`
 }
 
-// checks if rank has permission for command.
+// checks if rank has permission for command, applying wildcards and
+// negations in the rank's list (see patterns.go).
 func (r *Rank) HasCommand(command string) bool {
-	for _, cmd := range r.Commands {
-		if cmd == "*" || cmd == command {
-			return true
-		}
-	}
-
-	return false
+	return matchCommands(r.Commands, command)
 }
 
 // converts comma-separated string to slice
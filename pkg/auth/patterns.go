@@ -0,0 +1,107 @@
+package auth
+
+import "strings"
+
+// Rank command lists hold patterns rather than plain names:
+//
+//	help        the command "help"
+//	admin.*     every command in the "admin." family (also "render:*")
+//	*           every command
+//	-broadcast  negation: takes the command (or family) away again
+//
+// The most specific matching entry decides: an exact name beats a family
+// wildcard, a longer family beats a shorter one, and "*" comes last. When a
+// grant and a negation are equally specific, the negation wins. The order of
+// the list does not matter.
+const (
+	wildcard          = "*"
+	negationPrefix    = "-"
+	commandSeparators = ".:"
+)
+
+// wildcardFamily returns the family a wildcard pattern stands for, including
+// its separator ("admin." for "admin.*").
+func wildcardFamily(pattern string) (string, bool) {
+	family, ok := strings.CutSuffix(pattern, wildcard)
+	if !ok || family == "" || !strings.ContainsAny(family[len(family)-1:], commandSeparators) {
+		return "", false
+	}
+
+	return family, true
+}
+
+// patternSpecificity scores how specifically a pattern (without negation)
+// matches a command, or returns -1 when it does not match.
+func patternSpecificity(pattern, command string) int {
+	switch {
+	case pattern == command:
+		// longer than any family the command can be in.
+		return len(command) + 1
+	case pattern == wildcard:
+		return 0
+	}
+
+	family, ok := wildcardFamily(pattern)
+	if ok && strings.HasPrefix(command, family) {
+		return len(family)
+	}
+
+	return -1
+}
+
+// matchCommands applies a command list to a command.
+func matchCommands(patterns []string, command string) bool {
+	best, allowed := -1, false
+
+	for _, entry := range patterns {
+		pattern, negated := strings.CutPrefix(entry, negationPrefix)
+
+		score := patternSpecificity(pattern, command)
+		if score < 0 || score < best {
+			continue
+		}
+
+		if score > best || negated {
+			best, allowed = score, !negated
+		}
+	}
+
+	return allowed
+}
+
+// coversPattern reports whether a rank may hand out a grant pattern. For a
+// name that is HasCommand; a wildcard needs an equally broad or broader
+// grant and no negation reaching into it.
+func (r *Rank) coversPattern(pattern string) bool {
+	prefix, isFamily := wildcardFamily(pattern)
+	if !isFamily && pattern != wildcard {
+		return r.HasCommand(pattern)
+	}
+
+	granted := false
+
+	for _, entry := range r.Commands {
+		own, negated := strings.CutPrefix(entry, negationPrefix)
+
+		ownPrefix, ownFamily := wildcardFamily(own)
+		if !ownFamily && own != wildcard {
+			// a negated name inside the family is carved out of it.
+			if negated && strings.HasPrefix(own, prefix) {
+				return false
+			}
+
+			continue
+		}
+
+		overlaps := strings.HasPrefix(prefix, ownPrefix) || strings.HasPrefix(ownPrefix, prefix)
+
+		switch {
+		case negated && overlaps:
+			return false
+		case !negated && strings.HasPrefix(prefix, ownPrefix):
+			granted = true
+		}
+	}
+
+	return granted
+}
@@ -0,0 +1,139 @@
+package auth
+
+import "testing"
+
+func TestMatchCommands(t *testing.T) {
+	tests := []struct {
+		name     string
+		patterns []string
+		command  string
+		want     bool
+	}{
+		{"exact name", []string{"help"}, "help", true},
+		{"other name", []string{"help"}, "latex", false},
+		{"empty list", nil, "help", false},
+		{"wildcard", []string{"*"}, "anything", true},
+		{"dot family", []string{"admin.*"}, "admin.ban", true},
+		{"dot family nested", []string{"admin.*"}, "admin.users.list", true},
+		{"dot family needs separator", []string{"admin.*"}, "administrator", false},
+		{"dot family is not its root", []string{"admin.*"}, "admin", false},
+		{"colon family", []string{"render:*"}, "render:png", true},
+		{"colon family other separator", []string{"render:*"}, "render.png", false},
+		{"bare suffix wildcard is not a family", []string{"adm*"}, "admin", false},
+		{"negation alone", []string{"-broadcast"}, "broadcast", false},
+		{"wildcard minus name", []string{"*", "-broadcast"}, "broadcast", false},
+		{"wildcard minus name keeps others", []string{"*", "-broadcast"}, "help", true},
+		{"family minus name", []string{"admin.*", "-admin.ban"}, "admin.ban", false},
+		{"family minus name keeps siblings", []string{"admin.*", "-admin.ban"}, "admin.kick", true},
+		{"name beats negated family", []string{"-admin.*", "admin.ban"}, "admin.ban", true},
+		{"negated family beats wildcard", []string{"*", "-admin.*"}, "admin.ban", false},
+		{"longer family wins", []string{"-admin.*", "admin.users.*"}, "admin.users.list", true},
+		{"shorter family loses", []string{"admin.*", "-admin.users.*"}, "admin.users.list", false},
+		{"negation wins tie", []string{"help", "-help"}, "help", false},
+		{"negation wins tie in any order", []string{"-help", "help"}, "help", false},
+		{"negation wins family tie", []string{"-admin.*", "admin.*"}, "admin.ban", false},
+		{"negation wins wildcard tie", []string{"*", "-*"}, "help", false},
+		{"order does not matter", []string{"-broadcast", "*"}, "broadcast", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := matchCommands(tt.patterns, tt.command)
+			if got != tt.want {
+				t.Errorf("matchCommands(%q, %q) = %v, want %v", tt.patterns, tt.command, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPatternSpecificity(t *testing.T) {
+	tests := []struct {
+		pattern string
+		command string
+		want    int
+	}{
+		{"help", "help", 5},
+		{"*", "help", 0},
+		{"admin.*", "admin.ban", 6},
+		{"admin.users.*", "admin.users.list", 12},
+		{"render:*", "render:png", 7},
+		{"admin.*", "help", -1},
+		{"help", "helper", -1},
+	}
+
+	for _, tt := range tests {
+		got := patternSpecificity(tt.pattern, tt.command)
+		if got != tt.want {
+			t.Errorf("patternSpecificity(%q, %q) = %d, want %d", tt.pattern, tt.command, got, tt.want)
+		}
+	}
+
+	// an exact name must outrank every family the command belongs to.
+	if patternSpecificity("admin.users.list", "admin.users.list") <= patternSpecificity("admin.users.*", "admin.users.list") {
+		t.Error("exact name does not outrank its longest family")
+	}
+}
+
+func TestCoversPattern(t *testing.T) {
+	tests := []struct {
+		name    string
+		own     []string
+		pattern string
+		want    bool
+	}{
+		{"name it has", []string{"help"}, "help", true},
+		{"name it lacks", []string{"help"}, "latex", false},
+		{"name through its family", []string{"admin.*"}, "admin.ban", true},
+		{"name negated out of its family", []string{"admin.*", "-admin.ban"}, "admin.ban", false},
+		{"same family", []string{"admin.*"}, "admin.*", true},
+		{"narrower family", []string{"admin.*"}, "admin.users.*", true},
+		{"broader family", []string{"admin.users.*"}, "admin.*", false},
+		{"family through wildcard", []string{"*"}, "admin.*", true},
+		{"wildcard through wildcard", []string{"*"}, "*", true},
+		{"wildcard through family", []string{"admin.*"}, "*", false},
+		{"family with a name carved out", []string{"admin.*", "-admin.ban"}, "admin.*", false},
+		{"family with a subfamily carved out", []string{"admin.*", "-admin.users.*"}, "admin.*", false},
+		{"family inside a negated family", []string{"*", "-admin.*"}, "admin.users.*", false},
+		{"unrelated negation", []string{"*", "-broadcast"}, "admin.*", true},
+		{"wildcard with any name carved out", []string{"*", "-broadcast"}, "*", false},
+		{"other family", []string{"admin.*"}, "render:*", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rank := &Rank{Name: "test", Commands: tt.own}
+
+			got := rank.coversPattern(tt.pattern)
+			if got != tt.want {
+				t.Errorf("%q coversPattern(%q) = %v, want %v", tt.own, tt.pattern, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCanDelegate(t *testing.T) {
+	actor := &Rank{Name: "admin", Commands: []string{"help", "latex", "admin.*", "-admin.shutdown"}}
+
+	tests := []struct {
+		name     string
+		commands []string
+		wantErr  bool
+	}{
+		{"names it has", []string{"help", "latex"}, false},
+		{"name from its family", []string{"admin.ban"}, false},
+		{"negations are always allowed", []string{"-anything", "-*"}, false},
+		{"name it lacks", []string{"render"}, true},
+		{"name it had taken away", []string{"admin.shutdown"}, true},
+		{"its family with a carve-out", []string{"admin.*"}, true},
+		{"wildcard", []string{"*"}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := canDelegate(actor, tt.commands)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("canDelegate(%q) = %v, want error %v", tt.commands, err, tt.wantErr)
+			}
+		})
+	}
+}
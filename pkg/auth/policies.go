@@ -0,0 +1,199 @@
+package auth
+
+import (
+	"context"
+	"errors"
+)
+
+// groupPolicyCommand manages policies itself and cannot be overridden, so a
+// group can never lock its admins out of undoing a policy.
+const groupPolicyCommand = "group"
+
+// SetGroupPolicy creates or replaces the policy for one command in a group.
+// Opening a command requires the actor to hold it, and a minimum rank cannot
+// be set above the actor's own rank. Management commands cannot be opened at
+// all (ErrPolicyManagement). Replacing a policy takes the same rights as
+// deleting it.
+func (s *Service) SetGroupPolicy(ctx context.Context, policy *GroupPolicy, actorID string) error {
+	err := validateGroupPolicy(policy)
+	if err != nil {
+		return err
+	}
+
+	if policy.Effect == PolicyAllow && s.manageCommands[policy.Command] {
+		return ErrPolicyManagement
+	}
+
+	exists, err := s.repo.GroupExists(ctx, policy.GroupID)
+	if err != nil {
+		return err
+	}
+
+	if !exists {
+		return ErrGroupNotRegistered
+	}
+
+	actor, err := s.actorRank(ctx, actorID)
+	if err != nil {
+		return err
+	}
+
+	err = s.canSetPolicy(ctx, actor, policy)
+	if err != nil {
+		return err
+	}
+
+	err = s.canReplacePolicy(ctx, actor, policy.GroupID, policy.Command)
+	if err != nil && !errors.Is(err, ErrPolicyNotFound) {
+		return err
+	}
+
+	policy.UpdatedBy = actorID
+
+	err = s.repo.SaveGroupPolicy(ctx, policy)
+	if err != nil {
+		return err
+	}
+
+	s.cache.forgetPolicy(policy.GroupID, policy.Command)
+
+	reason := string(policy.Effect)
+	if policy.MinRank != "" {
+		reason += ", min rank " + policy.MinRank
+	}
+
+	return s.audit(ctx, &AuditEvent{
+		Action:   AuditGroupPolicySet,
+		ActorID:  actorID,
+		TargetID: policy.GroupID,
+		Command:  policy.Command,
+		GroupID:  policy.GroupID,
+		Reason:   reason,
+	})
+}
+
+// DeleteGroupPolicy drops a group's override so the command falls back to
+// rank permissions. The actor needs the rights to set the policy themselves
+// and must rank at least as high as whoever set it.
+func (s *Service) DeleteGroupPolicy(ctx context.Context, groupID, command, actorID string) error {
+	if groupID == "" {
+		return ErrInvalidInput
+	}
+
+	err := ValidateCommand(command)
+	if err != nil {
+		return err
+	}
+
+	actor, err := s.actorRank(ctx, actorID)
+	if err != nil {
+		return err
+	}
+
+	err = s.canReplacePolicy(ctx, actor, groupID, command)
+	if err != nil {
+		return err
+	}
+
+	err = s.repo.DeleteGroupPolicy(ctx, groupID, command)
+	if err != nil {
+		return err
+	}
+
+	s.cache.forgetPolicy(groupID, command)
+
+	return s.audit(ctx, &AuditEvent{
+		Action:   AuditGroupPolicyDeleted,
+		ActorID:  actorID,
+		TargetID: groupID,
+		Command:  command,
+		GroupID:  groupID,
+	})
+}
+
+// canSetPolicy is the delegation check for a policy: an `allow` needs the
+// command, and the minimum rank cannot be above the actor's.
+func (s *Service) canSetPolicy(ctx context.Context, actor *Rank, policy *GroupPolicy) error {
+	if policy.Effect == PolicyAllow {
+		err := canDelegate(actor, []string{policy.Command})
+		if err != nil {
+			return err
+		}
+	}
+
+	if policy.MinRank == "" {
+		return nil
+	}
+
+	minRank, err := s.repo.GetRank(ctx, policy.MinRank)
+	if err != nil {
+		return err
+	}
+
+	if minRank.Level < actor.Level {
+		return ErrRankEscalation
+	}
+
+	return nil
+}
+
+// canReplacePolicy checks that actor may change the group's current policy
+// for command: they could have set it, and whoever did does not outrank them.
+// It returns ErrPolicyNotFound when there is no policy.
+func (s *Service) canReplacePolicy(ctx context.Context, actor *Rank, groupID, command string) error {
+	current, err := s.repo.GetGroupPolicy(ctx, groupID, command)
+	if err != nil {
+		return err
+	}
+
+	// a minimum rank that was deleted since no longer restricts anything.
+	err = s.canSetPolicy(ctx, actor, current)
+	if err != nil && !errors.Is(err, ErrRankNotFound) {
+		return err
+	}
+
+	setter, err := s.actorRank(ctx, current.UpdatedBy)
+
+	switch {
+	case errors.Is(err, ErrUserNotFound):
+		// setters who left no longer protect their policies.
+		return nil
+	case err != nil:
+		return err
+	case setter.Level < actor.Level:
+		return ErrRankEscalation
+	default:
+		return nil
+	}
+}
+
+func (s *Service) ListGroupPolicies(ctx context.Context, groupID string) ([]*GroupPolicy, error) {
+	if groupID == "" {
+		return nil, ErrInvalidInput
+	}
+
+	return s.repo.ListGroupPolicies(ctx, groupID)
+}
+
+func validateGroupPolicy(policy *GroupPolicy) error {
+	if policy.GroupID == "" || policy.Command == groupPolicyCommand {
+		return ErrInvalidInput
+	}
+
+	err := ValidateCommand(policy.Command)
+	if err != nil {
+		return err
+	}
+
+	switch policy.Effect {
+	case PolicyInherit, PolicyAllow, PolicyDeny:
+	default:
+		return ErrInvalidInput
+	}
+
+	if policy.MinRank != "" {
+		return ValidateRankName(policy.MinRank)
+	}
+
+	return nil
+}
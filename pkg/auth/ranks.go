@@ -0,0 +1,196 @@
+package auth
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// CreateRank adds a custom rank. The actor must outrank the new level and
+// hold every command they put on it.
+func (s *Service) CreateRank(ctx context.Context, rank *Rank, actorID string) error {
+	err := ValidateRankName(rank.Name)
+	if err != nil {
+		return err
+	}
+
+	err = validateRankCommands(rank.Commands)
+	if err != nil {
+		return err
+	}
+
+	actor, err := s.actorRank(ctx, actorID)
+	if err != nil {
+		return err
+	}
+
+	err = canGrant(actor.Level, rank)
+	if err != nil {
+		return err
+	}
+
+	err = canDelegate(actor, rank.Commands)
+	if err != nil {
+		return err
+	}
+
+	err = s.repo.CreateRank(ctx, rank)
+	if err != nil {
+		return err
+	}
+
+	s.cache.forgetRank(rank.Name)
+
+	return s.audit(ctx, &AuditEvent{
+		Action:   AuditRankCreated,
+		ActorID:  actorID,
+		TargetID: rank.Name,
+		Reason:   fmt.Sprintf("level %d: %s", rank.Level, JoinCommands(rank.Commands)),
+	})
+}
+
+// UpdateRankCommands replaces a rank's command list.
+func (s *Service) UpdateRankCommands(ctx context.Context, rankName string, commands []string, actorID string) error {
+	err := validateRankCommands(commands)
+	if err != nil {
+		return err
+	}
+
+	actor, rank, err := s.managedRank(ctx, rankName, actorID)
+	if err != nil {
+		return err
+	}
+
+	// only newly added grants need delegating; an actor may always strip
+	// commands from a rank below them. Dropping a negation grants what it
+	// took away, so it counts as adding that command.
+	added := make([]string, 0, len(commands))
+
+	for _, cmd := range commands {
+		if !slices.Contains(rank.Commands, cmd) {
+			added = append(added, cmd)
+		}
+	}
+
+	for _, cmd := range rank.Commands {
+		negated, ok := strings.CutPrefix(cmd, negationPrefix)
+		if ok && !slices.Contains(commands, cmd) {
+			added = append(added, negated)
+		}
+	}
+
+	err = canDelegate(actor, added)
+	if err != nil {
+		return err
+	}
+
+	err = s.repo.UpdateRankCommands(ctx, rank.Name, commands)
+	if err != nil {
+		return err
+	}
+
+	s.cache.forgetRank(rank.Name)
+
+	return s.audit(ctx, &AuditEvent{
+		Action:   AuditRankUpdated,
+		ActorID:  actorID,
+		TargetID: rank.Name,
+		Reason:   "commands: " + JoinCommands(commands),
+	})
+}
+
+// SetRankLevel moves a rank within the hierarchy. Both the old and the new
+// level must be below the actor's.
+func (s *Service) SetRankLevel(ctx context.Context, rankName string, level int, actorID string) error {
+	actor, rank, err := s.managedRank(ctx, rankName, actorID)
+	if err != nil {
+		return err
+	}
+
+	err = canGrant(actor.Level, &Rank{Name: rank.Name, Level: level})
+	if err != nil {
+		return err
+	}
+
+	err = s.repo.UpdateRankLevel(ctx, rank.Name, level)
+	if err != nil {
+		return err
+	}
+
+	s.cache.forgetRank(rank.Name)
+
+	return s.audit(ctx, &AuditEvent{
+		Action:   AuditRankUpdated,
+		ActorID:  actorID,
+		TargetID: rank.Name,
+		Reason:   fmt.Sprintf("level %d -> %d", rank.Level, level),
+	})
+}
+
+// DeleteRank removes a rank. It refuses with ErrRankInUse while any active
+// user still holds it, so nobody is silently left without permissions.
+func (s *Service) DeleteRank(ctx context.Context, rankName, actorID string) error {
+	_, rank, err := s.managedRank(ctx, rankName, actorID)
+	if err != nil {
+		return err
+	}
+
+	holders, err := s.repo.CountUsersWithRank(ctx, rank.Name)
+	if err != nil {
+		return err
+	}
+
+	if holders > 0 {
+		return ErrRankInUse
+	}
+
+	err = s.repo.DeactivateRank(ctx, rank.Name)
+	if err != nil {
+		return err
+	}
+
+	s.cache.forgetRank(rank.Name)
+
+	return s.audit(ctx, &AuditEvent{
+		Action:   AuditRankDeleted,
+		ActorID:  actorID,
+		TargetID: rank.Name,
+	})
+}
+
+// managedRank loads a rank the actor is allowed to modify.
+func (s *Service) managedRank(ctx context.Context, rankName, actorID string) (*Rank, *Rank, error) {
+	err := ValidateRankName(rankName)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	actor, err := s.actorRank(ctx, actorID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	rank, err := s.repo.GetRank(ctx, rankName)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	err = canGrant(actor.Level, rank)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return actor, rank, nil
+}
+
+func validateRankCommands(commands []string) error {
+	for _, cmd := range commands {
+		err := ValidateCommandPattern(cmd)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -5,6 +5,8 @@ import (
 	"database/sql"
 	"errors"
 	"fmt"
+	"strings"
+	"time"
 )
 
 type Repository struct {
@@ -17,20 +19,23 @@ func NewRepository(db *sql.DB) *Repository {
 
 // user operations.
 func (r *Repository) GetUser(ctx context.Context, userID string) (*User, error) {
-	query := `
-			SELECT user_id, rank, registered_at, registered_by 
-			FROM users 
-			WHERE user_id = ? AND active = 1
-	`
+	user, err := r.GetUserRecord(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
 
-	var (
-		user         User
-		registeredBy sql.NullString
-	)
+	if !user.Active {
+		return nil, ErrUserNotFound
+	}
 
-	err := r.db.QueryRowContext(ctx, query, userID).Scan(
-		&user.ID, &user.Rank, &user.RegisteredAt, &registeredBy,
-	)
+	return user, nil
+}
+
+// GetUserRecord returns the user row even when it has been deactivated.
+func (r *Repository) GetUserRecord(ctx context.Context, userID string) (*User, error) {
+	query := `SELECT ` + userColumns + ` FROM users WHERE user_id = ?`
+
+	user, err := scanUser(r.db.QueryRowContext(ctx, query, userID))
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			return nil, ErrUserNotFound
@@ -39,18 +44,27 @@ func (r *Repository) GetUser(ctx context.Context, userID string) (*User, error)
 		return nil, fmt.Errorf("failed to get user: %w", err)
 	}
 
-	if registeredBy.Valid {
-		user.RegisteredBy = registeredBy.String
-	}
-
-	return &user, nil
+	return user, nil
 }
 
-func (r *Repository) CreateUser(ctx context.Context, userID, rank, registeredBy string) error {
-	query := `INSERT INTO users (user_id, rank, registered_by, active) 
-			  VALUES (?, ?, ?, 1)`
+// CreateUser registers a user, until expiresAt when it is not nil.
+func (r *Repository) CreateUser(ctx context.Context, userID, rank, registeredBy string, expiresAt *time.Time) error {
+	// a deactivated row with the same ID is reused, so re-registering
+	// someone starts from a clean record.
+	query := `INSERT INTO users (user_id, rank, registered_by, active, expires_at) 
+			  VALUES (?, ?, ?, 1, ?)
+			  ON CONFLICT(user_id) DO UPDATE SET
+			      rank = excluded.rank,
+			      registered_by = excluded.registered_by,
+			      registered_at = CURRENT_TIMESTAMP,
+			      active = 1,
+			      deactivated_at = NULL,
+			      deactivated_by = NULL,
+			      expires_at = excluded.expires_at,
+			      previous_rank = NULL,
+			      expiry_notified_at = NULL`
 
-	_, err := r.db.ExecContext(ctx, query, userID, rank, registeredBy)
+	_, err := r.db.ExecContext(ctx, query, userID, rank, registeredBy, nullTime(expiresAt))
 	if err != nil {
 		return fmt.Errorf("failed to create user: %w", err)
 	}
@@ -58,6 +72,53 @@ func (r *Repository) CreateUser(ctx context.Context, userID, rank, registeredBy
 	return nil
 }
 
+func (r *Repository) ListUsers(ctx context.Context) ([]*User, error) {
+	return r.listUsers(ctx, `active = 1 ORDER BY registered_at, user_id`)
+}
+
+// ListExpiredUsers returns active users whose grant ran out at or before now.
+func (r *Repository) ListExpiredUsers(ctx context.Context, now time.Time) ([]*User, error) {
+	return r.listUsers(ctx, `active = 1 AND expires_at <= ? ORDER BY expires_at`, now.UTC())
+}
+
+// ListUsersExpiringBefore returns active users whose grant runs out before
+// the given time and who have not been told yet.
+func (r *Repository) ListUsersExpiringBefore(ctx context.Context, before time.Time) ([]*User, error) {
+	return r.listUsers(ctx, `active = 1 AND expires_at <= ? AND expiry_notified_at IS NULL ORDER BY expires_at`, before.UTC())
+}
+
+func (r *Repository) listUsers(ctx context.Context, where string, args ...interface{}) (users []*User, err error) {
+	query := `SELECT ` + userColumns + ` FROM users WHERE ` + where
+
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list users: %w", err)
+	}
+
+	defer func() {
+		cerr := rows.Close()
+		if cerr != nil && err == nil {
+			err = fmt.Errorf("failed to close rows: %w", cerr)
+		}
+	}()
+
+	for rows.Next() {
+		user, scanErr := scanUser(rows)
+		if scanErr != nil {
+			return nil, fmt.Errorf("failed to scan user: %w", scanErr)
+		}
+
+		users = append(users, user)
+	}
+
+	err = rows.Err()
+	if err != nil {
+		return nil, fmt.Errorf("error iterating users: %w", err)
+	}
+
+	return users, nil
+}
+
 func (r *Repository) UserExists(ctx context.Context, userID string) (bool, error) {
 	query := `SELECT 1 FROM users WHERE user_id = ? AND active = 1`
 
@@ -75,17 +136,235 @@ func (r *Repository) UserExists(ctx context.Context, userID string) (bool, error
 	return true, nil
 }
 
+func (r *Repository) UpdateUserRank(ctx context.Context, userID, rank string) error {
+	query := `UPDATE users SET rank = ? WHERE user_id = ? AND active = 1`
+
+	result, err := r.db.ExecContext(ctx, query, rank, userID)
+	if err != nil {
+		return fmt.Errorf("failed to update user rank: %w", err)
+	}
+
+	return requireAffected(result, ErrUserNotFound)
+}
+
+// SetUserGrant replaces a user's rank together with its expiry and the rank
+// they return to afterwards. It also rearms the expiry notice.
+func (r *Repository) SetUserGrant(ctx context.Context, userID, rank, previousRank string, expiresAt *time.Time) error {
+	query := `UPDATE users
+			  SET rank = ?, previous_rank = ?, expires_at = ?, expiry_notified_at = NULL
+			  WHERE user_id = ? AND active = 1`
+
+	result, err := r.db.ExecContext(ctx, query, rank, nullString(previousRank), nullTime(expiresAt), userID)
+	if err != nil {
+		return fmt.Errorf("failed to set user grant: %w", err)
+	}
+
+	return requireAffected(result, ErrUserNotFound)
+}
+
+func (r *Repository) MarkExpiryNotified(ctx context.Context, userID string) error {
+	query := `UPDATE users SET expiry_notified_at = CURRENT_TIMESTAMP WHERE user_id = ?`
+
+	result, err := r.db.ExecContext(ctx, query, userID)
+	if err != nil {
+		return fmt.Errorf("failed to mark expiry notified: %w", err)
+	}
+
+	return requireAffected(result, ErrUserNotFound)
+}
+
+func (r *Repository) DeactivateUser(ctx context.Context, userID, deactivatedBy string) error {
+	query := `UPDATE users
+			  SET active = 0, deactivated_at = CURRENT_TIMESTAMP, deactivated_by = ?
+			  WHERE user_id = ? AND active = 1`
+
+	result, err := r.db.ExecContext(ctx, query, deactivatedBy, userID)
+	if err != nil {
+		return fmt.Errorf("failed to deactivate user: %w", err)
+	}
+
+	return requireAffected(result, ErrUserNotFound)
+}
+
+func (r *Repository) ReactivateUser(ctx context.Context, userID string) error {
+	query := `UPDATE users
+			  SET active = 1, deactivated_at = NULL, deactivated_by = NULL
+			  WHERE user_id = ? AND active = 0`
+
+	result, err := r.db.ExecContext(ctx, query, userID)
+	if err != nil {
+		return fmt.Errorf("failed to reactivate user: %w", err)
+	}
+
+	return requireAffected(result, ErrUserNotFound)
+}
+
+// identity migration.
+// ListStoredUserIDs returns every distinct user ID in users, bans and access
+// requests, active or not.
+func (r *Repository) ListStoredUserIDs(ctx context.Context) (ids []string, err error) {
+	query := `SELECT user_id FROM users
+			  UNION SELECT user_id FROM user_bans
+			  UNION SELECT user_id FROM access_requests`
+
+	rows, err := r.db.QueryContext(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list user ids: %w", err)
+	}
+
+	defer func() {
+		cerr := rows.Close()
+		if cerr != nil && err == nil {
+			err = fmt.Errorf("failed to close rows: %w", cerr)
+		}
+	}()
+
+	for rows.Next() {
+		var id string
+
+		scanErr := rows.Scan(&id)
+		if scanErr != nil {
+			return nil, fmt.Errorf("failed to scan user id: %w", scanErr)
+		}
+
+		ids = append(ids, id)
+	}
+
+	err = rows.Err()
+	if err != nil {
+		return nil, fmt.Errorf("error iterating user ids: %w", err)
+	}
+
+	return ids, nil
+}
+
+// RenameUserID moves a user's rows from one ID to another in one
+// transaction. Where the new ID already has a user or ban, that row wins and
+// the old one is dropped. It returns the rank of a dropped user row, or ""
+// when nothing was dropped.
+func (r *Repository) RenameUserID(ctx context.Context, from, to string) (dropped string, err error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return "", fmt.Errorf("failed to begin user id rename: %w", err)
+	}
+
+	defer func() {
+		if err != nil {
+			rerr := tx.Rollback()
+			if rerr != nil {
+				err = fmt.Errorf("%w (rollback: %w)", err, rerr)
+			}
+		}
+	}()
+
+	statements := []string{
+		`UPDATE OR IGNORE users SET user_id = ? WHERE user_id = ?`,
+		`UPDATE OR IGNORE user_bans SET user_id = ? WHERE user_id = ?`,
+		`UPDATE access_requests SET user_id = ? WHERE user_id = ?`,
+	}
+
+	for _, query := range statements {
+		_, err = tx.ExecContext(ctx, query, to, from)
+		if err != nil {
+			return "", fmt.Errorf("failed to rename user id: %w", err)
+		}
+	}
+
+	// whatever could not be renamed duplicates a row the new ID already has.
+	err = tx.QueryRowContext(ctx, `DELETE FROM users WHERE user_id = ? RETURNING rank`, from).Scan(&dropped)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return "", fmt.Errorf("failed to drop duplicate user: %w", err)
+	}
+
+	_, err = tx.ExecContext(ctx, `DELETE FROM user_bans WHERE user_id = ?`, from)
+	if err != nil {
+		return "", fmt.Errorf("failed to drop duplicate ban: %w", err)
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return "", fmt.Errorf("failed to commit user id rename: %w", err)
+	}
+
+	return dropped, nil
+}
+
+// ban operations.
+func (r *Repository) GetActiveBan(ctx context.Context, userID string, now time.Time) (*Ban, error) {
+	query := `SELECT user_id, reason, banned_by, banned_at, expires_at
+			  FROM user_bans
+			  WHERE user_id = ? AND (expires_at IS NULL OR expires_at > ?)`
+
+	var (
+		ban       Ban
+		reason    sql.NullString
+		expiresAt sql.NullTime
+	)
+
+	err := r.db.QueryRowContext(ctx, query, userID, now.UTC()).Scan(
+		&ban.UserID, &reason, &ban.BannedBy, &ban.BannedAt, &expiresAt,
+	)
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrNotBanned
+		}
+
+		return nil, fmt.Errorf("failed to get ban: %w", err)
+	}
+
+	ban.Reason = reason.String
+
+	if expiresAt.Valid {
+		ban.ExpiresAt = &expiresAt.Time
+	}
+
+	return &ban, nil
+}
+
+// SaveBan creates a ban or replaces the existing one for the same user.
+func (r *Repository) SaveBan(ctx context.Context, ban *Ban) error {
+	query := `INSERT INTO user_bans (user_id, reason, banned_by, banned_at, expires_at)
+			  VALUES (?, ?, ?, CURRENT_TIMESTAMP, ?)
+			  ON CONFLICT(user_id) DO UPDATE SET
+			      reason = excluded.reason,
+			      banned_by = excluded.banned_by,
+			      banned_at = excluded.banned_at,
+			      expires_at = excluded.expires_at`
+
+	var expiresAt interface{}
+	if ban.ExpiresAt != nil {
+		expiresAt = ban.ExpiresAt.UTC()
+	}
+
+	_, err := r.db.ExecContext(ctx, query, ban.UserID, ban.Reason, ban.BannedBy, expiresAt)
+	if err != nil {
+		return fmt.Errorf("failed to save ban: %w", err)
+	}
+
+	return nil
+}
+
+func (r *Repository) DeleteBan(ctx context.Context, userID string) error {
+	result, err := r.db.ExecContext(ctx, `DELETE FROM user_bans WHERE user_id = ?`, userID)
+	if err != nil {
+		return fmt.Errorf("failed to delete ban: %w", err)
+	}
+
+	return requireAffected(result, ErrNotBanned)
+}
+
 // rank operations.
 func (r *Repository) GetRank(ctx context.Context, name string) (*Rank, error) {
-	query := `SELECT name, level, commands FROM ranks WHERE name = ? AND active = 1`
+	query := `SELECT name, level, commands, description FROM ranks WHERE name = ? AND active = 1`
 
 	var (
 		rank        Rank
 		commandsRaw string
+		description sql.NullString
 	)
 
 	err := r.db.QueryRowContext(ctx, query, name).Scan(
-		&rank.Name, &rank.Level, &commandsRaw,
+		&rank.Name, &rank.Level, &commandsRaw, &description,
 	)
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
@@ -96,12 +375,13 @@ func (r *Repository) GetRank(ctx context.Context, name string) (*Rank, error) {
 	}
 
 	rank.Commands = ParseCommands(commandsRaw)
+	rank.Description = description.String
 
 	return &rank, nil
 }
 
 func (r *Repository) ListRanks(ctx context.Context) (ranks []*Rank, err error) {
-	query := `SELECT name, level, commands FROM ranks WHERE active = 1 ORDER BY level`
+	query := `SELECT name, level, commands, description FROM ranks WHERE active = 1 ORDER BY level`
 
 	rows, err := r.db.QueryContext(ctx, query)
 	if err != nil {
@@ -119,14 +399,16 @@ func (r *Repository) ListRanks(ctx context.Context) (ranks []*Rank, err error) {
 		var (
 			rank        Rank
 			commandsRaw string
+			description sql.NullString
 		)
 
-		scanErr := rows.Scan(&rank.Name, &rank.Level, &commandsRaw)
+		scanErr := rows.Scan(&rank.Name, &rank.Level, &commandsRaw, &description)
 		if scanErr != nil {
 			return nil, fmt.Errorf("failed to scan rank: %w", scanErr)
 		}
 
 		rank.Commands = ParseCommands(commandsRaw)
+		rank.Description = description.String
 		ranks = append(ranks, &rank)
 	}
 
@@ -138,6 +420,73 @@ func (r *Repository) ListRanks(ctx context.Context) (ranks []*Rank, err error) {
 	return ranks, nil
 }
 
+// CreateRank inserts a rank, reusing the row of a previously deleted rank
+// with the same name.
+func (r *Repository) CreateRank(ctx context.Context, rank *Rank) error {
+	query := `INSERT INTO ranks (name, level, commands, description, active)
+			  VALUES (?, ?, ?, ?, 1)
+			  ON CONFLICT(name) DO UPDATE SET
+			      level = excluded.level,
+			      commands = excluded.commands,
+			      description = excluded.description,
+			      created_at = CURRENT_TIMESTAMP,
+			      active = 1
+			  WHERE ranks.active = 0`
+
+	result, err := r.db.ExecContext(ctx, query, rank.Name, rank.Level, JoinCommands(rank.Commands), rank.Description)
+	if err != nil {
+		return fmt.Errorf("failed to create rank: %w", err)
+	}
+
+	return requireAffected(result, ErrRankExists)
+}
+
+func (r *Repository) UpdateRankCommands(ctx context.Context, name string, commands []string) error {
+	query := `UPDATE ranks SET commands = ? WHERE name = ? AND active = 1`
+
+	result, err := r.db.ExecContext(ctx, query, JoinCommands(commands), name)
+	if err != nil {
+		return fmt.Errorf("failed to update rank commands: %w", err)
+	}
+
+	return requireAffected(result, ErrRankNotFound)
+}
+
+func (r *Repository) UpdateRankLevel(ctx context.Context, name string, level int) error {
+	query := `UPDATE ranks SET level = ? WHERE name = ? AND active = 1`
+
+	result, err := r.db.ExecContext(ctx, query, level, name)
+	if err != nil {
+		return fmt.Errorf("failed to update rank level: %w", err)
+	}
+
+	return requireAffected(result, ErrRankNotFound)
+}
+
+func (r *Repository) DeactivateRank(ctx context.Context, name string) error {
+	query := `UPDATE ranks SET active = 0 WHERE name = ? AND active = 1`
+
+	result, err := r.db.ExecContext(ctx, query, name)
+	if err != nil {
+		return fmt.Errorf("failed to delete rank: %w", err)
+	}
+
+	return requireAffected(result, ErrRankNotFound)
+}
+
+func (r *Repository) CountUsersWithRank(ctx context.Context, name string) (int, error) {
+	query := `SELECT COUNT(*) FROM users WHERE rank = ? AND active = 1`
+
+	var count int
+
+	err := r.db.QueryRowContext(ctx, query, name).Scan(&count)
+	if err != nil {
+		return 0, fmt.Errorf("failed to count users with rank: %w", err)
+	}
+
+	return count, nil
+}
+
 // group operations.
 func (r *Repository) GetGroup(ctx context.Context, groupID string) (*Group, error) {
 	query := `SELECT group_id, registered_at, registered_by 
@@ -159,9 +508,50 @@ func (r *Repository) GetGroup(ctx context.Context, groupID string) (*Group, erro
 	return &group, nil
 }
 
+func (r *Repository) ListGroups(ctx context.Context) (groups []*Group, err error) {
+	query := `SELECT group_id, registered_at, registered_by
+			  FROM registered_groups WHERE active = 1 ORDER BY registered_at, group_id`
+
+	rows, err := r.db.QueryContext(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list groups: %w", err)
+	}
+
+	defer func() {
+		cerr := rows.Close()
+		if cerr != nil && err == nil {
+			err = fmt.Errorf("failed to close rows: %w", cerr)
+		}
+	}()
+
+	for rows.Next() {
+		var group Group
+
+		scanErr := rows.Scan(&group.ID, &group.RegisteredAt, &group.RegisteredBy)
+		if scanErr != nil {
+			return nil, fmt.Errorf("failed to scan group: %w", scanErr)
+		}
+
+		groups = append(groups, &group)
+	}
+
+	err = rows.Err()
+	if err != nil {
+		return nil, fmt.Errorf("error iterating groups: %w", err)
+	}
+
+	return groups, nil
+}
+
 func (r *Repository) CreateGroup(ctx context.Context, groupID, registeredBy string) error {
 	query := `INSERT INTO registered_groups (group_id, registered_by, active) 
-			  VALUES (?, ?, 1)`
+			  VALUES (?, ?, 1)
+			  ON CONFLICT(group_id) DO UPDATE SET
+			      registered_by = excluded.registered_by,
+			      registered_at = CURRENT_TIMESTAMP,
+			      active = 1,
+			      deactivated_at = NULL,
+			      deactivated_by = NULL`
 
 	_, err := r.db.ExecContext(ctx, query, groupID, registeredBy)
 	if err != nil {
@@ -187,3 +577,551 @@ func (r *Repository) GroupExists(ctx context.Context, groupID string) (bool, err
 
 	return true, nil
 }
+
+func (r *Repository) DeactivateGroup(ctx context.Context, groupID, deactivatedBy string) error {
+	query := `UPDATE registered_groups
+			  SET active = 0, deactivated_at = CURRENT_TIMESTAMP, deactivated_by = ?
+			  WHERE group_id = ? AND active = 1`
+
+	result, err := r.db.ExecContext(ctx, query, deactivatedBy, groupID)
+	if err != nil {
+		return fmt.Errorf("failed to deactivate group: %w", err)
+	}
+
+	return requireAffected(result, ErrGroupNotRegistered)
+}
+
+// group policy operations.
+func (r *Repository) GetGroupPolicy(ctx context.Context, groupID, command string) (*GroupPolicy, error) {
+	query := `SELECT group_id, command, effect, min_rank, updated_at, updated_by
+			  FROM group_policies WHERE group_id = ? AND command = ?`
+
+	policy, err := scanGroupPolicy(r.db.QueryRowContext(ctx, query, groupID, command))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrPolicyNotFound
+		}
+
+		return nil, fmt.Errorf("failed to get group policy: %w", err)
+	}
+
+	return policy, nil
+}
+
+func (r *Repository) ListGroupPolicies(ctx context.Context, groupID string) (policies []*GroupPolicy, err error) {
+	query := `SELECT group_id, command, effect, min_rank, updated_at, updated_by
+			  FROM group_policies WHERE group_id = ? ORDER BY command`
+
+	rows, err := r.db.QueryContext(ctx, query, groupID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list group policies: %w", err)
+	}
+
+	defer func() {
+		cerr := rows.Close()
+		if cerr != nil && err == nil {
+			err = fmt.Errorf("failed to close rows: %w", cerr)
+		}
+	}()
+
+	for rows.Next() {
+		policy, scanErr := scanGroupPolicy(rows)
+		if scanErr != nil {
+			return nil, fmt.Errorf("failed to scan group policy: %w", scanErr)
+		}
+
+		policies = append(policies, policy)
+	}
+
+	err = rows.Err()
+	if err != nil {
+		return nil, fmt.Errorf("error iterating group policies: %w", err)
+	}
+
+	return policies, nil
+}
+
+func (r *Repository) SaveGroupPolicy(ctx context.Context, policy *GroupPolicy) error {
+	query := `INSERT INTO group_policies (group_id, command, effect, min_rank, updated_by)
+			  VALUES (?, ?, ?, ?, ?)
+			  ON CONFLICT(group_id, command) DO UPDATE SET
+			      effect = excluded.effect,
+			      min_rank = excluded.min_rank,
+			      updated_by = excluded.updated_by,
+			      updated_at = CURRENT_TIMESTAMP`
+
+	var minRank interface{}
+	if policy.MinRank != "" {
+		minRank = policy.MinRank
+	}
+
+	_, err := r.db.ExecContext(ctx, query, policy.GroupID, policy.Command, string(policy.Effect), minRank, policy.UpdatedBy)
+	if err != nil {
+		return fmt.Errorf("failed to save group policy: %w", err)
+	}
+
+	return nil
+}
+
+func (r *Repository) DeleteGroupPolicy(ctx context.Context, groupID, command string) error {
+	result, err := r.db.ExecContext(ctx, `DELETE FROM group_policies WHERE group_id = ? AND command = ?`, groupID, command)
+	if err != nil {
+		return fmt.Errorf("failed to delete group policy: %w", err)
+	}
+
+	return requireAffected(result, ErrPolicyNotFound)
+}
+
+// invite operations.
+const inviteColumns = `code, rank, max_uses, uses, expires_at, created_by, created_at, revoked_at IS NOT NULL`
+
+func (r *Repository) CreateInvite(ctx context.Context, invite *Invite) error {
+	query := `INSERT INTO invite_codes (code, rank, max_uses, expires_at, created_by)
+			  VALUES (?, ?, ?, ?, ?)`
+
+	_, err := r.db.ExecContext(ctx, query,
+		invite.Code, invite.Rank, invite.MaxUses, nullTime(invite.ExpiresAt), invite.CreatedBy)
+	if err != nil {
+		return fmt.Errorf("failed to create invite: %w", err)
+	}
+
+	return nil
+}
+
+func (r *Repository) GetInvite(ctx context.Context, code string) (*Invite, error) {
+	query := `SELECT ` + inviteColumns + ` FROM invite_codes WHERE code = ?`
+
+	invite, err := scanInvite(r.db.QueryRowContext(ctx, query, code))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrInviteNotFound
+		}
+
+		return nil, fmt.Errorf("failed to get invite: %w", err)
+	}
+
+	return invite, nil
+}
+
+// ListInvites returns invites that are neither revoked nor expired, newest
+// first.
+func (r *Repository) ListInvites(ctx context.Context, now time.Time) (invites []*Invite, err error) {
+	query := `SELECT ` + inviteColumns + ` FROM invite_codes
+			  WHERE revoked_at IS NULL AND (expires_at IS NULL OR expires_at > ?)
+			  ORDER BY created_at DESC, code`
+
+	rows, err := r.db.QueryContext(ctx, query, now.UTC())
+	if err != nil {
+		return nil, fmt.Errorf("failed to list invites: %w", err)
+	}
+
+	defer func() {
+		cerr := rows.Close()
+		if cerr != nil && err == nil {
+			err = fmt.Errorf("failed to close rows: %w", cerr)
+		}
+	}()
+
+	for rows.Next() {
+		invite, scanErr := scanInvite(rows)
+		if scanErr != nil {
+			return nil, fmt.Errorf("failed to scan invite: %w", scanErr)
+		}
+
+		invites = append(invites, invite)
+	}
+
+	err = rows.Err()
+	if err != nil {
+		return nil, fmt.Errorf("error iterating invites: %w", err)
+	}
+
+	return invites, nil
+}
+
+// UseInvite takes one use of a usable invite. The check and the increment
+// happen in one statement, so concurrent redemptions cannot exceed MaxUses.
+func (r *Repository) UseInvite(ctx context.Context, code string, now time.Time) error {
+	query := `UPDATE invite_codes SET uses = uses + 1
+			  WHERE code = ? AND revoked_at IS NULL AND uses < max_uses
+			    AND (expires_at IS NULL OR expires_at > ?)`
+
+	result, err := r.db.ExecContext(ctx, query, code, now.UTC())
+	if err != nil {
+		return fmt.Errorf("failed to use invite: %w", err)
+	}
+
+	return requireAffected(result, ErrInviteInvalid)
+}
+
+// ReleaseInvite gives back a use taken by UseInvite.
+func (r *Repository) ReleaseInvite(ctx context.Context, code string) error {
+	query := `UPDATE invite_codes SET uses = uses - 1 WHERE code = ? AND uses > 0`
+
+	_, err := r.db.ExecContext(ctx, query, code)
+	if err != nil {
+		return fmt.Errorf("failed to release invite: %w", err)
+	}
+
+	return nil
+}
+
+func (r *Repository) RevokeInvite(ctx context.Context, code string) error {
+	query := `UPDATE invite_codes SET revoked_at = CURRENT_TIMESTAMP
+			  WHERE code = ? AND revoked_at IS NULL`
+
+	result, err := r.db.ExecContext(ctx, query, code)
+	if err != nil {
+		return fmt.Errorf("failed to revoke invite: %w", err)
+	}
+
+	return requireAffected(result, ErrInviteNotFound)
+}
+
+// access request operations.
+const requestColumns = `id, user_id, COALESCE(reason, ''), status, COALESCE(rank, ''), created_at, expires_at,
+			  COALESCE(decided_by, ''), decided_at`
+
+func (r *Repository) CreateAccessRequest(ctx context.Context, request *AccessRequest) error {
+	query := `INSERT INTO access_requests (user_id, reason, created_at, expires_at)
+			  VALUES (?, ?, ?, ?)`
+
+	result, err := r.db.ExecContext(ctx, query,
+		request.UserID, nullString(request.Reason), request.CreatedAt.UTC(), request.ExpiresAt.UTC())
+	if err != nil {
+		return fmt.Errorf("failed to create access request: %w", err)
+	}
+
+	request.ID, err = result.LastInsertId()
+	if err != nil {
+		return fmt.Errorf("failed to read access request id: %w", err)
+	}
+
+	return nil
+}
+
+func (r *Repository) GetAccessRequest(ctx context.Context, id int64) (*AccessRequest, error) {
+	query := `SELECT ` + requestColumns + ` FROM access_requests WHERE id = ?`
+
+	request, err := scanAccessRequest(r.db.QueryRowContext(ctx, query, id))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrRequestNotFound
+		}
+
+		return nil, fmt.Errorf("failed to get access request: %w", err)
+	}
+
+	return request, nil
+}
+
+// LatestAccessRequest returns the user's most recent request, whatever its
+// status.
+func (r *Repository) LatestAccessRequest(ctx context.Context, userID string) (*AccessRequest, error) {
+	query := `SELECT ` + requestColumns + ` FROM access_requests
+			  WHERE user_id = ? ORDER BY created_at DESC, id DESC LIMIT 1`
+
+	request, err := scanAccessRequest(r.db.QueryRowContext(ctx, query, userID))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return nil, ErrRequestNotFound
+		}
+
+		return nil, fmt.Errorf("failed to get latest access request: %w", err)
+	}
+
+	return request, nil
+}
+
+// ListPendingAccessRequests returns undecided requests that have not expired,
+// oldest first.
+func (r *Repository) ListPendingAccessRequests(ctx context.Context, now time.Time) (requests []*AccessRequest, err error) {
+	query := `SELECT ` + requestColumns + ` FROM access_requests
+			  WHERE status = ? AND expires_at > ?
+			  ORDER BY created_at, id`
+
+	rows, err := r.db.QueryContext(ctx, query, string(RequestPending), now.UTC())
+	if err != nil {
+		return nil, fmt.Errorf("failed to list access requests: %w", err)
+	}
+
+	defer func() {
+		cerr := rows.Close()
+		if cerr != nil && err == nil {
+			err = fmt.Errorf("failed to close rows: %w", cerr)
+		}
+	}()
+
+	for rows.Next() {
+		request, scanErr := scanAccessRequest(rows)
+		if scanErr != nil {
+			return nil, fmt.Errorf("failed to scan access request: %w", scanErr)
+		}
+
+		requests = append(requests, request)
+	}
+
+	err = rows.Err()
+	if err != nil {
+		return nil, fmt.Errorf("error iterating access requests: %w", err)
+	}
+
+	return requests, nil
+}
+
+// DecideAccessRequest closes a pending request that has not expired. The
+// check and the update happen in one statement, so two admins deciding at
+// once cannot both succeed.
+func (r *Repository) DecideAccessRequest(
+	ctx context.Context, id int64, status RequestStatus, rank, decidedBy string, now time.Time,
+) error {
+	query := `UPDATE access_requests SET status = ?, rank = ?, decided_by = ?, decided_at = ?
+			  WHERE id = ? AND status = ? AND expires_at > ?`
+
+	result, err := r.db.ExecContext(ctx, query,
+		string(status), nullString(rank), decidedBy, now.UTC(), id, string(RequestPending), now.UTC())
+	if err != nil {
+		return fmt.Errorf("failed to decide access request: %w", err)
+	}
+
+	return requireAffected(result, ErrRequestClosed)
+}
+
+// ReopenAccessRequest undoes DecideAccessRequest.
+func (r *Repository) ReopenAccessRequest(ctx context.Context, id int64) error {
+	query := `UPDATE access_requests SET status = ?, rank = NULL, decided_by = NULL, decided_at = NULL
+			  WHERE id = ?`
+
+	_, err := r.db.ExecContext(ctx, query, string(RequestPending), id)
+	if err != nil {
+		return fmt.Errorf("failed to reopen access request: %w", err)
+	}
+
+	return nil
+}
+
+// audit operations.
+func (r *Repository) InsertAuditEvent(ctx context.Context, event *AuditEvent) error {
+	query := `INSERT INTO audit_events (action, actor_id, target_id, command, group_id, reason)
+			  VALUES (?, ?, ?, ?, ?, ?)`
+
+	_, err := r.db.ExecContext(ctx, query,
+		string(event.Action), nullString(event.ActorID), nullString(event.TargetID),
+		nullString(event.Command), nullString(event.GroupID), nullString(event.Reason),
+	)
+	if err != nil {
+		return fmt.Errorf("failed to insert audit event: %w", err)
+	}
+
+	return nil
+}
+
+// DeleteAuditEventsBefore removes events recorded before the given time and
+// returns how many were removed.
+func (r *Repository) DeleteAuditEventsBefore(ctx context.Context, before time.Time) (int64, error) {
+	// created_at is written by CURRENT_TIMESTAMP, so compare in its format.
+	result, err := r.db.ExecContext(ctx, `DELETE FROM audit_events WHERE created_at < ?`,
+		before.UTC().Format(time.DateTime))
+	if err != nil {
+		return 0, fmt.Errorf("failed to delete audit events: %w", err)
+	}
+
+	deleted, err := result.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("failed to delete audit events: %w", err)
+	}
+
+	return deleted, nil
+}
+
+// ListAuditEvents returns the newest events matching the filter first.
+func (r *Repository) ListAuditEvents(ctx context.Context, filter AuditFilter) (events []*AuditEvent, err error) {
+	var (
+		conditions []string
+		args       []interface{}
+	)
+
+	if filter.UserID != "" {
+		conditions = append(conditions, "(actor_id = ? OR target_id = ?)")
+		args = append(args, filter.UserID, filter.UserID)
+	}
+
+	if filter.GroupID != "" {
+		conditions = append(conditions, "(group_id = ? OR target_id = ?)")
+		args = append(args, filter.GroupID, filter.GroupID)
+	}
+
+	query := `SELECT id, action, actor_id, target_id, command, group_id, reason, created_at
+			  FROM audit_events`
+
+	if len(conditions) > 0 {
+		query += " WHERE " + strings.Join(conditions, " AND ")
+	}
+
+	query += " ORDER BY id DESC LIMIT ?"
+	args = append(args, filter.Limit)
+
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list audit events: %w", err)
+	}
+
+	defer func() {
+		cerr := rows.Close()
+		if cerr != nil && err == nil {
+			err = fmt.Errorf("failed to close rows: %w", cerr)
+		}
+	}()
+
+	for rows.Next() {
+		event, scanErr := scanAuditEvent(rows)
+		if scanErr != nil {
+			return nil, fmt.Errorf("failed to scan audit event: %w", scanErr)
+		}
+
+		events = append(events, event)
+	}
+
+	err = rows.Err()
+	if err != nil {
+		return nil, fmt.Errorf("error iterating audit events: %w", err)
+	}
+
+	return events, nil
+}
+
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+const userColumns = `user_id, rank, registered_at, registered_by, active, expires_at, previous_rank`
+
+func scanUser(row rowScanner) (*User, error) {
+	var (
+		user                       User
+		registeredBy, previousRank sql.NullString
+		expiresAt                  sql.NullTime
+	)
+
+	err := row.Scan(&user.ID, &user.Rank, &user.RegisteredAt, &registeredBy, &user.Active, &expiresAt, &previousRank)
+	if err != nil {
+		return nil, err
+	}
+
+	user.RegisteredBy = registeredBy.String
+	user.PreviousRank = previousRank.String
+
+	if expiresAt.Valid {
+		user.ExpiresAt = &expiresAt.Time
+	}
+
+	return &user, nil
+}
+
+func scanGroupPolicy(row rowScanner) (*GroupPolicy, error) {
+	var (
+		policy  GroupPolicy
+		effect  string
+		minRank sql.NullString
+	)
+
+	err := row.Scan(&policy.GroupID, &policy.Command, &effect, &minRank, &policy.UpdatedAt, &policy.UpdatedBy)
+	if err != nil {
+		return nil, err
+	}
+
+	policy.Effect = PolicyEffect(effect)
+	policy.MinRank = minRank.String
+
+	return &policy, nil
+}
+
+func scanAuditEvent(row rowScanner) (*AuditEvent, error) {
+	var (
+		event                                     AuditEvent
+		action                                    string
+		actorID, targetID, command, group, reason sql.NullString
+	)
+
+	err := row.Scan(&event.ID, &action, &actorID, &targetID, &command, &group, &reason, &event.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	event.Action = AuditAction(action)
+	event.ActorID = actorID.String
+	event.TargetID = targetID.String
+	event.Command = command.String
+	event.GroupID = group.String
+	event.Reason = reason.String
+
+	return &event, nil
+}
+
+// nullString stores empty strings as NULL.
+func scanInvite(row rowScanner) (*Invite, error) {
+	var (
+		invite    Invite
+		expiresAt sql.NullTime
+	)
+
+	err := row.Scan(&invite.Code, &invite.Rank, &invite.MaxUses, &invite.Uses, &expiresAt,
+		&invite.CreatedBy, &invite.CreatedAt, &invite.Revoked)
+	if err != nil {
+		return nil, err
+	}
+
+	if expiresAt.Valid {
+		invite.ExpiresAt = &expiresAt.Time
+	}
+
+	return &invite, nil
+}
+
+func scanAccessRequest(row rowScanner) (*AccessRequest, error) {
+	var (
+		request   AccessRequest
+		status    string
+		decidedAt sql.NullTime
+	)
+
+	err := row.Scan(&request.ID, &request.UserID, &request.Reason, &status, &request.Rank,
+		&request.CreatedAt, &request.ExpiresAt, &request.DecidedBy, &decidedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	request.Status = RequestStatus(status)
+
+	if decidedAt.Valid {
+		request.DecidedAt = &decidedAt.Time
+	}
+
+	return &request, nil
+}
+
+func nullString(value string) sql.NullString {
+	return sql.NullString{String: value, Valid: value != ""}
+}
+
+func nullTime(value *time.Time) sql.NullTime {
+	if value == nil {
+		return sql.NullTime{}
+	}
+
+	return sql.NullTime{Time: value.UTC(), Valid: true}
+}
+
+// requireAffected maps "no row matched" to the caller's not-found error.
+func requireAffected(result sql.Result, notFound error) error {
+	affected, err := result.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to read affected rows: %w", err)
+	}
+
+	if affected == 0 {
+		return notFound
+	}
+
+	return nil
+}
@@ -0,0 +1,226 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+	"unicode/utf8"
+)
+
+const (
+	DefaultRequestTTL      = 72 * time.Hour
+	DefaultRequestCooldown = 24 * time.Hour
+
+	// MaxRequestReasonLength bounds the reason given with a request, in
+	// characters.
+	MaxRequestReasonLength = 200
+)
+
+// SetRequestPolicy sets how long access requests stay open (ttl) and how long
+// a user has to wait after one request before filing the next (cooldown).
+func (s *Service) SetRequestPolicy(ttl, cooldown time.Duration) error {
+	if ttl <= 0 || cooldown < 0 {
+		return fmt.Errorf("%w: request ttl must be positive and cooldown non-negative", ErrInvalidInput)
+	}
+
+	s.requestTTL = ttl
+	s.requestCooldown = cooldown
+
+	return nil
+}
+
+// RequestAccess files a request from an unregistered user. A user can have
+// only one open request (ErrRequestPending) and must wait the cooldown after
+// their last request before filing another (ErrRequestTooSoon).
+func (s *Service) RequestAccess(ctx context.Context, userID, reason string) (*AccessRequest, error) {
+	userID = s.canonicalID(ctx, userID)
+
+	if userID == "" {
+		return nil, ErrInvalidInput
+	}
+
+	if utf8.RuneCountInString(reason) > MaxRequestReasonLength {
+		return nil, fmt.Errorf("%w: reason longer than %d characters", ErrInvalidInput, MaxRequestReasonLength)
+	}
+
+	exists, err := s.repo.UserExists(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	if exists {
+		return nil, ErrUserExists
+	}
+
+	now := time.Now()
+
+	err = s.checkRequestAllowed(ctx, userID, now)
+	if err != nil {
+		return nil, err
+	}
+
+	request := &AccessRequest{
+		UserID:    userID,
+		Reason:    reason,
+		Status:    RequestPending,
+		CreatedAt: now,
+		ExpiresAt: now.Add(s.requestTTL),
+	}
+
+	err = s.repo.CreateAccessRequest(ctx, request)
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.audit(ctx, &AuditEvent{
+		Action:   AuditAccessRequested,
+		ActorID:  userID,
+		TargetID: requestTarget(request.ID),
+		Reason:   reason,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return request, nil
+}
+
+func (s *Service) checkRequestAllowed(ctx context.Context, userID string, now time.Time) error {
+	last, err := s.repo.LatestAccessRequest(ctx, userID)
+
+	switch {
+	case errors.Is(err, ErrRequestNotFound):
+		return nil
+	case err != nil:
+		return err
+	case last.Open(now):
+		return fmt.Errorf("%w: #%d", ErrRequestPending, last.ID)
+	case now.Sub(last.CreatedAt) < s.requestCooldown:
+		return fmt.Errorf("%w: try again after %s", ErrRequestTooSoon,
+			last.CreatedAt.Add(s.requestCooldown).UTC().Format(time.DateTime))
+	default:
+		return nil
+	}
+}
+
+// ApproveRequest registers the requester with rankName as actorID, subject to
+// the usual hierarchy checks. Requests that were already decided or expired
+// fail with ErrRequestClosed.
+func (s *Service) ApproveRequest(ctx context.Context, id int64, rankName, actorID string) (*AccessRequest, error) {
+	request, err := s.repo.GetAccessRequest(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+
+	// claim the request first so a concurrent decision cannot register the
+	// user twice, and give it back if the registration fails.
+	err = s.repo.DecideAccessRequest(ctx, id, RequestApproved, rankName, actorID, now)
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.RegisterUser(ctx, request.UserID, rankName, nil, actorID)
+	if err != nil {
+		reopenErr := s.repo.ReopenAccessRequest(ctx, id)
+		if reopenErr != nil {
+			return nil, fmt.Errorf("%w (reopening request: %w)", err, reopenErr)
+		}
+
+		return nil, err
+	}
+
+	request.Status = RequestApproved
+	request.Rank = rankName
+	request.DecidedBy = actorID
+	request.DecidedAt = &now
+
+	err = s.audit(ctx, &AuditEvent{
+		Action:   AuditAccessApproved,
+		ActorID:  actorID,
+		TargetID: request.UserID,
+		Reason:   requestTarget(id) + ", rank " + rankName,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return request, nil
+}
+
+// DenyRequest closes a pending request without registering anyone.
+func (s *Service) DenyRequest(ctx context.Context, id int64, actorID string) (*AccessRequest, error) {
+	request, err := s.repo.GetAccessRequest(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+
+	err = s.repo.DecideAccessRequest(ctx, id, RequestDenied, "", actorID, now)
+	if err != nil {
+		return nil, err
+	}
+
+	request.Status = RequestDenied
+	request.DecidedBy = actorID
+	request.DecidedAt = &now
+
+	err = s.audit(ctx, &AuditEvent{
+		Action:   AuditAccessDenied,
+		ActorID:  actorID,
+		TargetID: request.UserID,
+		Reason:   requestTarget(id),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return request, nil
+}
+
+// ListPendingRequests returns the requests still awaiting a decision, oldest
+// first.
+func (s *Service) ListPendingRequests(ctx context.Context) ([]*AccessRequest, error) {
+	return s.repo.ListPendingAccessRequests(ctx, time.Now())
+}
+
+// RequestReviewers returns the active users holding rankName or a rank above
+// it, the people to tell about new requests.
+func (s *Service) RequestReviewers(ctx context.Context, rankName string) ([]*User, error) {
+	minRank, err := s.repo.GetRank(ctx, rankName)
+	if err != nil {
+		return nil, err
+	}
+
+	users, err := s.repo.ListUsers(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var reviewers []*User
+
+	for _, user := range users {
+		rank, rankErr := s.userRank(ctx, user.ID)
+		if errors.Is(rankErr, ErrUserNotFound) || errors.Is(rankErr, ErrRankNotFound) {
+			continue
+		}
+
+		if rankErr != nil {
+			return nil, rankErr
+		}
+
+		if rank.Level <= minRank.Level {
+			reviewers = append(reviewers, user)
+		}
+	}
+
+	return reviewers, nil
+}
+
+func requestTarget(id int64) string {
+	return "request #" + strconv.FormatInt(id, 10)
+}
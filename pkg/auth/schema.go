@@ -3,62 +3,263 @@ package auth
 import (
 	"context"
 	"database/sql"
+	"database/sql/driver"
+	"embed"
 	"fmt"
+	"io/fs"
+	"maps"
+	"path"
+	"slices"
+	"strconv"
+	"strings"
 )
 
-const schema = `
--- Users table
-CREATE TABLE IF NOT EXISTS users (
-    user_id TEXT PRIMARY KEY,
-    rank TEXT NOT NULL,
-    registered_at DATETIME DEFAULT CURRENT_TIMESTAMP,
-    registered_by TEXT,
-    active INTEGER DEFAULT 1
-);
+//go:embed migrations/*.sql
+var migrationFiles embed.FS
 
--- Ranks table
-CREATE TABLE IF NOT EXISTS ranks (
-    name TEXT PRIMARY KEY,
-    level INTEGER NOT NULL,
-    commands TEXT NOT NULL,
-    description TEXT,
-    created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
-    active INTEGER DEFAULT 1
+const schemaVersionTable = `
+CREATE TABLE IF NOT EXISTS schema_version (
+    version INTEGER PRIMARY KEY,
+    name TEXT NOT NULL,
+    applied_at DATETIME DEFAULT CURRENT_TIMESTAMP
 );
+`
 
--- Registered groups table
-CREATE TABLE IF NOT EXISTS registered_groups (
-    group_id TEXT PRIMARY KEY,
-    registered_at DATETIME DEFAULT CURRENT_TIMESTAMP,
-    registered_by TEXT NOT NULL,
-    active INTEGER DEFAULT 1
-);
+// migration is one embedded up-migration. Files are named
+// NNNN_description.sql and applied in version order.
+type migration struct {
+	version int
+	name    string
+	sql     string
+}
 
--- Indexes for performance
-CREATE INDEX IF NOT EXISTS idx_users_active ON users(active);
-CREATE INDEX IF NOT EXISTS idx_users_rank ON users(rank);
-CREATE INDEX IF NOT EXISTS idx_ranks_active ON ranks(active);
-CREATE INDEX IF NOT EXISTS idx_groups_active ON registered_groups(active);
-`
+func loadMigrations() ([]migration, error) {
+	entries, err := fs.ReadDir(migrationFiles, "migrations")
+	if err != nil {
+		return nil, fmt.Errorf("read migrations: %w", err)
+	}
 
-const defaultRanksData = `
--- Insert default ranks
-INSERT OR IGNORE INTO ranks (name, level, commands, description) VALUES
-('owner', 0, '*', 'Bot owner with full access'),
-('admin', 10, 'help,latex,register_user,register_group', 'Administrator with management access'),
-('user', 100, 'help,latex', 'Basic user access');
-`
+	migrations := make([]migration, 0, len(entries))
+
+	for _, entry := range entries {
+		name := strings.TrimSuffix(entry.Name(), ".sql")
+
+		prefix, _, found := strings.Cut(name, "_")
+		if !found {
+			return nil, fmt.Errorf("%w: migration file %s", ErrInvalidInput, entry.Name())
+		}
+
+		version, convErr := strconv.Atoi(prefix)
+		if convErr != nil {
+			return nil, fmt.Errorf("%w: migration file %s", ErrInvalidInput, entry.Name())
+		}
+
+		content, readErr := migrationFiles.ReadFile(path.Join("migrations", entry.Name()))
+		if readErr != nil {
+			return nil, fmt.Errorf("read migration %s: %w", entry.Name(), readErr)
+		}
+
+		migrations = append(migrations, migration{version: version, name: name, sql: string(content)})
+	}
+
+	slices.SortFunc(migrations, func(a, b migration) int {
+		return a.version - b.version
+	})
+
+	return migrations, nil
+}
+
+// LatestSchemaVersion is the version the embedded migrations bring a
+// database to.
+func LatestSchemaVersion() (int, error) {
+	migrations, err := loadMigrations()
+	if err != nil {
+		return 0, err
+	}
+
+	if len(migrations) == 0 {
+		return 0, nil
+	}
+
+	return migrations[len(migrations)-1].version, nil
+}
+
+// SchemaVersion returns the highest migration applied to the database, or 0
+// for a database that has never been migrated.
+func SchemaVersion(ctx context.Context, database *sql.DB) (int, error) {
+	columns, err := tableColumns(ctx, database, "schema_version")
+	if err != nil {
+		return 0, err
+	}
+
+	if len(columns) == 0 {
+		return 0, nil
+	}
+
+	var version int
+
+	err = database.QueryRowContext(ctx, "SELECT COALESCE(MAX(version), 0) FROM schema_version").Scan(&version)
+	if err != nil {
+		return 0, fmt.Errorf("read schema version: %w", err)
+	}
+
+	return version, nil
+}
+
+// ValidateSchema checks that every table and column the migrations create
+// exists in the database and that no migration is pending. It reports
+// ErrSchemaDrift describing what is missing.
+func ValidateSchema(ctx context.Context, database *sql.DB) error {
+	expected, err := referenceSchema(ctx, database.Driver())
+	if err != nil {
+		return err
+	}
+
+	var missing []string
+
+	for _, table := range slices.Sorted(maps.Keys(expected)) {
+		columns, colErr := tableColumns(ctx, database, table)
+		if colErr != nil {
+			return colErr
+		}
+
+		if len(columns) == 0 {
+			missing = append(missing, "table "+table)
+
+			continue
+		}
+
+		for _, column := range expected[table] {
+			if !slices.Contains(columns, column) {
+				missing = append(missing, "column "+table+"."+column)
+			}
+		}
+	}
+
+	if len(missing) > 0 {
+		return fmt.Errorf("%w: missing %s", ErrSchemaDrift, strings.Join(missing, ", "))
+	}
 
-func InitSchema(ctx context.Context, database *sql.DB) error {
-	_, err := database.ExecContext(ctx, schema)
+	current, err := SchemaVersion(ctx, database)
 	if err != nil {
-		return fmt.Errorf("exec schema: %w", err)
+		return err
 	}
 
-	_, err = database.ExecContext(ctx, defaultRanksData)
+	latest, err := LatestSchemaVersion()
 	if err != nil {
-		return fmt.Errorf("insert default ranks: %w", err)
+		return err
+	}
+
+	if current != latest {
+		return fmt.Errorf("%w: database at version %d, expected %d", ErrSchemaDrift, current, latest)
 	}
 
 	return nil
 }
+
+// referenceSchema migrates a scratch in-memory database with the same driver
+// and returns its tables and their columns.
+func referenceSchema(ctx context.Context, drv driver.Driver) (_ map[string][]string, err error) {
+	reference := sql.OpenDB(dsnConnector{driver: drv, dsn: ":memory:"})
+
+	// every connection to :memory: is a separate database.
+	reference.SetMaxOpenConns(1)
+
+	defer func() {
+		cerr := reference.Close()
+		if cerr != nil && err == nil {
+			err = fmt.Errorf("close reference database: %w", cerr)
+		}
+	}()
+
+	_, err = Migrate(ctx, reference)
+	if err != nil {
+		return nil, fmt.Errorf("build reference schema: %w", err)
+	}
+
+	tables, err := tableNames(ctx, reference)
+	if err != nil {
+		return nil, err
+	}
+
+	schema := make(map[string][]string, len(tables))
+
+	for _, table := range tables {
+		schema[table], err = tableColumns(ctx, reference, table)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return schema, nil
+}
+
+type dsnConnector struct {
+	driver driver.Driver
+	dsn    string
+}
+
+func (c dsnConnector) Connect(context.Context) (driver.Conn, error) {
+	return c.driver.Open(c.dsn)
+}
+
+func (c dsnConnector) Driver() driver.Driver {
+	return c.driver
+}
+
+func tableNames(ctx context.Context, database *sql.DB) (_ []string, err error) {
+	rows, err := database.QueryContext(ctx,
+		"SELECT name FROM sqlite_master WHERE type = 'table' AND name NOT LIKE 'sqlite_%'")
+	if err != nil {
+		return nil, fmt.Errorf("list tables: %w", err)
+	}
+
+	defer func() {
+		cerr := rows.Close()
+		if cerr != nil && err == nil {
+			err = fmt.Errorf("failed to close rows: %w", cerr)
+		}
+	}()
+
+	return scanStrings(rows)
+}
+
+// tableColumns returns the column names of a table, or none if it does not
+// exist.
+func tableColumns(ctx context.Context, database *sql.DB, table string) (_ []string, err error) {
+	rows, err := database.QueryContext(ctx, "SELECT name FROM pragma_table_info(?)", table)
+	if err != nil {
+		return nil, fmt.Errorf("inspect table %s: %w", table, err)
+	}
+
+	defer func() {
+		cerr := rows.Close()
+		if cerr != nil && err == nil {
+			err = fmt.Errorf("failed to close rows: %w", cerr)
+		}
+	}()
+
+	return scanStrings(rows)
+}
+
+func scanStrings(rows *sql.Rows) ([]string, error) {
+	var values []string
+
+	for rows.Next() {
+		var value string
+
+		err := rows.Scan(&value)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan row: %w", err)
+		}
+
+		values = append(values, value)
+	}
+
+	err := rows.Err()
+	if err != nil {
+		return nil, fmt.Errorf("failed to iterate rows: %w", err)
+	}
+
+	return values, nil
+}
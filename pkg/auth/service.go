@@ -4,77 +4,346 @@ import (
 	"context"
 	"database/sql"
 	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
 )
 
+// registerGroupCommand is the only command honored in groups that are not
+// registered yet; otherwise no group could ever be registered from inside it.
+const registerGroupCommand = "register_group"
+
+// DefaultUnrankedAuditCooldown is how often a denial of a sender without a
+// rank is recorded in the audit log at most, per sender.
+const DefaultUnrankedAuditCooldown = time.Minute
+
 type Service struct {
-	repo *Repository
+	repo             *Repository
+	cache            *authCache
+	groupAdmins      GroupAdminChecker
+	identities       IdentityResolver
+	autoRegisterRank string
+	openCommands     map[string]bool
+	manageCommands   map[string]bool
+	claimMu          sync.Mutex
+	claimCode        string
+	requestTTL       time.Duration
+	requestCooldown  time.Duration
+	unrankedMu       sync.Mutex
+	unrankedAudited  map[string]time.Time
+	unrankedSwept    time.Time
+	unrankedCooldown time.Duration
 }
 
 func NewService(db *sql.DB) *Service {
 	return &Service{
-		repo: NewRepository(db),
+		repo:             NewRepository(db),
+		openCommands:     make(map[string]bool),
+		manageCommands:   make(map[string]bool),
+		requestTTL:       DefaultRequestTTL,
+		requestCooldown:  DefaultRequestCooldown,
+		unrankedAudited:  make(map[string]time.Time),
+		unrankedCooldown: DefaultUnrankedAuditCooldown,
+	}
+}
+
+// SetOpenCommands lets anyone run the given commands, registered or not and
+// whatever their rank, e.g. the commands people use to get access in the
+// first place. Bans and group policies still apply.
+func (s *Service) SetOpenCommands(commands ...string) {
+	for _, cmd := range commands {
+		s.openCommands[cmd] = true
 	}
 }
 
+// SetManagementCommands marks commands that manage the bot (registrations,
+// ranks, bans, ...). Group policies cannot open them with `allow`: they keep
+// requiring a registered user whose rank has the command.
+func (s *Service) SetManagementCommands(commands ...string) {
+	for _, cmd := range commands {
+		s.manageCommands[cmd] = true
+	}
+}
+
+// opensCommand reports whether a policy lets everyone in the group run its
+// command. An `allow` stored for a management command is ignored.
+func (s *Service) opensCommand(policy *GroupPolicy) bool {
+	return policy.Effect == PolicyAllow && !s.manageCommands[policy.Command]
+}
+
+// SetUnrankedAuditCooldown sets how often a denial of a sender without a
+// rank is recorded per sender; 0 records every one.
+func (s *Service) SetUnrankedAuditCooldown(cooldown time.Duration) error {
+	if cooldown < 0 {
+		return fmt.Errorf("%w: unranked audit cooldown must be non-negative", ErrInvalidInput)
+	}
+
+	s.unrankedMu.Lock()
+	defer s.unrankedMu.Unlock()
+
+	s.unrankedCooldown = cooldown
+
+	return nil
+}
+
+// CheckPermission decides whether a user may run a command, recording
+// denials in the audit log. Senders without a rank (strangers, banned users)
+// can trigger denials at will, so theirs are recorded at most once per
+// unranked audit cooldown per sender.
 func (s *Service) CheckPermission(ctx context.Context, userID, groupID, command string) (*PermissionResult, error) {
+	userID = s.canonicalID(ctx, userID)
+
+	result, err := s.checkPermission(ctx, userID, groupID, command)
+	if err != nil || result.Allowed {
+		return result, err
+	}
+
+	if result.UserRank == "" && !s.auditUnranked(userID, time.Now()) {
+		return result, nil
+	}
+
+	err = s.audit(ctx, &AuditEvent{
+		Action:  AuditPermissionDenied,
+		ActorID: userID,
+		Command: command,
+		GroupID: groupID,
+		Reason:  result.auditReason(),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+// auditUnranked reports whether a denial of userID, who has no rank, should
+// be recorded now, and if so starts its cooldown. Senders whose cooldown
+// ran out are forgotten at most once per cooldown.
+func (s *Service) auditUnranked(userID string, now time.Time) bool {
+	s.unrankedMu.Lock()
+	defer s.unrankedMu.Unlock()
+
+	if s.unrankedCooldown == 0 {
+		return true
+	}
+
+	if now.Sub(s.unrankedSwept) >= s.unrankedCooldown {
+		for id, audited := range s.unrankedAudited {
+			if now.Sub(audited) >= s.unrankedCooldown {
+				delete(s.unrankedAudited, id)
+			}
+		}
+
+		s.unrankedSwept = now
+	}
+
+	audited, ok := s.unrankedAudited[userID]
+	if ok && now.Sub(audited) < s.unrankedCooldown {
+		return false
+	}
+
+	s.unrankedAudited[userID] = now
+
+	return true
+}
+
+func (s *Service) checkPermission(ctx context.Context, userID, groupID, command string) (*PermissionResult, error) {
 	err := ValidateCommand(command)
 	if err != nil {
 		return nil, err
 	}
 
-	user, err := s.repo.GetUser(ctx, userID)
+	ban, err := s.lookupBan(ctx, userID, time.Now())
+
+	switch {
+	case err == nil:
+		return denied(DenialBanned, &DenialDetails{BanReason: ban.Reason, BannedUntil: ban.ExpiresAt}), nil
+	case !errors.Is(err, ErrNotBanned):
+		return nil, err
+	}
+
+	denied, policy, err := s.checkGroup(ctx, groupID, command)
 	if err != nil {
-		if errors.Is(err, ErrUserNotFound) {
-			return &PermissionResult{
-				Allowed: false,
-				Reason:  "User not registered",
-			}, nil
-		}
+		return nil, err
+	}
 
+	rank, err := s.userRank(ctx, userID)
+	if err != nil && !errors.Is(err, ErrUserNotFound) {
 		return nil, err
 	}
 
-	if groupID != "" {
-		exists, err := s.repo.GroupExists(ctx, groupID)
-		if err != nil {
-			return nil, err
+	rank, err = s.effectiveRank(ctx, rank, userID, groupID)
+	if err != nil {
+		return nil, err
+	}
+
+	if denied != nil {
+		if rank != nil {
+			denied.UserRank = rank.Name
 		}
 
-		if !exists {
-			return &PermissionResult{
-				Allowed: false,
-				Reason:  "Group not registered",
-			}, nil
+		return denied, nil
+	}
+
+	if rank == nil {
+		rank, err = s.autoRegister(ctx, userID, groupID)
+		if errors.Is(err, ErrUserNotFound) {
+			return s.checkUnregistered(ctx, userID, policy)
+		}
+
+		if err != nil {
+			return nil, err
 		}
 	}
 
-	rank, err := s.repo.GetRank(ctx, user.Rank)
+	return s.checkRank(ctx, rank, command, policy)
+}
+
+// userRank returns the rank of a registered user, or ErrUserNotFound. Users
+// whose grant expired hold their previous rank until the sweeper catches up.
+func (s *Service) userRank(ctx context.Context, userID string) (*Rank, error) {
+	user, err := s.lookupUser(ctx, userID)
 	if err != nil {
 		return nil, err
 	}
 
-	if !rank.HasCommand(command) {
+	if !user.Expired(time.Now()) {
+		return s.lookupRank(ctx, user.Rank)
+	}
+
+	if user.PreviousRank == "" {
+		return nil, ErrUserNotFound
+	}
+
+	rank, err := s.lookupRank(ctx, user.PreviousRank)
+	if errors.Is(err, ErrRankNotFound) {
+		return nil, ErrUserNotFound
+	}
+
+	return rank, err
+}
+
+// checkGroup verifies the group is registered and returns its policy for the
+// command. A non-nil result means the request is denied at group level.
+func (s *Service) checkGroup(ctx context.Context, groupID, command string) (*PermissionResult, *GroupPolicy, error) {
+	inherit := &GroupPolicy{GroupID: groupID, Command: command, Effect: PolicyInherit}
+
+	if groupID == "" || command == registerGroupCommand {
+		return nil, inherit, nil
+	}
+
+	exists, err := s.groupRegistered(ctx, groupID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if !exists {
+		return denied(DenialGroupNotRegistered, nil), nil, nil
+	}
+
+	policy, err := s.lookupGroupPolicy(ctx, groupID, command)
+	if err != nil {
+		if errors.Is(err, ErrPolicyNotFound) {
+			return nil, inherit, nil
+		}
+
+		return nil, nil, err
+	}
+
+	if policy.Effect == PolicyDeny {
+		return denied(DenialGroupPolicy, nil), policy, nil
+	}
+
+	return nil, policy, nil
+}
+
+// checkUnregistered lets unregistered users through only when the command is
+// open or the group has opened it to everyone.
+func (s *Service) checkUnregistered(ctx context.Context, userID string, policy *GroupPolicy) (*PermissionResult, error) {
+	if s.openCommands[policy.Command] && policy.MinRank == "" {
 		return &PermissionResult{
-			Allowed:  false,
-			Reason:   "Command not allowed for your rank",
-			UserRank: user.Rank,
+			Allowed: true,
+			Reason:  "Open command",
 		}, nil
 	}
 
+	if !s.opensCommand(policy) || policy.MinRank != "" {
+		return s.unregisteredDenial(ctx, userID)
+	}
+
+	return &PermissionResult{
+		Allowed: true,
+		Reason:  "Allowed by group policy",
+	}, nil
+}
+
+// unregisteredDenial tells users whose time-limited grant ran out apart from
+// people who were never registered. It only runs on the denial path, so it
+// reads the user row directly instead of going through the cache.
+func (s *Service) unregisteredDenial(ctx context.Context, userID string) (*PermissionResult, error) {
+	user, err := s.repo.GetUserRecord(ctx, userID)
+
+	switch {
+	case errors.Is(err, ErrUserNotFound):
+		return denied(DenialNotRegistered, nil), nil
+	case err != nil:
+		return nil, err
+	case user.Expired(time.Now()):
+		return denied(DenialExpired, &DenialDetails{ExpiredAt: user.ExpiresAt}), nil
+	default:
+		return denied(DenialNotRegistered, nil), nil
+	}
+}
+
+func (s *Service) checkRank(ctx context.Context, rank *Rank, command string, policy *GroupPolicy) (*PermissionResult, error) {
+	if policy.MinRank != "" {
+		minRank, err := s.lookupRank(ctx, policy.MinRank)
+
+		// a policy pointing at a deleted rank no longer restricts anything.
+		switch {
+		case err == nil:
+			if rank.Level > minRank.Level {
+				result := denied(DenialGroupPolicy, &DenialDetails{MinRank: minRank.Name})
+				result.UserRank = rank.Name
+
+				return result, nil
+			}
+		case !errors.Is(err, ErrRankNotFound):
+			return nil, err
+		}
+	}
+
+	if !s.opensCommand(policy) && !s.openCommands[command] && !rank.HasCommand(command) {
+		result := denied(DenialRankLacksCommand, nil)
+		result.UserRank = rank.Name
+
+		return result, nil
+	}
+
 	return &PermissionResult{
 		Allowed:  true,
 		Reason:   "Access granted",
-		UserRank: user.Rank,
+		UserRank: rank.Name,
 	}, nil
 }
 
-func (s *Service) RegisterUser(ctx context.Context, userID, rankName, registeredBy string) error {
+// RegisterUser registers a user with a rank. A non-nil expiresAt makes the
+// registration temporary: the user counts as unregistered from then on.
+func (s *Service) RegisterUser(ctx context.Context, userID, rankName string, expiresAt *time.Time, registeredBy string) error {
+	userID, registeredBy = s.canonicalID(ctx, userID), s.canonicalID(ctx, registeredBy)
+
 	err := ValidateRankName(rankName)
 	if err != nil {
 		return err
 	}
 
-	_, err = s.repo.GetRank(ctx, rankName)
+	err = validateExpiry(expiresAt)
+	if err != nil {
+		return err
+	}
+
+	rank, err := s.repo.GetRank(ctx, rankName)
 	if err != nil {
 		if errors.Is(err, ErrRankNotFound) {
 			return ErrRankNotFound
@@ -83,6 +352,16 @@ func (s *Service) RegisterUser(ctx context.Context, userID, rankName, registered
 		return err
 	}
 
+	actorLevel, err := s.actorLevel(ctx, registeredBy)
+	if err != nil {
+		return err
+	}
+
+	err = canGrant(actorLevel, rank)
+	if err != nil {
+		return err
+	}
+
 	exists, err := s.repo.UserExists(ctx, userID)
 	if err != nil {
 		return err
@@ -92,37 +371,372 @@ func (s *Service) RegisterUser(ctx context.Context, userID, rankName, registered
 		return ErrUserExists
 	}
 
-	return s.repo.CreateUser(ctx, userID, rankName, registeredBy)
+	err = s.repo.CreateUser(ctx, userID, rankName, registeredBy, expiresAt)
+	if err != nil {
+		return err
+	}
+
+	s.cache.forgetUser(userID)
+
+	return s.audit(ctx, &AuditEvent{
+		Action:   AuditUserRegistered,
+		ActorID:  registeredBy,
+		TargetID: userID,
+		Reason:   "rank " + rankName + untilSuffix(expiresAt),
+	})
+}
+
+// SetUserRank moves a user to an explicit rank. The actor must outrank both
+// the user's current rank and the new one. A non-nil expiresAt grants the
+// rank temporarily: the user returns to the rank they hold now (or the one
+// they held before an earlier temporary grant) at that time. A nil expiresAt
+// makes the change permanent.
+func (s *Service) SetUserRank(ctx context.Context, userID, rankName string, expiresAt *time.Time, actorID string) error {
+	userID, actorID = s.canonicalID(ctx, userID), s.canonicalID(ctx, actorID)
+
+	err := ValidateRankName(rankName)
+	if err != nil {
+		return err
+	}
+
+	err = validateExpiry(expiresAt)
+	if err != nil {
+		return err
+	}
+
+	rank, err := s.repo.GetRank(ctx, rankName)
+	if err != nil {
+		return err
+	}
+
+	pick := func(_ []*Rank, _ *Rank) (*Rank, error) {
+		return rank, nil
+	}
+
+	save := func(target *User, next *Rank) error {
+		previous := target.Rank
+		if target.ExpiresAt != nil {
+			previous = target.PreviousRank
+		}
+
+		if expiresAt == nil {
+			previous = ""
+		}
+
+		return s.repo.SetUserGrant(ctx, userID, next.Name, previous, expiresAt)
+	}
+
+	_, err = s.changeRank(ctx, userID, actorID, pick, save, untilSuffix(expiresAt))
+
+	return err
+}
+
+// PromoteUser moves a user one rank up (towards level 0) and returns the new
+// rank. A temporary grant keeps its expiry.
+func (s *Service) PromoteUser(ctx context.Context, userID, actorID string) (*Rank, error) {
+	return s.changeRank(ctx, userID, actorID, func(ranks []*Rank, current *Rank) (*Rank, error) {
+		return adjacentRank(ranks, current.Level, -1)
+	}, s.updateRank(ctx), "")
+}
+
+// DemoteUser moves a user one rank down and returns the new rank.
+func (s *Service) DemoteUser(ctx context.Context, userID, actorID string) (*Rank, error) {
+	return s.changeRank(ctx, userID, actorID, func(ranks []*Rank, current *Rank) (*Rank, error) {
+		return adjacentRank(ranks, current.Level, 1)
+	}, s.updateRank(ctx), "")
+}
+
+// updateRank saves a rank change without touching the user's expiry.
+func (s *Service) updateRank(ctx context.Context) func(target *User, next *Rank) error {
+	return func(target *User, next *Rank) error {
+		return s.repo.UpdateUserRank(ctx, target.ID, next.Name)
+	}
+}
+
+// changeRank checks the hierarchy for moving a user to the rank pick returns
+// and stores it with save. note is appended to the audit reason.
+func (s *Service) changeRank(
+	ctx context.Context,
+	userID, actorID string,
+	pick func(ranks []*Rank, current *Rank) (*Rank, error),
+	save func(target *User, next *Rank) error,
+	note string,
+) (*Rank, error) {
+	userID, actorID = s.canonicalID(ctx, userID), s.canonicalID(ctx, actorID)
+
+	actorLevel, err := s.actorLevel(ctx, actorID)
+	if err != nil {
+		return nil, err
+	}
+
+	target, err := s.repo.GetUser(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.canManage(ctx, actorLevel, target)
+	if err != nil {
+		return nil, err
+	}
+
+	current, err := s.repo.GetRank(ctx, target.Rank)
+	if err != nil {
+		return nil, err
+	}
+
+	ranks, err := s.repo.ListRanks(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	next, err := pick(ranks, current)
+	if err != nil {
+		return nil, err
+	}
+
+	err = canGrant(actorLevel, next)
+	if err != nil {
+		return nil, err
+	}
+
+	err = save(target, next)
+	if err != nil {
+		return nil, err
+	}
+
+	s.cache.forgetUser(userID)
+
+	err = s.audit(ctx, &AuditEvent{
+		Action:   AuditRankChanged,
+		ActorID:  actorID,
+		TargetID: userID,
+		Reason:   current.Name + " -> " + next.Name + note,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return next, nil
 }
 
 func (s *Service) RegisterGroup(ctx context.Context, groupID, registeredBy string) error {
+	registeredBy = s.canonicalID(ctx, registeredBy)
+
 	if groupID == "" {
 		return ErrInvalidInput
 	}
 
-	exists, err := s.repo.UserExists(ctx, registeredBy)
+	if !IsSystemActor(registeredBy) {
+		exists, err := s.repo.UserExists(ctx, registeredBy)
+		if err != nil {
+			return err
+		}
+
+		if !exists {
+			return ErrUserNotFound
+		}
+	}
+
+	exists, err := s.repo.GroupExists(ctx, groupID)
 	if err != nil {
 		return err
 	}
 
-	if !exists {
-		return ErrUserNotFound
+	if exists {
+		return ErrGroupExists
 	}
 
-	exists, err = s.repo.GroupExists(ctx, groupID)
+	err = s.repo.CreateGroup(ctx, groupID, registeredBy)
 	if err != nil {
 		return err
 	}
 
-	if exists {
-		return ErrGroupExists
+	s.cache.forgetGroup(groupID)
+
+	return s.audit(ctx, &AuditEvent{
+		Action:   AuditGroupRegistered,
+		ActorID:  registeredBy,
+		TargetID: groupID,
+		GroupID:  groupID,
+	})
+}
+
+// DeactivateUser revokes a registration. The row is kept so audits can still
+// see who registered and who removed the user.
+func (s *Service) DeactivateUser(ctx context.Context, userID, actorID string) error {
+	userID, actorID = s.canonicalID(ctx, userID), s.canonicalID(ctx, actorID)
+
+	actorLevel, err := s.actorLevel(ctx, actorID)
+	if err != nil {
+		return err
 	}
 
-	return s.repo.CreateGroup(ctx, groupID, registeredBy)
+	target, err := s.repo.GetUser(ctx, userID)
+	if err != nil {
+		return err
+	}
+
+	err = s.canManage(ctx, actorLevel, target)
+	if err != nil {
+		return err
+	}
+
+	err = s.repo.DeactivateUser(ctx, userID, actorID)
+	if err != nil {
+		return err
+	}
+
+	s.cache.forgetUser(userID)
+
+	return s.audit(ctx, &AuditEvent{
+		Action:   AuditUserDeactivated,
+		ActorID:  actorID,
+		TargetID: userID,
+	})
+}
+
+// ReactivateUser lifts a ban and restores a deactivated registration,
+// whichever of the two applies. Returns ErrNotBanned when there was nothing
+// to undo.
+func (s *Service) ReactivateUser(ctx context.Context, userID, actorID string) error {
+	userID, actorID = s.canonicalID(ctx, userID), s.canonicalID(ctx, actorID)
+
+	actorLevel, err := s.actorLevel(ctx, actorID)
+	if err != nil {
+		return err
+	}
+
+	record, err := s.repo.GetUserRecord(ctx, userID)
+	if err != nil && !errors.Is(err, ErrUserNotFound) {
+		return err
+	}
+
+	if record != nil {
+		err = s.canManage(ctx, actorLevel, record)
+		if err != nil {
+			return err
+		}
+	}
+
+	var undone []string
+
+	err = s.repo.DeleteBan(ctx, userID)
+	switch {
+	case err == nil:
+		s.cache.forgetBan(userID)
+
+		undone = append(undone, "ban lifted")
+	case !errors.Is(err, ErrNotBanned):
+		return err
+	}
+
+	if record != nil && !record.Active {
+		err = s.repo.ReactivateUser(ctx, userID)
+		if err != nil {
+			return err
+		}
+
+		s.cache.forgetUser(userID)
+
+		undone = append(undone, "registration restored")
+	}
+
+	if len(undone) == 0 {
+		return ErrNotBanned
+	}
+
+	return s.audit(ctx, &AuditEvent{
+		Action:   AuditUserReactivated,
+		ActorID:  actorID,
+		TargetID: userID,
+		Reason:   strings.Join(undone, ", "),
+	})
+}
+
+// BanUser blocks a user from every command, registered or not. A nil
+// expiresAt bans permanently. Banning a registered user requires outranking them.
+func (s *Service) BanUser(ctx context.Context, userID, reason string, expiresAt *time.Time, actorID string) error {
+	userID, actorID = s.canonicalID(ctx, userID), s.canonicalID(ctx, actorID)
+
+	if userID == "" || userID == actorID {
+		return ErrInvalidInput
+	}
+
+	if expiresAt != nil && !expiresAt.After(time.Now()) {
+		return ErrInvalidInput
+	}
+
+	actorLevel, err := s.actorLevel(ctx, actorID)
+	if err != nil {
+		return err
+	}
+
+	target, err := s.repo.GetUser(ctx, userID)
+	switch {
+	case err == nil:
+		err = s.canManage(ctx, actorLevel, target)
+		if err != nil {
+			return err
+		}
+	case !errors.Is(err, ErrUserNotFound):
+		return err
+	}
+
+	err = s.repo.SaveBan(ctx, &Ban{
+		UserID:    userID,
+		Reason:    reason,
+		BannedBy:  actorID,
+		ExpiresAt: expiresAt,
+	})
+	if err != nil {
+		return err
+	}
+
+	s.cache.forgetBan(userID)
+
+	if expiresAt != nil {
+		reason = strings.TrimSpace(reason + " (until " + expiresAt.UTC().Format(time.RFC3339) + ")")
+	}
+
+	return s.audit(ctx, &AuditEvent{
+		Action:   AuditUserBanned,
+		ActorID:  actorID,
+		TargetID: userID,
+		Reason:   reason,
+	})
+}
+
+// DeactivateGroup stops the bot in a group. Like RegisterGroup, it takes a
+// registered user or a system actor.
+func (s *Service) DeactivateGroup(ctx context.Context, groupID, actorID string) error {
+	if groupID == "" {
+		return ErrInvalidInput
+	}
+
+	actorID = s.canonicalID(ctx, actorID)
+
+	_, err := s.actorRank(ctx, actorID)
+	if err != nil {
+		return err
+	}
+
+	err = s.repo.DeactivateGroup(ctx, groupID, actorID)
+	if err != nil {
+		return err
+	}
+
+	s.cache.forgetGroup(groupID)
+
+	return s.audit(ctx, &AuditEvent{
+		Action:   AuditGroupDeactivated,
+		ActorID:  actorID,
+		TargetID: groupID,
+		GroupID:  groupID,
+	})
 }
 
 func (s *Service) GetUser(ctx context.Context, userID string) (*User, error) {
-	return s.repo.GetUser(ctx, userID)
+	return s.repo.GetUser(ctx, s.canonicalID(ctx, userID))
 }
 
 func (s *Service) GetRank(ctx context.Context, rankName string) (*Rank, error) {
@@ -138,6 +752,14 @@ func (s *Service) ListRanks(ctx context.Context) ([]*Rank, error) {
 	return s.repo.ListRanks(ctx)
 }
 
+func (s *Service) ListUsers(ctx context.Context) ([]*User, error) {
+	return s.repo.ListUsers(ctx)
+}
+
+func (s *Service) ListGroups(ctx context.Context) ([]*Group, error) {
+	return s.repo.ListGroups(ctx)
+}
+
 func (s *Service) GetGroup(ctx context.Context, groupID string) (*Group, error) {
 	if groupID == "" {
 		return nil, ErrInvalidInput
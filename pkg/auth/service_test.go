@@ -0,0 +1,103 @@
+package auth
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func countDenials(t *testing.T, service *Service, userID string) int {
+	t.Helper()
+
+	events, err := service.ListAuditEvents(context.Background(), AuditFilter{UserID: userID, Limit: MaxAuditLimit})
+	if err != nil {
+		t.Fatalf("ListAuditEvents: %v", err)
+	}
+
+	denials := 0
+
+	for _, event := range events {
+		if event.Action == AuditPermissionDenied {
+			denials++
+		}
+	}
+
+	return denials
+}
+
+func TestCheckPermissionAuditsUnrankedDenialsOncePerCooldown(t *testing.T) {
+	const (
+		stranger = "51911111111@s.whatsapp.net"
+		other    = "51922222222@s.whatsapp.net"
+	)
+
+	ctx := context.Background()
+	database := openMemoryDB(t)
+
+	_, err := Migrate(ctx, database)
+	if err != nil {
+		t.Fatalf("Migrate: %v", err)
+	}
+
+	service := NewService(database)
+
+	for range 3 {
+		result, err := service.CheckPermission(ctx, stranger, "", "help")
+		if err != nil {
+			t.Fatalf("CheckPermission: %v", err)
+		}
+
+		if result.Allowed {
+			t.Fatal("unregistered sender allowed")
+		}
+	}
+
+	if got := countDenials(t, service, stranger); got != 1 {
+		t.Errorf("%d denials recorded within the cooldown, want 1", got)
+	}
+
+	_, err = service.CheckPermission(ctx, other, "", "help")
+	if err != nil {
+		t.Fatalf("CheckPermission: %v", err)
+	}
+
+	if got := countDenials(t, service, other); got != 1 {
+		t.Errorf("%d denials recorded for another sender, want 1", got)
+	}
+
+	err = service.SetUnrankedAuditCooldown(0)
+	if err != nil {
+		t.Fatalf("SetUnrankedAuditCooldown: %v", err)
+	}
+
+	_, err = service.CheckPermission(ctx, stranger, "", "help")
+	if err != nil {
+		t.Fatalf("CheckPermission: %v", err)
+	}
+
+	if got := countDenials(t, service, stranger); got != 2 {
+		t.Errorf("%d denials recorded without a cooldown, want 2", got)
+	}
+}
+
+func TestAuditUnrankedForgetsExpiredSenders(t *testing.T) {
+	service := NewService(nil)
+	now := time.Now()
+
+	if !service.auditUnranked("a", now) || service.auditUnranked("a", now.Add(time.Second)) {
+		t.Fatal("cooldown not applied")
+	}
+
+	later := now.Add(DefaultUnrankedAuditCooldown)
+	if !service.auditUnranked("b", later) {
+		t.Fatal("new sender not audited")
+	}
+
+	if _, ok := service.unrankedAudited["a"]; ok {
+		t.Error("sender whose cooldown ran out was kept")
+	}
+
+	if !service.auditUnranked("a", later) {
+		t.Error("sender not audited again after the cooldown")
+	}
+}
@@ -1,19 +1,48 @@
 package auth
 
+import "strings"
+
 const (
 	maxCommandLength  = 50
 	maxRankNameLength = 50
 )
 
+// ValidateCommand checks a command name. Besides letters, digits, "_" and
+// "-", names may use "." and ":" to separate a family from its members
+// ("admin.stats", "render:pdf"), but may not start or end with either, and
+// may not start with "-", which marks negations in rank command lists.
 func ValidateCommand(command string) error {
 	err := validateCommandLength(command)
 	if err != nil {
 		return err
 	}
 
+	err = validateCommandEnds(command)
+	if err != nil {
+		return err
+	}
+
 	return validateCommandCharacters(command)
 }
 
+// ValidateCommandPattern checks an entry of a rank's command list: a command
+// name, "*" for every command, a family wildcard ending in ".*" or ":*"
+// ("admin.*"), or any of these prefixed with "-" to negate it.
+func ValidateCommandPattern(pattern string) error {
+	pattern = strings.TrimPrefix(pattern, negationPrefix)
+	if pattern == wildcard {
+		return nil
+	}
+
+	family, ok := wildcardFamily(pattern)
+	if !ok {
+		return ValidateCommand(pattern)
+	}
+
+	// validate the family as a name of its own, e.g. "admin" for "admin.*".
+	return ValidateCommand(family[:len(family)-1])
+}
+
 func ValidateRankName(name string) error {
 	err := validateRankNameLength(name)
 	if err != nil {
@@ -36,6 +65,16 @@ func validateCommandLength(command string) error {
 	return nil
 }
 
+func validateCommandEnds(command string) error {
+	if strings.HasPrefix(command, negationPrefix) ||
+		strings.ContainsAny(command[:1], commandSeparators) ||
+		strings.ContainsAny(command[len(command)-1:], commandSeparators) {
+		return ErrInvalidInput
+	}
+
+	return nil
+}
+
 func validateCommandCharacters(command string) error {
 	for _, r := range command {
 		if !isValidCommandChar(r) {
@@ -73,7 +112,8 @@ func isValidCommandChar(r rune) bool {
 	return (r >= 'a' && r <= 'z') ||
 		(r >= 'A' && r <= 'Z') ||
 		(r >= '0' && r <= '9') ||
-		r == '_' || r == '-'
+		r == '_' || r == '-' ||
+		strings.ContainsRune(commandSeparators, r)
 }
 
 func isValidRankChar(r rune) bool {
@@ -0,0 +1,191 @@
+// Package cli implements the administrative subcommands of the botex binary.
+// They open the bot's database through auth.Service without connecting to
+// WhatsApp, so onboarding and recovery can be scripted.
+package cli
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"slices"
+	"strings"
+	"text/tabwriter"
+
+	"botex/pkg/auth"
+	"botex/pkg/config"
+	"botex/pkg/util"
+)
+
+// Actor is recorded as the actor of every change made from the command line.
+// Like every system actor it is not bound by the rank hierarchy.
+const Actor = auth.SystemActor + ":cli"
+
+var (
+	ErrUsage         = errors.New("invalid usage")
+	ErrInvalidUserID = util.ErrInvalidUserID
+)
+
+type subcommand struct {
+	args string
+	run  func(a *app, ctx context.Context, args []string) error
+}
+
+var subcommands = map[string]map[string]subcommand{
+	"auth": {
+		"export": {"[file.json|file.yaml]", (*app).authExport},
+		"import": {"[--dry-run] <file.json|file.yaml>", (*app).authImport},
+	},
+	"user": {
+		"add":      {"<number|jid> [rank] [duration]", (*app).userAdd},
+		"list":     {"", (*app).userList},
+		"rm":       {"<number|jid>", (*app).userRemove},
+		"set-rank": {"<number|jid> <rank> [duration]", (*app).userSetRank},
+		"unban":    {"<number|jid>", (*app).userUnban},
+	},
+	"group": {
+		"add":  {"<group jid>", (*app).groupAdd},
+		"list": {"", (*app).groupList},
+		"rm":   {"<group jid>", (*app).groupRemove},
+	},
+	"invite": {
+		"create": {"<rank> [uses] [duration]", (*app).inviteCreate},
+		"list":   {"", (*app).inviteList},
+		"rm":     {"<code>|#<ref>", (*app).inviteRemove},
+	},
+	"request": {
+		"approve": {"<id> [rank]", (*app).requestApprove},
+		"deny":    {"<id>", (*app).requestDeny},
+		"list":    {"", (*app).requestList},
+	},
+	"rank": {
+		"create":       {"<name> <level> [cmd,cmd] [description]", (*app).rankCreate},
+		"list":         {"", (*app).rankList},
+		"rm":           {"<name>", (*app).rankRemove},
+		"set-commands": {"<name> <cmd,cmd>", (*app).rankSetCommands},
+		"set-level":    {"<name> <level>", (*app).rankSetLevel},
+	},
+	"db": {
+		"check":   {"", (*app).dbCheck},
+		"migrate": {"", (*app).dbMigrate},
+	},
+}
+
+type app struct {
+	cfg     *config.Config
+	out     io.Writer
+	db      *sql.DB
+	service *auth.Service
+}
+
+// IsCommand reports whether the arguments name a CLI subcommand rather than
+// asking to start the bot.
+func IsCommand(args []string) bool {
+	if len(args) == 0 {
+		return false
+	}
+
+	_, ok := subcommands[args[0]]
+
+	return ok || args[0] == "help"
+}
+
+// Run executes one subcommand, e.g. ["user", "add", "51999888777", "admin"].
+func Run(ctx context.Context, cfg *config.Config, args []string, out io.Writer) (err error) {
+	if len(args) == 0 || args[0] == "help" {
+		printUsage(out)
+
+		return nil
+	}
+
+	group, ok := subcommands[args[0]]
+	if !ok || len(args) < 2 {
+		printUsage(out)
+
+		return fmt.Errorf("%w: %s", ErrUsage, strings.Join(args, " "))
+	}
+
+	cmd, ok := group[args[1]]
+	if !ok {
+		printUsage(out)
+
+		return fmt.Errorf("%w: unknown subcommand %q", ErrUsage, args[0]+" "+args[1])
+	}
+
+	database, err := sql.Open("sqlite3", cfg.DBPath)
+	if err != nil {
+		return fmt.Errorf("open database: %w", err)
+	}
+
+	defer func() {
+		cerr := database.Close()
+		if cerr != nil && err == nil {
+			err = fmt.Errorf("close database: %w", cerr)
+		}
+	}()
+
+	a := &app{
+		cfg:     cfg,
+		out:     out,
+		db:      database,
+		service: auth.New(database),
+	}
+
+	// db subcommands manage the schema themselves; everything else needs it
+	// current, exactly as the bot does at startup.
+	if args[0] != "db" {
+		_, err = auth.Migrate(ctx, database)
+		if err != nil {
+			return fmt.Errorf("migrate database: %w", err)
+		}
+	}
+
+	err = cmd.run(a, ctx, args[2:])
+	if errors.Is(err, ErrUsage) {
+		fmt.Fprintln(out, "Usage:", usageLine(args[0], args[1], cmd))
+	}
+
+	return err
+}
+
+func printUsage(out io.Writer) {
+	fmt.Fprintln(out, "Usage: botex [command]")
+	fmt.Fprintln(out, "\nWithout a command the bot starts. Commands:")
+
+	for _, noun := range slices.Sorted(maps.Keys(subcommands)) {
+		for _, verb := range slices.Sorted(maps.Keys(subcommands[noun])) {
+			fmt.Fprintln(out, "  "+usageLine(noun, verb, subcommands[noun][verb]))
+		}
+	}
+}
+
+func usageLine(noun, verb string, cmd subcommand) string {
+	return strings.TrimSpace(fmt.Sprintf("botex %s %s %s", noun, verb, cmd.args))
+}
+
+// requireArgs fails with ErrUsage unless there are between minArgs and
+// maxArgs arguments (maxArgs < 0 means no upper bound).
+func requireArgs(args []string, minArgs, maxArgs int) error {
+	if len(args) < minArgs || (maxArgs >= 0 && len(args) > maxArgs) {
+		return ErrUsage
+	}
+
+	return nil
+}
+
+func (a *app) table() *tabwriter.Writer {
+	return tabwriter.NewWriter(a.out, 0, 0, 2, ' ', 0)
+}
+
+// ParseUserID turns a phone number ("+51 999 888 777") or a JID into the
+// user ID stored by the auth package.
+func ParseUserID(raw string) (string, error) {
+	jid, err := util.ParseUserJID(raw)
+	if err != nil {
+		return "", err
+	}
+
+	return jid.String(), nil
+}
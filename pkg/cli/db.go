@@ -0,0 +1,62 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+
+	"botex/pkg/auth"
+)
+
+func (a *app) dbMigrate(ctx context.Context, args []string) error {
+	err := requireArgs(args, 0, 0)
+	if err != nil {
+		return err
+	}
+
+	applied, err := auth.Migrate(ctx, a.db)
+	if err != nil {
+		return fmt.Errorf("migrate: %w", err)
+	}
+
+	version, err := auth.SchemaVersion(ctx, a.db)
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(a.out, "Applied %d migration(s), schema at version %d\n", applied, version)
+
+	missing, err := auth.MissingAdminCommands(ctx, a.db)
+	if err != nil {
+		return err
+	}
+
+	if len(missing) > 0 {
+		fmt.Fprintf(a.out, "The admin rank was edited and lacks commands the migrations grant: %s\n",
+			auth.JoinCommands(missing))
+	}
+
+	return nil
+}
+
+// dbCheck reports drift without changing anything, so it can run against a
+// database the bot is using.
+func (a *app) dbCheck(ctx context.Context, args []string) error {
+	err := requireArgs(args, 0, 0)
+	if err != nil {
+		return err
+	}
+
+	err = auth.ValidateSchema(ctx, a.db)
+	if err != nil {
+		return err
+	}
+
+	version, err := auth.SchemaVersion(ctx, a.db)
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(a.out, "Schema OK at version %d\n", version)
+
+	return nil
+}
@@ -0,0 +1,81 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"go.mau.fi/whatsmeow/types"
+)
+
+func (a *app) groupAdd(ctx context.Context, args []string) error {
+	err := requireArgs(args, 1, 1)
+	if err != nil {
+		return err
+	}
+
+	groupID, err := parseGroupID(args[0])
+	if err != nil {
+		return err
+	}
+
+	err = a.service.RegisterGroup(ctx, groupID, Actor)
+	if err != nil {
+		return fmt.Errorf("register group: %w", err)
+	}
+
+	fmt.Fprintf(a.out, "Registered group %s\n", groupID)
+
+	return nil
+}
+
+func (a *app) groupList(ctx context.Context, args []string) error {
+	err := requireArgs(args, 0, 0)
+	if err != nil {
+		return err
+	}
+
+	groups, err := a.service.ListGroups(ctx)
+	if err != nil {
+		return fmt.Errorf("list groups: %w", err)
+	}
+
+	table := a.table()
+	fmt.Fprintln(table, "GROUP\tREGISTERED\tREGISTERED BY")
+
+	for _, group := range groups {
+		fmt.Fprintf(table, "%s\t%s\t%s\n", group.ID, group.RegisteredAt.Format(time.DateTime), group.RegisteredBy)
+	}
+
+	return table.Flush()
+}
+
+func (a *app) groupRemove(ctx context.Context, args []string) error {
+	err := requireArgs(args, 1, 1)
+	if err != nil {
+		return err
+	}
+
+	groupID, err := parseGroupID(args[0])
+	if err != nil {
+		return err
+	}
+
+	err = a.service.DeactivateGroup(ctx, groupID, Actor)
+	if err != nil {
+		return fmt.Errorf("deactivate group: %w", err)
+	}
+
+	fmt.Fprintf(a.out, "Deactivated group %s\n", groupID)
+
+	return nil
+}
+
+func parseGroupID(raw string) (string, error) {
+	jid, err := types.ParseJID(raw)
+	if err != nil || jid.Server != types.GroupServer {
+		return "", fmt.Errorf("%w: %q is not a group JID (<id>@%s)", ErrUsage, raw, types.GroupServer)
+	}
+
+	return jid.String(), nil
+}
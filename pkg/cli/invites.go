@@ -0,0 +1,89 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	"botex/pkg/auth"
+	"botex/pkg/util"
+)
+
+func (a *app) inviteCreate(ctx context.Context, args []string) error {
+	err := requireArgs(args, 1, 3)
+	if err != nil {
+		return err
+	}
+
+	uses := 1
+
+	if len(args) > 1 {
+		uses, err = strconv.Atoi(args[1])
+		if err != nil {
+			return fmt.Errorf("%w: uses %q", ErrUsage, args[1])
+		}
+	}
+
+	var expiresAt *time.Time
+
+	if len(args) > 2 {
+		expiresAt, err = parseExpiry(args[2])
+		if err != nil {
+			return err
+		}
+	}
+
+	invite, err := a.service.CreateInvite(ctx, args[0], uses, expiresAt, Actor)
+	if err != nil {
+		return fmt.Errorf("create invite: %w", err)
+	}
+
+	fmt.Fprintf(a.out, "Invite %s (%s) registers up to %d user(s) as %s%s\n",
+		invite.Code, auth.InviteRef(invite.Code), invite.MaxUses, invite.Rank, util.UntilText(expiresAt))
+
+	return nil
+}
+
+func (a *app) inviteList(ctx context.Context, args []string) error {
+	err := requireArgs(args, 0, 0)
+	if err != nil {
+		return err
+	}
+
+	invites, err := a.service.ListInvites(ctx, Actor)
+	if err != nil {
+		return fmt.Errorf("list invites: %w", err)
+	}
+
+	table := a.table()
+	fmt.Fprintln(table, "REF\tRANK\tUSED\tEXPIRES\tCREATED BY")
+
+	for _, invite := range invites {
+		expires := "-"
+		if invite.ExpiresAt != nil {
+			expires = invite.ExpiresAt.Local().Format(time.DateTime)
+		}
+
+		fmt.Fprintf(table, "%s\t%s\t%d/%d\t%s\t%s\n",
+			invite.Code, invite.Rank, invite.Uses, invite.MaxUses, expires, invite.CreatedBy)
+	}
+
+	return table.Flush()
+}
+
+func (a *app) inviteRemove(ctx context.Context, args []string) error {
+	err := requireArgs(args, 1, 1)
+	if err != nil {
+		return err
+	}
+
+	err = a.service.RevokeInvite(ctx, args[0], Actor)
+	if err != nil {
+		return fmt.Errorf("revoke invite: %w", err)
+	}
+
+	fmt.Fprintf(a.out, "Revoked invite %s\n", args[0])
+
+	return nil
+}
@@ -0,0 +1,129 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"botex/pkg/auth"
+)
+
+func (a *app) rankList(ctx context.Context, args []string) error {
+	err := requireArgs(args, 0, 0)
+	if err != nil {
+		return err
+	}
+
+	ranks, err := a.service.ListRanks(ctx)
+	if err != nil {
+		return fmt.Errorf("list ranks: %w", err)
+	}
+
+	table := a.table()
+	fmt.Fprintln(table, "RANK\tLEVEL\tCOMMANDS\tDESCRIPTION")
+
+	for _, rank := range ranks {
+		fmt.Fprintf(table, "%s\t%d\t%s\t%s\n", rank.Name, rank.Level, auth.JoinCommands(rank.Commands), rank.Description)
+	}
+
+	return table.Flush()
+}
+
+func (a *app) rankCreate(ctx context.Context, args []string) error {
+	err := requireArgs(args, 2, -1)
+	if err != nil {
+		return err
+	}
+
+	level, err := parseLevel(args[1])
+	if err != nil {
+		return err
+	}
+
+	rank := &auth.Rank{
+		Name:  args[0],
+		Level: level,
+	}
+
+	if len(args) > 2 {
+		rank.Commands = auth.ParseCommands(args[2])
+	}
+
+	if len(args) > 3 {
+		rank.Description = strings.Join(args[3:], " ")
+	}
+
+	err = a.service.CreateRank(ctx, rank, Actor)
+	if err != nil {
+		return fmt.Errorf("create rank: %w", err)
+	}
+
+	fmt.Fprintf(a.out, "Created rank %s at level %d\n", rank.Name, rank.Level)
+
+	return nil
+}
+
+func (a *app) rankSetCommands(ctx context.Context, args []string) error {
+	err := requireArgs(args, 2, 2)
+	if err != nil {
+		return err
+	}
+
+	commands := auth.ParseCommands(args[1])
+
+	err = a.service.UpdateRankCommands(ctx, args[0], commands, Actor)
+	if err != nil {
+		return fmt.Errorf("update rank commands: %w", err)
+	}
+
+	fmt.Fprintf(a.out, "Rank %s commands: %s\n", args[0], auth.JoinCommands(commands))
+
+	return nil
+}
+
+func (a *app) rankSetLevel(ctx context.Context, args []string) error {
+	err := requireArgs(args, 2, 2)
+	if err != nil {
+		return err
+	}
+
+	level, err := parseLevel(args[1])
+	if err != nil {
+		return err
+	}
+
+	err = a.service.SetRankLevel(ctx, args[0], level, Actor)
+	if err != nil {
+		return fmt.Errorf("set rank level: %w", err)
+	}
+
+	fmt.Fprintf(a.out, "Rank %s moved to level %d\n", args[0], level)
+
+	return nil
+}
+
+func (a *app) rankRemove(ctx context.Context, args []string) error {
+	err := requireArgs(args, 1, 1)
+	if err != nil {
+		return err
+	}
+
+	err = a.service.DeleteRank(ctx, args[0], Actor)
+	if err != nil {
+		return fmt.Errorf("delete rank: %w", err)
+	}
+
+	fmt.Fprintf(a.out, "Deleted rank %s\n", args[0])
+
+	return nil
+}
+
+func parseLevel(raw string) (int, error) {
+	level, err := strconv.Atoi(raw)
+	if err != nil {
+		return 0, fmt.Errorf("%w: level %q is not a number", ErrUsage, raw)
+	}
+
+	return level, nil
+}
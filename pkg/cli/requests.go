@@ -0,0 +1,87 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+)
+
+func (a *app) requestList(ctx context.Context, args []string) error {
+	err := requireArgs(args, 0, 0)
+	if err != nil {
+		return err
+	}
+
+	requests, err := a.service.ListPendingRequests(ctx)
+	if err != nil {
+		return fmt.Errorf("list access requests: %w", err)
+	}
+
+	table := a.table()
+	fmt.Fprintln(table, "ID\tUSER\tREQUESTED\tEXPIRES\tREASON")
+
+	for _, request := range requests {
+		fmt.Fprintf(table, "%d\t%s\t%s\t%s\t%s\n",
+			request.ID, request.UserID, request.CreatedAt.Local().Format(time.DateTime),
+			request.ExpiresAt.Local().Format(time.DateTime), request.Reason)
+	}
+
+	return table.Flush()
+}
+
+func (a *app) requestApprove(ctx context.Context, args []string) error {
+	err := requireArgs(args, 1, 2)
+	if err != nil {
+		return err
+	}
+
+	id, err := parseRequestID(args[0])
+	if err != nil {
+		return err
+	}
+
+	rank := a.cfg.Auth.DefaultUserRank
+	if len(args) > 1 {
+		rank = args[1]
+	}
+
+	request, err := a.service.ApproveRequest(ctx, id, rank, Actor)
+	if err != nil {
+		return fmt.Errorf("approve request: %w", err)
+	}
+
+	fmt.Fprintf(a.out, "Approved request %d: registered %s as %s\n", id, request.UserID, rank)
+
+	return nil
+}
+
+func (a *app) requestDeny(ctx context.Context, args []string) error {
+	err := requireArgs(args, 1, 1)
+	if err != nil {
+		return err
+	}
+
+	id, err := parseRequestID(args[0])
+	if err != nil {
+		return err
+	}
+
+	request, err := a.service.DenyRequest(ctx, id, Actor)
+	if err != nil {
+		return fmt.Errorf("deny request: %w", err)
+	}
+
+	fmt.Fprintf(a.out, "Denied request %d from %s\n", id, request.UserID)
+
+	return nil
+}
+
+func parseRequestID(raw string) (int64, error) {
+	id, err := strconv.ParseInt(raw, 10, 64)
+	if err != nil || id <= 0 {
+		return 0, fmt.Errorf("%w: request id %q", ErrUsage, raw)
+	}
+
+	return id, nil
+}
@@ -0,0 +1,105 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"botex/pkg/auth"
+)
+
+const dryRunFlag = "--dry-run"
+
+// authExport writes the snapshot to stdout as JSON, or to a file in the
+// format its extension names.
+func (a *app) authExport(ctx context.Context, args []string) error {
+	err := requireArgs(args, 0, 1)
+	if err != nil {
+		return err
+	}
+
+	format := auth.FormatJSON
+
+	if len(args) == 1 {
+		format, err = auth.ParseSnapshotFormat(filepath.Ext(args[0]))
+		if err != nil {
+			return err
+		}
+	}
+
+	snapshot, err := a.service.Export(ctx)
+	if err != nil {
+		return fmt.Errorf("export: %w", err)
+	}
+
+	data, err := auth.MarshalSnapshot(snapshot, format)
+	if err != nil {
+		return err
+	}
+
+	if len(args) == 0 {
+		_, err = a.out.Write(append(data, '\n'))
+
+		return err
+	}
+
+	err = os.WriteFile(args[0], data, 0o600)
+	if err != nil {
+		return fmt.Errorf("write %s: %w", args[0], err)
+	}
+
+	fmt.Fprintf(a.out, "Exported %d rank(s), %d user(s) and %d group(s) to %s\n",
+		len(snapshot.Ranks), len(snapshot.Users), len(snapshot.Groups), args[0])
+
+	return nil
+}
+
+// authImport applies a snapshot, or with --dry-run lists what it would change.
+func (a *app) authImport(ctx context.Context, args []string) error {
+	dryRun := len(args) > 0 && args[0] == dryRunFlag
+	if dryRun {
+		args = args[1:]
+	}
+
+	err := requireArgs(args, 1, 1)
+	if err != nil {
+		return err
+	}
+
+	format, err := auth.ParseSnapshotFormat(filepath.Ext(args[0]))
+	if err != nil {
+		return err
+	}
+
+	data, err := os.ReadFile(args[0])
+	if err != nil {
+		return fmt.Errorf("read %s: %w", args[0], err)
+	}
+
+	snapshot, err := auth.UnmarshalSnapshot(data, format)
+	if err != nil {
+		return err
+	}
+
+	changes, err := a.service.Import(ctx, snapshot, Actor, dryRun)
+
+	for _, change := range changes {
+		fmt.Fprintln(a.out, change)
+	}
+
+	if err != nil {
+		return fmt.Errorf("import: %w", err)
+	}
+
+	switch {
+	case len(changes) == 0:
+		fmt.Fprintln(a.out, "Nothing to import, database already matches")
+	case dryRun:
+		fmt.Fprintf(a.out, "Dry run: %d change(s) not applied\n", len(changes))
+	default:
+		fmt.Fprintf(a.out, "Applied %d change(s)\n", len(changes))
+	}
+
+	return nil
+}
@@ -0,0 +1,159 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"botex/pkg/util"
+)
+
+func (a *app) userAdd(ctx context.Context, args []string) error {
+	err := requireArgs(args, 1, 3)
+	if err != nil {
+		return err
+	}
+
+	userID, err := ParseUserID(args[0])
+	if err != nil {
+		return err
+	}
+
+	rank := a.cfg.Auth.DefaultUserRank
+	if len(args) > 1 {
+		rank = args[1]
+	}
+
+	var expiresAt *time.Time
+
+	if len(args) > 2 {
+		expiresAt, err = parseExpiry(args[2])
+		if err != nil {
+			return err
+		}
+	}
+
+	err = a.service.RegisterUser(ctx, userID, rank, expiresAt, Actor)
+	if err != nil {
+		return fmt.Errorf("register user: %w", err)
+	}
+
+	fmt.Fprintf(a.out, "Registered %s as %s%s\n", userID, rank, util.UntilText(expiresAt))
+
+	return nil
+}
+
+func (a *app) userList(ctx context.Context, args []string) error {
+	err := requireArgs(args, 0, 0)
+	if err != nil {
+		return err
+	}
+
+	users, err := a.service.ListUsers(ctx)
+	if err != nil {
+		return fmt.Errorf("list users: %w", err)
+	}
+
+	table := a.table()
+	fmt.Fprintln(table, "USER\tRANK\tREGISTERED\tREGISTERED BY\tEXPIRES")
+
+	for _, user := range users {
+		expires := "-"
+		if user.ExpiresAt != nil {
+			expires = user.ExpiresAt.Local().Format(time.DateTime)
+			if user.PreviousRank != "" {
+				expires += " (then " + user.PreviousRank + ")"
+			}
+		}
+
+		fmt.Fprintf(table, "%s\t%s\t%s\t%s\t%s\n",
+			user.ID, user.Rank, user.RegisteredAt.Format(time.DateTime), user.RegisteredBy, expires)
+	}
+
+	return table.Flush()
+}
+
+func (a *app) userRemove(ctx context.Context, args []string) error {
+	err := requireArgs(args, 1, 1)
+	if err != nil {
+		return err
+	}
+
+	userID, err := ParseUserID(args[0])
+	if err != nil {
+		return err
+	}
+
+	err = a.service.DeactivateUser(ctx, userID, Actor)
+	if err != nil {
+		return fmt.Errorf("deactivate user: %w", err)
+	}
+
+	fmt.Fprintf(a.out, "Deactivated %s\n", userID)
+
+	return nil
+}
+
+// userUnban lifts a ban and restores a deactivated registration, like
+// !unban. It is the way back for an owner nobody in the chat can unban.
+func (a *app) userUnban(ctx context.Context, args []string) error {
+	err := requireArgs(args, 1, 1)
+	if err != nil {
+		return err
+	}
+
+	userID, err := ParseUserID(args[0])
+	if err != nil {
+		return err
+	}
+
+	err = a.service.ReactivateUser(ctx, userID, Actor)
+	if err != nil {
+		return fmt.Errorf("unban user: %w", err)
+	}
+
+	fmt.Fprintf(a.out, "Unbanned %s\n", userID)
+
+	return nil
+}
+
+func (a *app) userSetRank(ctx context.Context, args []string) error {
+	err := requireArgs(args, 2, 3)
+	if err != nil {
+		return err
+	}
+
+	userID, err := ParseUserID(args[0])
+	if err != nil {
+		return err
+	}
+
+	var expiresAt *time.Time
+
+	if len(args) > 2 {
+		expiresAt, err = parseExpiry(args[2])
+		if err != nil {
+			return err
+		}
+	}
+
+	err = a.service.SetUserRank(ctx, userID, args[1], expiresAt, Actor)
+	if err != nil {
+		return fmt.Errorf("set user rank: %w", err)
+	}
+
+	fmt.Fprintf(a.out, "%s is now %s%s\n", userID, args[1], util.UntilText(expiresAt))
+
+	return nil
+}
+
+// parseExpiry is util.ParseExpiry failing with ErrUsage, so the usage line
+// is printed.
+func parseExpiry(raw string) (*time.Time, error) {
+	expiresAt, err := util.ParseExpiry(raw)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrUsage, err)
+	}
+
+	return expiresAt, nil
+}
@@ -0,0 +1,56 @@
+package commands
+
+import (
+	"strings"
+	"time"
+
+	"botex/pkg/message"
+	"botex/pkg/util"
+	"go.mau.fi/whatsmeow/types"
+)
+
+var (
+	ErrInvalidTarget   = util.ErrInvalidUserID
+	ErrInvalidDuration = util.ErrInvalidDuration
+)
+
+// resolveTargetUser picks the user a management command refers to.
+// A mention always wins over the typed argument, because WhatsApp renders
+// mentions as "@<display name>" in the text and the real JID only lives in
+// the message's ContextInfo.
+func resolveTargetUser(msg *message.Message, arg string) (types.JID, error) {
+	if mentions := msg.MentionedJIDs(); len(mentions) > 0 {
+		return normalizeUserJID(mentions[0]), nil
+	}
+
+	return util.ParseUserJID(arg)
+}
+
+// normalizeUserJID drops the device part so the same person always maps to
+// the same users.user_id row.
+func normalizeUserJID(jid types.JID) types.JID {
+	return jid.ToNonAD()
+}
+
+// splitArgs separates the first word from the rest of the arguments.
+func splitArgs(text string) (string, []string) {
+	fields := strings.Fields(text)
+	if len(fields) == 0 {
+		return "", nil
+	}
+
+	return fields[0], fields[1:]
+}
+
+// splitExpiry takes an optional trailing duration ("3d", "12h") off the
+// arguments and turns it into an expiry time.
+func splitExpiry(args []string) ([]string, *time.Time) {
+	if n := len(args); n > 0 {
+		until, err := util.ParseExpiry(args[n-1])
+		if err == nil {
+			return args[:n-1], until
+		}
+	}
+
+	return args, nil
+}
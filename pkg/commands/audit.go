@@ -0,0 +1,163 @@
+package commands
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"botex/pkg/auth"
+	"botex/pkg/config"
+	"botex/pkg/logger"
+	"botex/pkg/message"
+	"botex/pkg/util"
+	"go.mau.fi/whatsmeow"
+	"go.mau.fi/whatsmeow/types"
+)
+
+const auditUsage = "Usage: `!audit [@mention|<number>|group [<group id>]] [count]`"
+
+type AuditCommand struct {
+	config        *config.Config
+	messageSender *message.MessageSender
+	authService   auth.Auth
+	logger        *logger.Logger
+}
+
+func NewAuditCommand(client *whatsmeow.Client, cfg *config.Config, authService auth.Auth, loggerFactory *logger.Factory) *AuditCommand {
+	return &AuditCommand{
+		config:        cfg,
+		messageSender: message.NewMessageSender(client),
+		authService:   authService,
+		logger:        loggerFactory.GetLogger("audit-command"),
+	}
+}
+
+func (ac *AuditCommand) Name() string {
+	return "audit"
+}
+
+func (ac *AuditCommand) Info() CommandInfo {
+	return CommandInfo{
+		Description: "Show recent registrations, rank changes, bans and denied commands",
+		Usage:       "!audit [@mention|<number>|group] [count]",
+		Examples: []string{
+			"!audit",
+			"!audit @Alice",
+			"!audit 51999888777 20",
+			"!audit group 5",
+		},
+	}
+}
+
+func (ac *AuditCommand) Handle(ctx context.Context, msg *message.Message) error {
+	filter, err := parseAuditFilter(msg, strings.Fields(msg.Text))
+	if err != nil {
+		return reply(ctx, ac.messageSender, msg, auditUsage, err)
+	}
+
+	events, err := ac.authService.ListAuditEvents(ctx, filter)
+	if err != nil {
+		ac.logger.Error("Failed to list audit events", map[string]interface{}{
+			"user":  filter.UserID,
+			"group": filter.GroupID,
+			"error": err.Error(),
+		})
+
+		return reply(ctx, ac.messageSender, msg, authErrorMessage(err, ""), err)
+	}
+
+	if len(events) == 0 {
+		return reply(ctx, ac.messageSender, msg, "No audit events found.", nil)
+	}
+
+	var builder strings.Builder
+	builder.WriteString("*Audit log* (newest first)\n\n")
+
+	for _, event := range events {
+		builder.WriteString(formatAuditEvent(event))
+		builder.WriteString("\n")
+	}
+
+	return reply(ctx, ac.messageSender, msg, builder.String(), nil)
+}
+
+// parseAuditFilter reads an optional target (user or group) followed by an
+// optional count. Counts are told apart from phone numbers by their length.
+func parseAuditFilter(msg *message.Message, args []string) (auth.AuditFilter, error) {
+	var filter auth.AuditFilter
+
+	if n := len(args); n > 0 && len(args[n-1]) < util.MinPhoneDigits {
+		limit, err := strconv.Atoi(args[n-1])
+		if err == nil {
+			if limit <= 0 {
+				return filter, fmt.Errorf("%w: count %q", ErrInvalidCommandInput, args[n-1])
+			}
+
+			filter.Limit = limit
+			args = args[:n-1]
+		}
+	}
+
+	switch {
+	case len(msg.MentionedJIDs()) > 0 || (len(args) > 0 && !isGroupArg(args[0])):
+		target, err := resolveTargetUser(msg, strings.Join(args, " "))
+		if err != nil {
+			return filter, err
+		}
+
+		filter.UserID = target.String()
+	case len(args) > 0:
+		groupArg := ""
+		if len(args) > 1 {
+			groupArg = args[1]
+		} else if !strings.EqualFold(args[0], "group") {
+			groupArg = args[0]
+		}
+
+		group, err := resolveTargetGroup(msg, groupArg)
+		if err != nil {
+			return filter, err
+		}
+
+		filter.GroupID = group.String()
+	}
+
+	return filter, nil
+}
+
+func isGroupArg(arg string) bool {
+	return strings.EqualFold(arg, "group") || strings.HasSuffix(arg, "@"+types.GroupServer)
+}
+
+func formatAuditEvent(event *auth.AuditEvent) string {
+	var builder strings.Builder
+
+	builder.WriteString(fmt.Sprintf("• %s *%s* by %s",
+		event.CreatedAt.Format(time.DateTime), event.Action, displayID(event.ActorID)))
+
+	if event.TargetID != "" && event.TargetID != event.GroupID {
+		builder.WriteString(" → " + displayID(event.TargetID))
+	}
+
+	if event.Command != "" {
+		builder.WriteString(" `!" + event.Command + "`")
+	}
+
+	if event.Reason != "" {
+		builder.WriteString(": " + event.Reason)
+	}
+
+	if event.GroupID != "" {
+		builder.WriteString(" (in " + event.GroupID + ")")
+	}
+
+	return builder.String()
+}
+
+// displayID shortens user JIDs to the phone number; groups, ranks and
+// system actors are shown as stored.
+func displayID(id string) string {
+	return strings.TrimSuffix(id, "@"+types.DefaultUserServer)
+}
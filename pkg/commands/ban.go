@@ -0,0 +1,158 @@
+package commands
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"botex/pkg/auth"
+	"botex/pkg/config"
+	"botex/pkg/logger"
+	"botex/pkg/message"
+	"botex/pkg/util"
+	"go.mau.fi/whatsmeow"
+)
+
+const (
+	banUsage   = "Usage: `!ban @mention|<number> [duration] [reason]`"
+	unbanUsage = "Usage: `!unban @mention|<number>`"
+)
+
+type BanCommand struct {
+	config        *config.Config
+	messageSender *message.MessageSender
+	authService   auth.Auth
+	logger        *logger.Logger
+}
+
+func NewBanCommand(client *whatsmeow.Client, cfg *config.Config, authService auth.Auth, loggerFactory *logger.Factory) *BanCommand {
+	return &BanCommand{
+		config:        cfg,
+		messageSender: message.NewMessageSender(client),
+		authService:   authService,
+		logger:        loggerFactory.GetLogger("ban-command"),
+	}
+}
+
+func (bc *BanCommand) Name() string {
+	return "ban"
+}
+
+func (bc *BanCommand) Info() CommandInfo {
+	return CommandInfo{
+		Description: "Block a user from all commands, optionally for a limited time",
+		Usage:       "!ban @mention|<number> [duration] [reason]",
+		Examples: []string{
+			"!ban @Alice spamming renders",
+			"!ban 51999888777 7d flooding the group",
+		},
+	}
+}
+
+func (bc *BanCommand) Handle(ctx context.Context, msg *message.Message) error {
+	targetArg, rest := splitArgs(msg.Text)
+
+	target, err := resolveTargetUser(msg, targetArg)
+	if err != nil {
+		return reply(ctx, bc.messageSender, msg, banUsage, err)
+	}
+
+	// the duration is optional, so a first word that does not parse as one
+	// is simply the start of the reason.
+	var expiresAt *time.Time
+
+	if len(rest) > 0 {
+		duration, durationErr := util.ParseDuration(rest[0])
+		if durationErr == nil {
+			until := time.Now().Add(duration)
+			expiresAt = &until
+			rest = rest[1:]
+		}
+	}
+
+	reason := strings.Join(rest, " ")
+	actor := normalizeUserJID(msg.Sender).String()
+
+	err = bc.authService.BanUser(ctx, target.String(), reason, expiresAt, actor)
+	if err != nil {
+		bc.logger.Warn("Ban failed", map[string]interface{}{
+			"target": target.String(),
+			"actor":  actor,
+			"error":  err.Error(),
+		})
+
+		return reply(ctx, bc.messageSender, msg, authErrorMessage(err, ""), err)
+	}
+
+	bc.logger.Info("User banned", map[string]interface{}{
+		"target":    target.String(),
+		"actor":     actor,
+		"reason":    reason,
+		"expiresAt": expiresAt,
+	})
+
+	text := fmt.Sprintf("%s is banned permanently.", target.User)
+	if expiresAt != nil {
+		text = fmt.Sprintf("%s is banned until %s.", target.User, expiresAt.Format(time.DateTime))
+	}
+
+	return reply(ctx, bc.messageSender, msg, text, nil)
+}
+
+type UnbanCommand struct {
+	config        *config.Config
+	messageSender *message.MessageSender
+	authService   auth.Auth
+	logger        *logger.Logger
+}
+
+func NewUnbanCommand(client *whatsmeow.Client, cfg *config.Config, authService auth.Auth, loggerFactory *logger.Factory) *UnbanCommand {
+	return &UnbanCommand{
+		config:        cfg,
+		messageSender: message.NewMessageSender(client),
+		authService:   authService,
+		logger:        loggerFactory.GetLogger("unban-command"),
+	}
+}
+
+func (uc *UnbanCommand) Name() string {
+	return "unban"
+}
+
+func (uc *UnbanCommand) Info() CommandInfo {
+	return CommandInfo{
+		Description: "Lift a ban and restore a deactivated registration",
+		Usage:       "!unban @mention|<number>",
+		Examples:    []string{"!unban @Alice", "!unban 51999888777"},
+	}
+}
+
+func (uc *UnbanCommand) Handle(ctx context.Context, msg *message.Message) error {
+	targetArg, _ := splitArgs(msg.Text)
+
+	target, err := resolveTargetUser(msg, targetArg)
+	if err != nil {
+		return reply(ctx, uc.messageSender, msg, unbanUsage, err)
+	}
+
+	actor := normalizeUserJID(msg.Sender).String()
+
+	err = uc.authService.ReactivateUser(ctx, target.String(), actor)
+	if err != nil {
+		uc.logger.Warn("Unban failed", map[string]interface{}{
+			"target": target.String(),
+			"actor":  actor,
+			"error":  err.Error(),
+		})
+
+		return reply(ctx, uc.messageSender, msg, authErrorMessage(err, ""), err)
+	}
+
+	uc.logger.Info("User reactivated", map[string]interface{}{
+		"target": target.String(),
+		"actor":  actor,
+	})
+
+	return reply(ctx, uc.messageSender, msg, fmt.Sprintf("%s can use the bot again.", target.User), nil)
+}
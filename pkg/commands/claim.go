@@ -0,0 +1,79 @@
+package commands
+
+import (
+	"context"
+	"errors"
+	"strings"
+
+	"botex/pkg/auth"
+	"botex/pkg/config"
+	"botex/pkg/logger"
+	"botex/pkg/message"
+	"go.mau.fi/whatsmeow"
+)
+
+const claimUsage = "Usage: `!claim <code>` in a private chat with the bot"
+
+// ClaimCommand turns the first person presenting the startup claim code into
+// the bot's owner. It is open to unregistered users.
+type ClaimCommand struct {
+	config        *config.Config
+	messageSender *message.MessageSender
+	authService   auth.Auth
+	logger        *logger.Logger
+}
+
+func NewClaimCommand(client *whatsmeow.Client, cfg *config.Config, authService auth.Auth, loggerFactory *logger.Factory) *ClaimCommand {
+	return &ClaimCommand{
+		config:        cfg,
+		messageSender: message.NewMessageSender(client),
+		authService:   authService,
+		logger:        loggerFactory.GetLogger("claim-command"),
+	}
+}
+
+func (cc *ClaimCommand) Name() string {
+	return "claim"
+}
+
+func (cc *ClaimCommand) Info() CommandInfo {
+	return CommandInfo{
+		Description: "Become the bot owner with the code printed at first startup",
+		Usage:       "!claim <code>",
+		Examples:    []string{"!claim ABCDEFGHIJKLMNOP"},
+	}
+}
+
+func (cc *ClaimCommand) Handle(ctx context.Context, msg *message.Message) error {
+	if msg.IsGroup {
+		return reply(ctx, cc.messageSender, msg, claimUsage, ErrInvalidCommandInput)
+	}
+
+	code := strings.TrimSpace(msg.Text)
+	if code == "" {
+		return reply(ctx, cc.messageSender, msg, claimUsage, ErrInvalidCommandInput)
+	}
+
+	sender := normalizeUserJID(msg.Sender).String()
+
+	err := cc.authService.ClaimOwner(ctx, sender, code)
+	if err != nil {
+		cc.logger.Warn("Owner claim failed", map[string]interface{}{
+			"sender": sender,
+			"error":  err.Error(),
+		})
+
+		text := authErrorMessage(err, "")
+		if errors.Is(err, auth.ErrInvalidClaimCode) {
+			text = "That claim code is not valid."
+		}
+
+		return reply(ctx, cc.messageSender, msg, text, err)
+	}
+
+	cc.logger.Info("Bot ownership claimed", map[string]interface{}{
+		"owner": sender,
+	})
+
+	return reply(ctx, cc.messageSender, msg, "You are now the owner of this bot.", nil)
+}
@@ -0,0 +1,46 @@
+package commands
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"botex/pkg/auth"
+	"botex/pkg/logger"
+	"botex/pkg/message"
+	"go.mau.fi/whatsmeow"
+)
+
+// ExpiryNotice implements auth.ExpiryNotifier by messaging the user directly.
+type ExpiryNotice struct {
+	messageSender *message.MessageSender
+	logger        *logger.Logger
+}
+
+func NewExpiryNotice(client *whatsmeow.Client, loggerFactory *logger.Factory) *ExpiryNotice {
+	return &ExpiryNotice{
+		messageSender: message.NewMessageSender(client),
+		logger:        loggerFactory.GetLogger("expiry-notice"),
+	}
+}
+
+func (en *ExpiryNotice) NotifyExpiring(ctx context.Context, user *auth.User) error {
+	text := fmt.Sprintf("Your *%s* access to this bot ends on %s.",
+		user.Rank, user.ExpiresAt.Local().Format(time.DateTime))
+	if user.PreviousRank != "" {
+		text += fmt.Sprintf(" You will be back to *%s* afterwards.", user.PreviousRank)
+	}
+
+	err := sendDirect(ctx, en.messageSender, user.ID, text)
+	if err != nil {
+		return fmt.Errorf("failed to send expiry notice: %w", err)
+	}
+
+	en.logger.Info("Expiry notice sent", map[string]interface{}{
+		"user":      user.ID,
+		"rank":      user.Rank,
+		"expiresAt": user.ExpiresAt,
+	})
+
+	return nil
+}
@@ -0,0 +1,94 @@
+package commands
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"botex/pkg/auth"
+	"botex/pkg/config"
+	"botex/pkg/logger"
+	"botex/pkg/message"
+	"go.mau.fi/whatsmeow"
+)
+
+const exportAuthUsage = "Usage: `!export_auth [json|yaml]` in a private chat with the bot"
+
+// ExportAuthCommand sends the permission setup as a document that
+// `botex auth import` can load. It is not seeded into any rank, so only the
+// owner's wildcard grants it.
+type ExportAuthCommand struct {
+	config        *config.Config
+	messageSender *message.MessageSender
+	authService   auth.Auth
+	logger        *logger.Logger
+}
+
+func NewExportAuthCommand(client *whatsmeow.Client, cfg *config.Config, authService auth.Auth, loggerFactory *logger.Factory) *ExportAuthCommand {
+	return &ExportAuthCommand{
+		config:        cfg,
+		messageSender: message.NewMessageSender(client),
+		authService:   authService,
+		logger:        loggerFactory.GetLogger("export-auth-command"),
+	}
+}
+
+func (ec *ExportAuthCommand) Name() string {
+	return "export_auth"
+}
+
+func (ec *ExportAuthCommand) Info() CommandInfo {
+	return CommandInfo{
+		Description: "Export users, ranks and groups as a JSON or YAML document",
+		Usage:       "!export_auth [json|yaml]",
+		Examples:    []string{"!export_auth", "!export_auth yaml"},
+	}
+}
+
+func (ec *ExportAuthCommand) Handle(ctx context.Context, msg *message.Message) error {
+	// The export lists every registered number, so keep it out of groups.
+	if msg.IsGroup {
+		return reply(ctx, ec.messageSender, msg, exportAuthUsage, ErrInvalidCommandInput)
+	}
+
+	formatArg := strings.TrimSpace(msg.Text)
+	if formatArg == "" {
+		formatArg = string(auth.FormatJSON)
+	}
+
+	format, err := auth.ParseSnapshotFormat(formatArg)
+	if err != nil {
+		return reply(ctx, ec.messageSender, msg, exportAuthUsage, fmt.Errorf("%w: %w", ErrInvalidCommandInput, err))
+	}
+
+	snapshot, err := ec.authService.Export(ctx)
+	if err != nil {
+		ec.logger.Error("Failed to export permissions", map[string]interface{}{
+			"error": err.Error(),
+		})
+
+		return reply(ctx, ec.messageSender, msg, authErrorMessage(err, ""), err)
+	}
+
+	data, err := auth.MarshalSnapshot(snapshot, format)
+	if err != nil {
+		return reply(ctx, ec.messageSender, msg, authErrorMessage(err, ""), err)
+	}
+
+	filename := fmt.Sprintf("botex-auth-%s.%s", snapshot.ExportedAt.Format("20060102"), format)
+
+	err = ec.messageSender.SendDocument(ctx, msg.Recipient, data, filename, "application/"+string(format))
+	if err != nil {
+		return fmt.Errorf("failed to send export: %w", err)
+	}
+
+	ec.logger.Info("Permissions exported", map[string]interface{}{
+		"sender": msg.Sender.String(),
+		"users":  len(snapshot.Users),
+		"ranks":  len(snapshot.Ranks),
+		"groups": len(snapshot.Groups),
+		"format": string(format),
+	})
+
+	return nil
+}
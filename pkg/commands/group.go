@@ -0,0 +1,220 @@
+package commands
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"botex/pkg/auth"
+	"botex/pkg/config"
+	"botex/pkg/logger"
+	"botex/pkg/message"
+	"go.mau.fi/whatsmeow"
+)
+
+const groupUsage = "Usage: `!group allow|deny <command>`, `!group require <command> <rank>`, " +
+	"`!group reset <command>` or `!group show`"
+
+// GroupCommand manages the policy overrides of the group it is sent in.
+type GroupCommand struct {
+	config        *config.Config
+	messageSender *message.MessageSender
+	authService   auth.Auth
+	logger        *logger.Logger
+}
+
+func NewGroupCommand(client *whatsmeow.Client, cfg *config.Config, authService auth.Auth, loggerFactory *logger.Factory) *GroupCommand {
+	return &GroupCommand{
+		config:        cfg,
+		messageSender: message.NewMessageSender(client),
+		authService:   authService,
+		logger:        loggerFactory.GetLogger("group-command"),
+	}
+}
+
+func (gc *GroupCommand) Name() string {
+	return "group"
+}
+
+func (gc *GroupCommand) Info() CommandInfo {
+	return CommandInfo{
+		Description: "Override which commands are available in this group",
+		Usage:       "!group allow|deny|require|reset|show ...",
+		Examples: []string{
+			"!group allow latex",
+			"!group deny latex",
+			"!group require latex admin",
+			"!group reset latex",
+			"!group show",
+		},
+	}
+}
+
+func (gc *GroupCommand) Handle(ctx context.Context, msg *message.Message) error {
+	if !msg.IsGroup {
+		return reply(ctx, gc.messageSender, msg, "This command only works inside a group.", ErrGroupRequired)
+	}
+
+	sub, args := splitArgs(msg.Text)
+	groupID := msg.GroupID.String()
+	actor := normalizeUserJID(msg.Sender).String()
+
+	var (
+		text string
+		err  error
+	)
+
+	switch strings.ToLower(sub) {
+	case "show":
+		text, err = gc.show(ctx, groupID)
+	case "allow":
+		text, err = gc.setEffect(ctx, groupID, args, actor, auth.PolicyAllow)
+	case "deny":
+		text, err = gc.setEffect(ctx, groupID, args, actor, auth.PolicyDeny)
+	case "require":
+		text, err = gc.require(ctx, groupID, args, actor)
+	case "reset":
+		text, err = gc.reset(ctx, groupID, args, actor)
+	default:
+		return reply(ctx, gc.messageSender, msg, groupUsage, fmt.Errorf("%w: %q", ErrUnknownSubcommand, sub))
+	}
+
+	if err != nil {
+		gc.logger.Warn("Group policy command failed", map[string]interface{}{
+			"subcommand": sub,
+			"group":      groupID,
+			"args":       args,
+			"actor":      actor,
+			"error":      err.Error(),
+		})
+
+		return reply(ctx, gc.messageSender, msg, groupErrorMessage(err), err)
+	}
+
+	return reply(ctx, gc.messageSender, msg, text, nil)
+}
+
+func (gc *GroupCommand) show(ctx context.Context, groupID string) (string, error) {
+	policies, err := gc.authService.ListGroupPolicies(ctx, groupID)
+	if err != nil {
+		return "", fmt.Errorf("list group policies: %w", err)
+	}
+
+	if len(policies) == 0 {
+		return "No overrides in this group. Rank permissions apply.", nil
+	}
+
+	var builder strings.Builder
+	builder.WriteString("*Group overrides*\n\n")
+
+	for _, policy := range policies {
+		builder.WriteString(fmt.Sprintf("• *%s*: %s", policy.Command, policy.Effect))
+
+		if policy.MinRank != "" {
+			builder.WriteString(fmt.Sprintf(", requires %s or higher", policy.MinRank))
+		}
+
+		builder.WriteString("\n")
+	}
+
+	return builder.String(), nil
+}
+
+func (gc *GroupCommand) setEffect(ctx context.Context, groupID string, args []string, actor string, effect auth.PolicyEffect) (string, error) {
+	if len(args) < 1 {
+		return "", ErrInvalidCommandInput
+	}
+
+	policy, err := gc.currentPolicy(ctx, groupID, strings.ToLower(args[0]))
+	if err != nil {
+		return "", err
+	}
+
+	policy.Effect = effect
+
+	err = gc.authService.SetGroupPolicy(ctx, policy, actor)
+	if err != nil {
+		return "", fmt.Errorf("set group policy: %w", err)
+	}
+
+	if effect == auth.PolicyAllow {
+		return fmt.Sprintf("`!%s` is now open to everyone in this group.", policy.Command), nil
+	}
+
+	return fmt.Sprintf("`!%s` is now disabled in this group.", policy.Command), nil
+}
+
+func (gc *GroupCommand) require(ctx context.Context, groupID string, args []string, actor string) (string, error) {
+	if len(args) < 2 {
+		return "", ErrInvalidCommandInput
+	}
+
+	policy, err := gc.currentPolicy(ctx, groupID, strings.ToLower(args[0]))
+	if err != nil {
+		return "", err
+	}
+
+	policy.MinRank = strings.ToLower(args[1])
+
+	err = gc.authService.SetGroupPolicy(ctx, policy, actor)
+	if err != nil {
+		return "", fmt.Errorf("set group policy: %w", err)
+	}
+
+	return fmt.Sprintf("`!%s` now requires *%s* or higher in this group.", policy.Command, policy.MinRank), nil
+}
+
+func (gc *GroupCommand) reset(ctx context.Context, groupID string, args []string, actor string) (string, error) {
+	if len(args) < 1 {
+		return "", ErrInvalidCommandInput
+	}
+
+	command := strings.ToLower(args[0])
+
+	err := gc.authService.DeleteGroupPolicy(ctx, groupID, command, actor)
+	if err != nil {
+		return "", fmt.Errorf("delete group policy: %w", err)
+	}
+
+	return fmt.Sprintf("`!%s` follows rank permissions again.", command), nil
+}
+
+// currentPolicy returns the existing override for a command, or a fresh
+// inheriting one, so allow/deny and require can be combined.
+func (gc *GroupCommand) currentPolicy(ctx context.Context, groupID, command string) (*auth.GroupPolicy, error) {
+	policies, err := gc.authService.ListGroupPolicies(ctx, groupID)
+	if err != nil {
+		return nil, fmt.Errorf("list group policies: %w", err)
+	}
+
+	for _, policy := range policies {
+		if policy.Command == command {
+			return policy, nil
+		}
+	}
+
+	return &auth.GroupPolicy{
+		GroupID: groupID,
+		Command: command,
+		Effect:  auth.PolicyInherit,
+	}, nil
+}
+
+func groupErrorMessage(err error) string {
+	switch {
+	case errors.Is(err, ErrInvalidCommandInput):
+		return groupUsage
+	case errors.Is(err, auth.ErrPolicyNotFound):
+		return "There is no override for that command in this group."
+	case errors.Is(err, auth.ErrPolicyManagement):
+		return "Management commands cannot be opened to everyone in a group."
+	case errors.Is(err, auth.ErrRankEscalation):
+		return "You can only open commands you have, cannot require a rank above your own, " +
+			"and cannot change overrides set by a higher rank."
+	case errors.Is(err, auth.ErrInvalidInput):
+		return "Invalid command name. The `group` command itself cannot be overridden."
+	default:
+		return authErrorMessage(err, "")
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"math"
 	"strings"
 	"time"
 
@@ -14,11 +15,13 @@ import (
 	"botex/pkg/ratelimit"
 	"botex/pkg/timing"
 	"go.mau.fi/whatsmeow"
+	"go.mau.fi/whatsmeow/types"
 	"go.mau.fi/whatsmeow/types/events"
 )
 
 const (
 	defaultCommandTimeout = 30 * time.Second
+	rateStateLoadTimeout  = 30 * time.Second
 	concurrentLimitMsg    = "Too many concurrent requests. Please try again later."
 )
 
@@ -64,15 +67,34 @@ type CommandHandler struct {
 	messageSender *message.MessageSender
 	logger        *logger.Logger
 	rateService   *ratelimit.RateLimitService
+	penalties     *ratelimit.Penalties
 	semaphore     chan struct{}
 	timeTracker   *timing.Tracker
 	authService   auth.Auth
+
+	// denialNotifier spaces out answers to denials that strangers can
+	// trigger at will (see quietDenial).
+	denialNotifier *ratelimit.Notifier
+
+	// ratePersister saves limiter and notifier state, every flush interval
+	// (ratePersistence) and on Close, so quotas survive restarts.
+	ratePersister   *ratelimit.Persister
+	ratePersistence *ratelimit.AutoCleaner
 }
 
-func NewCommandHandler(client *whatsmeow.Client, cfg *config.Config, registry *CommandRegistry, loggerFactory *logger.Factory, authService auth.Auth) (*CommandHandler, error) {
+func NewCommandHandler(
+	client *whatsmeow.Client, cfg *config.Config, registry *CommandRegistry, loggerFactory *logger.Factory,
+	authService auth.Auth, rateStore ratelimit.Store,
+) (*CommandHandler, error) {
 	cmdLogger := loggerFactory.GetLogger("command-handler")
 
+	algorithm, err := ratelimit.NewAlgorithm(cfg.RateLimit.Algorithm)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create rate limiter: %w", err)
+	}
+
 	limiter := ratelimit.NewLimiter(
+		algorithm,
 		cfg.RateLimit.Requests,
 		cfg.RateLimit.Period,
 	)
@@ -89,14 +111,50 @@ func NewCommandHandler(client *whatsmeow.Client, cfg *config.Config, registry *C
 		limiter,
 		notifier,
 		cleaner,
+		cfg.RateLimitPolicies(),
 		cmdLogger,
 	)
 
-	err := rateService.Start()
+	rateService.SetIdentities(authService)
+
+	var penalties *ratelimit.Penalties
+	if policy := cfg.PenaltyPolicy(); policy != nil {
+		penalties = ratelimit.NewPenalties(*policy)
+		rateService.SetPenalties(penalties, authService, auth.RateLimitActor)
+	}
+
+	err = rateService.Start()
 	if err != nil {
 		return nil, fmt.Errorf("failed to start rate limiter: %w", err)
 	}
 
+	denialNotifier := ratelimit.NewNotifier(cfg.Auth.DenialCooldown)
+	cleaner.Register(denialNotifier)
+
+	persister := ratelimit.NewPersister(rateStore, cmdLogger)
+	// the algorithm is part of the name: state of one cannot be read by another.
+	persister.Register("limiter:"+cfg.RateLimit.Algorithm, algorithm)
+	persister.Register("notifier", notifier)
+	persister.Register("denial-notifier", denialNotifier)
+
+	if penalties != nil {
+		persister.Register("penalties", penalties)
+	}
+
+	loadCtx, cancel := context.WithTimeout(context.Background(), rateStateLoadTimeout)
+	defer cancel()
+
+	// a failed restore only costs the quotas used before the restart.
+	loadErr := persister.Load(loadCtx)
+	if loadErr != nil {
+		cmdLogger.Warn("Failed to restore rate limit state", map[string]interface{}{
+			"error": loadErr.Error(),
+		})
+	}
+
+	persistence := ratelimit.NewAutoCleaner(cfg.RateLimit.FlushInterval)
+	persistence.Register(persister)
+
 	timeTracker := timing.NewTrackerFromConfig(cfg, loggerFactory.GetLogger("timing"))
 
 	handler := &CommandHandler{
@@ -106,9 +164,15 @@ func NewCommandHandler(client *whatsmeow.Client, cfg *config.Config, registry *C
 		messageSender: message.NewMessageSender(client),
 		logger:        cmdLogger,
 		rateService:   rateService,
+		penalties:     penalties,
 		semaphore:     make(chan struct{}, cfg.MaxConcurrent),
 		timeTracker:   timeTracker,
 		authService:   authService,
+
+		denialNotifier: denialNotifier,
+
+		ratePersister:   persister,
+		ratePersistence: persistence,
 	}
 
 	for _, cmd := range registry.commands {
@@ -127,7 +191,15 @@ func (h *CommandHandler) GetCommands() []Command {
 	return cmds
 }
 
+// Penalties returns the tracker for repeat rate limit offenders, or nil when
+// penalties are off.
+func (h *CommandHandler) Penalties() *ratelimit.Penalties {
+	return h.penalties
+}
+
 func (h *CommandHandler) Close() {
+	h.ratePersistence.Stop()
+	h.ratePersister.Cleanup()
 	h.rateService.Stop()
 }
 
@@ -144,14 +216,30 @@ func (h *CommandHandler) HandleEvent(evt interface{}) {
 		return
 	}
 
+	// unknown commands (typos, "!!!") are dropped before the permission
+	// check, so they never auto-register anyone or reach the audit log.
+	if _, known := h.commands[command]; !known {
+		h.logger.Debug("Ignoring unknown command", map[string]interface{}{
+			"command": command,
+			"sender":  msg.Sender,
+		})
+
+		return
+	}
+
 	ctx, cancel := context.WithTimeout(context.Background(), defaultCommandTimeout)
 	defer cancel()
 
-	if !h.checkPermission(ctx, msg, command) {
+	if msg.IsGroup {
+		ctx = auth.WithGroup(ctx, msg.GroupID.String())
+	}
+
+	userRank, allowed := h.checkPermission(ctx, msg, command)
+	if !allowed {
 		return
 	}
 
-	h.processCommand(ctx, msg, command)
+	h.processCommand(ctx, msg, command, userRank)
 }
 
 func (h *CommandHandler) extractCommand(msg *message.Message) (string, bool) {
@@ -174,7 +262,9 @@ func (h *CommandHandler) extractCommand(msg *message.Message) (string, bool) {
 	return parts[0], true
 }
 
-func (h *CommandHandler) checkPermission(ctx context.Context, msg *message.Message, command string) bool {
+// checkPermission reports whether the sender may run command, and their
+// rank when they have one.
+func (h *CommandHandler) checkPermission(ctx context.Context, msg *message.Message, command string) (string, bool) {
 	userID := msg.Sender.String()
 
 	groupID := ""
@@ -191,27 +281,28 @@ func (h *CommandHandler) checkPermission(ctx context.Context, msg *message.Messa
 			"error":    err.Error(),
 		})
 
-		return false
+		return "", false
 	}
 
 	if !permissionResult.Allowed {
 		h.logger.Info("Command permission denied", map[string]interface{}{
 			"command":   command,
 			"sender":    userID,
+			"code":      permissionResult.Code,
 			"reason":    permissionResult.Reason,
 			"user_rank": permissionResult.UserRank,
 		})
 		h.handlePermissionDenied(ctx, msg, command, permissionResult)
 
-		return false
+		return "", false
 	}
 
-	return true
+	return permissionResult.UserRank, true
 }
 
-func (h *CommandHandler) processCommand(ctx context.Context, msg *message.Message, command string) {
+func (h *CommandHandler) processCommand(ctx context.Context, msg *message.Message, command, userRank string) {
 	err := h.timeTracker.Track(ctx, "handle_command", timing.Basic, func(ctx context.Context) error {
-		rateLimitErr := h.rateService.Check(ctx, msg)
+		rateLimitErr := h.rateService.Check(ctx, msg, command, userRank)
 		if rateLimitErr != nil {
 			h.handleRateLimitError(ctx, msg, rateLimitErr)
 
@@ -257,7 +348,7 @@ func (h *CommandHandler) handleRateLimitError(ctx context.Context, msg *message.
 	}
 
 	if rateErr.Notify {
-		waitMsg := fmt.Sprintf("Too many requests. Please wait %d seconds.", int(rateErr.ResetAfter.Seconds()))
+		waitMsg := rateLimitMessage(rateErr)
 
 		textErr := h.messageSender.SendText(ctx, msg.Recipient, waitMsg)
 		if textErr != nil {
@@ -266,6 +357,26 @@ func (h *CommandHandler) handleRateLimitError(ctx context.Context, msg *message.
 	}
 }
 
+// rateLimitMessage tells the sender whose budget ran out and how long to wait,
+// and repeat offenders that the wait is growing or that they are now banned.
+func rateLimitMessage(rateErr *ratelimit.RateLimitError) string {
+	seconds := int(math.Ceil(rateErr.ResetAfter.Seconds()))
+
+	switch {
+	case rateErr.Banned:
+		return fmt.Sprintf("You kept going over the rate limit and are banned until %s.",
+			time.Now().Add(rateErr.ResetAfter).Format(time.DateTime))
+	case rateErr.Strikes > 1:
+		return fmt.Sprintf("You keep going over the rate limit, so the wait grows each time. Please wait %d seconds.", seconds)
+	case rateErr.Scope == ratelimit.ScopeGroup:
+		return fmt.Sprintf("This group is busy. Please wait %d seconds.", seconds)
+	case rateErr.Scope == ratelimit.ScopeGlobal:
+		return fmt.Sprintf("The bot is busy right now. Please try again in %d seconds.", seconds)
+	default:
+		return fmt.Sprintf("Too many requests. Please wait %d seconds.", seconds)
+	}
+}
+
 func (h *CommandHandler) acquireSemaphore(ctx context.Context) (func(), error) {
 	select {
 	case h.semaphore <- struct{}{}:
@@ -327,12 +438,36 @@ func (h *CommandHandler) handleConcurrencyLimit(ctx context.Context, msg *messag
 }
 
 func (h *CommandHandler) handlePermissionDenied(ctx context.Context, msg *message.Message, command string, result *auth.PermissionResult) {
+	mode := config.DenialModeReply
+
+	if quietDenial(result.Code) {
+		mode = h.denialMode(msg)
+		if mode == config.DenialModeIgnore || !h.denialNotifier.ShouldNotify(h.denialKey(msg, mode)) {
+			return
+		}
+	}
+
+	if mode == config.DenialModeDirect {
+		permissionMsg := h.createPermissionDeniedMessage(msg.IsGroup, command, result)
+
+		textErr := h.messageSender.SendText(ctx, msg.Sender.ToNonAD(), permissionMsg)
+		if textErr != nil {
+			h.logger.Error("Failed to send permission denied message", map[string]interface{}{"error": textErr.Error()})
+		}
+
+		return
+	}
+
 	reactionErr := h.messageSender.SendReaction(ctx, msg.Recipient, msg.MessageID, "🚫")
 	if reactionErr != nil {
 		h.logger.Error("Failed to send permission denied reaction", map[string]interface{}{"error": reactionErr.Error()})
 	}
 
-	permissionMsg := h.createPermissionDeniedMessage(msg.IsGroup, command, result.Reason)
+	if mode == config.DenialModeReact {
+		return
+	}
+
+	permissionMsg := h.createPermissionDeniedMessage(msg.IsGroup, command, result)
 
 	textErr := h.messageSender.SendText(ctx, msg.Recipient, permissionMsg)
 	if textErr != nil {
@@ -340,19 +475,90 @@ func (h *CommandHandler) handlePermissionDenied(ctx context.Context, msg *messag
 	}
 }
 
-func (h *CommandHandler) createPermissionDeniedMessage(isGroup bool, command, reason string) string {
-	switch reason {
-	case "User not registered":
+// quietDenial reports whether a denial follows the configured denial mode.
+// These are the ones anyone can trigger by typing "!" somewhere the bot
+// reads; registered users running a command they lack always get a reply.
+func quietDenial(code auth.DenialCode) bool {
+	switch code {
+	case auth.DenialNotRegistered, auth.DenialGroupNotRegistered, auth.DenialBanned:
+		return true
+	default:
+		return false
+	}
+}
+
+// denialMode returns the group's own denial mode, or the global one.
+func (h *CommandHandler) denialMode(msg *message.Message) string {
+	if msg.IsGroup {
+		mode, ok := h.config.Auth.DenialGroupModes[msg.GroupID.String()]
+		if ok {
+			return mode
+		}
+	}
+
+	return h.config.Auth.DenialMode
+}
+
+// denialKey picks what the denial cooldown counts against: the chat for
+// answers posted in a group, so a crowd of strangers gets one answer per
+// cooldown, and the sender for private chats and DMs.
+func (h *CommandHandler) denialKey(msg *message.Message, mode string) types.JID {
+	if msg.IsGroup && mode != config.DenialModeDirect {
+		return msg.GroupID
+	}
+
+	return msg.Sender.ToNonAD()
+}
+
+// createPermissionDeniedMessage explains a denial by its code. Unknown codes
+// get the generic message.
+func (h *CommandHandler) createPermissionDeniedMessage(isGroup bool, command string, result *auth.PermissionResult) string {
+	details := result.Details
+	if details == nil {
+		details = &auth.DenialDetails{}
+	}
+
+	switch result.Code {
+	case auth.DenialNotRegistered:
 		if isGroup {
 			return "You must be a registered user to use commands in this group. Please contact an admin."
 		}
 
 		return "You must be a registered user to use commands. Please contact an admin."
-	case "Group not registered":
+	case auth.DenialExpired:
+		return "Your access has expired. Please contact an admin to renew it."
+	case auth.DenialBanned:
+		if details.BannedUntil != nil {
+			return fmt.Sprintf("You are banned from using this bot until %s.", details.BannedUntil.Local().Format(time.DateTime))
+		}
+
+		return "You are banned from using this bot."
+	case auth.DenialGroupNotRegistered:
 		return "This group is not registered for bot usage. Please contact an admin."
-	case "Command not allowed for your rank":
+	case auth.DenialGroupPolicy:
+		if details.MinRank != "" {
+			return fmt.Sprintf("The command `!%s` requires rank *%s* or higher in this group.", command, details.MinRank)
+		}
+
+		return fmt.Sprintf("The command `!%s` is disabled in this group.", command)
+	case auth.DenialRankLacksCommand:
 		return fmt.Sprintf("The command `!%s` is not available for your rank.", command)
 	default:
 		return fmt.Sprintf("You do not have permission to use the `!%s` command.", command)
 	}
 }
+
+// reply sends text back to the chat the command came from and passes cause
+// through, so the handler still reacts with ❌ when the command failed.
+func reply(ctx context.Context, sender *message.MessageSender, msg *message.Message, text string, cause error) error {
+	err := sender.SendText(ctx, msg.Recipient, text)
+	if err != nil {
+		if cause != nil {
+			return fmt.Errorf("%w (reply failed: %w)", cause, err)
+		}
+
+		return fmt.Errorf("failed to send reply: %w", err)
+	}
+
+	return cause
+}
@@ -0,0 +1,255 @@
+package commands
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"botex/pkg/auth"
+	"botex/pkg/config"
+	"botex/pkg/logger"
+	"botex/pkg/message"
+	"botex/pkg/util"
+	"go.mau.fi/whatsmeow"
+)
+
+const (
+	inviteUsage = "Usage: `!invite create [rank=<rank>] [uses=<n>] [expires=<duration>]`, " +
+		"`!invite list` or `!invite revoke <code>|#<ref>`"
+	joinUsage = "Usage: `!join <code>`"
+
+	defaultInviteUses = 1
+)
+
+type InviteCommand struct {
+	config        *config.Config
+	messageSender *message.MessageSender
+	authService   auth.Auth
+	logger        *logger.Logger
+}
+
+func NewInviteCommand(client *whatsmeow.Client, cfg *config.Config, authService auth.Auth, loggerFactory *logger.Factory) *InviteCommand {
+	return &InviteCommand{
+		config:        cfg,
+		messageSender: message.NewMessageSender(client),
+		authService:   authService,
+		logger:        loggerFactory.GetLogger("invite-command"),
+	}
+}
+
+func (ic *InviteCommand) Name() string {
+	return "invite"
+}
+
+func (ic *InviteCommand) Info() CommandInfo {
+	return CommandInfo{
+		Description: "Create invite codes people can redeem with !join to register themselves",
+		Usage:       "!invite create|list|revoke ...",
+		Examples: []string{
+			"!invite create",
+			"!invite create rank=user uses=50 expires=7d",
+			"!invite list",
+			"!invite revoke ABCD2345",
+			"!invite revoke #3fa9c1",
+		},
+	}
+}
+
+func (ic *InviteCommand) Handle(ctx context.Context, msg *message.Message) error {
+	sub, args := splitArgs(msg.Text)
+	actor := normalizeUserJID(msg.Sender).String()
+
+	var (
+		text string
+		err  error
+	)
+
+	switch strings.ToLower(sub) {
+	case "create":
+		text, err = ic.create(ctx, args, actor)
+	case "list":
+		text, err = ic.list(ctx, actor)
+	case "revoke":
+		text, err = ic.revoke(ctx, args, actor)
+	default:
+		return reply(ctx, ic.messageSender, msg, inviteUsage, fmt.Errorf("%w: %q", ErrUnknownSubcommand, sub))
+	}
+
+	if err != nil {
+		ic.logger.Warn("Invite command failed", map[string]interface{}{
+			"subcommand": sub,
+			"args":       args,
+			"actor":      actor,
+			"error":      err.Error(),
+		})
+
+		return reply(ctx, ic.messageSender, msg, inviteErrorMessage(err), err)
+	}
+
+	return reply(ctx, ic.messageSender, msg, text, nil)
+}
+
+func (ic *InviteCommand) create(ctx context.Context, args []string, actor string) (string, error) {
+	rankName := ic.config.Auth.DefaultUserRank
+	uses := defaultInviteUses
+
+	var expiresAt *time.Time
+
+	for _, arg := range args {
+		key, value, ok := strings.Cut(arg, "=")
+		if !ok {
+			return "", fmt.Errorf("%w: %q", ErrInvalidCommandInput, arg)
+		}
+
+		switch strings.ToLower(key) {
+		case "rank":
+			rankName = strings.ToLower(value)
+		case "uses":
+			n, err := strconv.Atoi(value)
+			if err != nil {
+				return "", fmt.Errorf("%w: uses %q", ErrInvalidCommandInput, value)
+			}
+
+			uses = n
+		case "expires":
+			duration, err := util.ParseDuration(value)
+			if err != nil {
+				return "", fmt.Errorf("%w: %w", ErrInvalidCommandInput, err)
+			}
+
+			until := time.Now().Add(duration)
+			expiresAt = &until
+		default:
+			return "", fmt.Errorf("%w: %q", ErrInvalidCommandInput, arg)
+		}
+	}
+
+	invite, err := ic.authService.CreateInvite(ctx, rankName, uses, expiresAt, actor)
+	if err != nil {
+		return "", fmt.Errorf("create invite: %w", err)
+	}
+
+	ic.logger.Info("Invite created", map[string]interface{}{
+		"code":      invite.Code,
+		"rank":      invite.Rank,
+		"uses":      invite.MaxUses,
+		"expiresAt": expiresAt,
+		"actor":     actor,
+	})
+
+	return fmt.Sprintf("Invite code `%s` registers up to %d user(s) as *%s*%s. `!invite list` shows it as `%s`.\nShare: `!join %s`",
+		invite.Code, invite.MaxUses, invite.Rank, util.UntilText(expiresAt), auth.InviteRef(invite.Code), invite.Code), nil
+}
+
+func (ic *InviteCommand) list(ctx context.Context, actor string) (string, error) {
+	invites, err := ic.authService.ListInvites(ctx, actor)
+	if err != nil {
+		return "", fmt.Errorf("list invites: %w", err)
+	}
+
+	if len(invites) == 0 {
+		return "No active invite codes.", nil
+	}
+
+	var builder strings.Builder
+	builder.WriteString("*Invite codes*\n\n")
+
+	for _, invite := range invites {
+		builder.WriteString(fmt.Sprintf("• `%s` *%s* used %d/%d%s, by %s\n",
+			invite.Code, invite.Rank, invite.Uses, invite.MaxUses, util.UntilText(invite.ExpiresAt), displayID(invite.CreatedBy)))
+	}
+
+	return builder.String(), nil
+}
+
+func (ic *InviteCommand) revoke(ctx context.Context, args []string, actor string) (string, error) {
+	if len(args) != 1 {
+		return "", ErrInvalidCommandInput
+	}
+
+	err := ic.authService.RevokeInvite(ctx, args[0], actor)
+	if err != nil {
+		return "", fmt.Errorf("revoke invite: %w", err)
+	}
+
+	return fmt.Sprintf("Invite `%s` revoked.", args[0]), nil
+}
+
+// JoinCommand lets unregistered users register themselves with an invite
+// code. It is open to everyone.
+type JoinCommand struct {
+	config        *config.Config
+	messageSender *message.MessageSender
+	authService   auth.Auth
+	logger        *logger.Logger
+}
+
+func NewJoinCommand(client *whatsmeow.Client, cfg *config.Config, authService auth.Auth, loggerFactory *logger.Factory) *JoinCommand {
+	return &JoinCommand{
+		config:        cfg,
+		messageSender: message.NewMessageSender(client),
+		authService:   authService,
+		logger:        loggerFactory.GetLogger("join-command"),
+	}
+}
+
+func (jc *JoinCommand) Name() string {
+	return "join"
+}
+
+func (jc *JoinCommand) Info() CommandInfo {
+	return CommandInfo{
+		Description: "Register yourself with an invite code",
+		Usage:       "!join <code>",
+		Examples:    []string{"!join ABCD2345"},
+	}
+}
+
+func (jc *JoinCommand) Handle(ctx context.Context, msg *message.Message) error {
+	code := strings.TrimSpace(msg.Text)
+	if code == "" || strings.ContainsAny(code, " \t\n") {
+		return reply(ctx, jc.messageSender, msg, joinUsage, ErrInvalidCommandInput)
+	}
+
+	sender := normalizeUserJID(msg.Sender).String()
+
+	invite, err := jc.authService.RedeemInvite(ctx, sender, code)
+	if err != nil {
+		jc.logger.Warn("Invite redemption failed", map[string]interface{}{
+			"sender": sender,
+			"error":  err.Error(),
+		})
+
+		return reply(ctx, jc.messageSender, msg, inviteErrorMessage(err), err)
+	}
+
+	jc.logger.Info("User joined with invite", map[string]interface{}{
+		"user": sender,
+		"code": invite.Code,
+		"rank": invite.Rank,
+	})
+
+	return reply(ctx, jc.messageSender, msg,
+		fmt.Sprintf("Welcome! You are registered as *%s*. Send `!help` to see what you can do.", invite.Rank), nil)
+}
+
+// inviteErrorMessage turns invite errors into replies for the chat.
+func inviteErrorMessage(err error) string {
+	switch {
+	case errors.Is(err, ErrInvalidCommandInput), errors.Is(err, util.ErrInvalidDuration):
+		return inviteUsage
+	case errors.Is(err, auth.ErrInviteNotFound):
+		return "That invite code does not exist."
+	case errors.Is(err, auth.ErrInviteInvalid):
+		return "That invite code has expired, been used up or been revoked."
+	case errors.Is(err, auth.ErrRankNotFound):
+		return "That rank does not exist. Send `!rank list` to see the ranks."
+	case errors.Is(err, auth.ErrUserExists):
+		return "You are already registered."
+	default:
+		return authErrorMessage(err, "")
+	}
+}
@@ -0,0 +1,139 @@
+package commands
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"botex/pkg/auth"
+	"botex/pkg/config"
+	"botex/pkg/logger"
+	"botex/pkg/message"
+	"botex/pkg/ratelimit"
+	"go.mau.fi/whatsmeow"
+	"go.mau.fi/whatsmeow/types"
+)
+
+const penaltiesUsage = "Usage: `!penalties`, or `!penalties clear @mention|<number>`"
+
+// PenaltiesCommand shows the senders the rate limiter is penalizing for
+// repeatedly going over their limit, and lets admins clear someone's strikes.
+type PenaltiesCommand struct {
+	config        *config.Config
+	messageSender *message.MessageSender
+	authService   auth.Auth
+	penalties     *ratelimit.Penalties
+	logger        *logger.Logger
+}
+
+func NewPenaltiesCommand(client *whatsmeow.Client, cfg *config.Config, authService auth.Auth, loggerFactory *logger.Factory) *PenaltiesCommand {
+	return &PenaltiesCommand{
+		config:        cfg,
+		messageSender: message.NewMessageSender(client),
+		authService:   authService,
+		logger:        loggerFactory.GetLogger("penalties-command"),
+	}
+}
+
+// SetPenalties hands the command the tracker the CommandHandler creates,
+// which does not exist yet when commands are registered (see SetHandler on
+// HelpCommand). nil means penalties are off.
+func (pc *PenaltiesCommand) SetPenalties(penalties *ratelimit.Penalties) {
+	pc.penalties = penalties
+}
+
+func (pc *PenaltiesCommand) Name() string {
+	return "penalties"
+}
+
+func (pc *PenaltiesCommand) Info() CommandInfo {
+	return CommandInfo{
+		Description: "List repeat rate limit offenders, or clear someone's strikes",
+		Usage:       "!penalties [clear @mention|<number>]",
+		Examples:    []string{"!penalties", "!penalties clear @Alice", "!penalties clear 51999888777"},
+	}
+}
+
+func (pc *PenaltiesCommand) Handle(ctx context.Context, msg *message.Message) error {
+	if pc.penalties == nil {
+		return reply(ctx, pc.messageSender, msg, "Rate limit penalties are turned off.", nil)
+	}
+
+	action, rest := splitArgs(msg.Text)
+
+	switch strings.ToLower(action) {
+	case "":
+		return reply(ctx, pc.messageSender, msg, pc.list(), nil)
+	case "clear":
+		return pc.clear(ctx, msg, rest)
+	default:
+		return reply(ctx, pc.messageSender, msg, penaltiesUsage, ErrInvalidCommandInput)
+	}
+}
+
+func (pc *PenaltiesCommand) list() string {
+	offenders := pc.penalties.List()
+	if len(offenders) == 0 {
+		return "Nobody is being penalized."
+	}
+
+	policy := pc.penalties.Policy()
+
+	var builder strings.Builder
+	builder.WriteString(fmt.Sprintf("*Rate limit penalties* (one strike forgiven per %s", policy.Window))
+
+	if policy.BanAfter > 0 {
+		builder.WriteString(fmt.Sprintf(", banned at %d", policy.BanAfter))
+	}
+
+	builder.WriteString(")\n\n")
+
+	now := time.Now()
+
+	for _, offender := range offenders {
+		builder.WriteString(fmt.Sprintf("• %s: %d strike(s)", displayID(offender.User.String()), offender.Strikes))
+
+		if offender.Strikes > 0 {
+			builder.WriteString(", last " + offender.LastStrike.Local().Format(time.DateTime))
+		}
+
+		if offender.BlockedUntil.After(now) {
+			builder.WriteString(", blocked until " + offender.BlockedUntil.Local().Format(time.DateTime))
+		}
+
+		builder.WriteString("\n")
+	}
+
+	return builder.String()
+}
+
+func (pc *PenaltiesCommand) clear(ctx context.Context, msg *message.Message, args []string) error {
+	targetArg := ""
+	if len(args) > 0 {
+		targetArg = args[0]
+	}
+
+	target, err := resolveTargetUser(msg, targetArg)
+	if err != nil {
+		return reply(ctx, pc.messageSender, msg, penaltiesUsage, err)
+	}
+
+	// strikes are kept under the canonical ID, which a mention in a LID
+	// group is not.
+	canonical, err := types.ParseJID(pc.authService.CanonicalID(ctx, target.String()))
+	if err == nil {
+		target = canonical
+	}
+
+	if !pc.penalties.Forgive(target) {
+		return reply(ctx, pc.messageSender, msg, fmt.Sprintf("%s has no penalties.", displayID(target.String())), nil)
+	}
+
+	pc.logger.Info("Rate limit penalties cleared", map[string]interface{}{
+		"target": target.String(),
+		"actor":  normalizeUserJID(msg.Sender).String(),
+	})
+
+	return reply(ctx, pc.messageSender, msg, fmt.Sprintf("Cleared the penalties of %s.", displayID(target.String())), nil)
+}
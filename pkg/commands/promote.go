@@ -0,0 +1,136 @@
+package commands
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"botex/pkg/auth"
+	"botex/pkg/config"
+	"botex/pkg/logger"
+	"botex/pkg/message"
+	"botex/pkg/util"
+	"go.mau.fi/whatsmeow"
+)
+
+// RankChangeCommand backs both !promote and !demote. Without a rank argument
+// the user moves one step in the command's direction; with one, they are set
+// to that rank directly (still subject to the auth hierarchy checks).
+type RankChangeCommand struct {
+	name          string
+	promote       bool
+	config        *config.Config
+	messageSender *message.MessageSender
+	authService   auth.Auth
+	logger        *logger.Logger
+}
+
+func NewPromoteCommand(client *whatsmeow.Client, cfg *config.Config, authService auth.Auth, loggerFactory *logger.Factory) *RankChangeCommand {
+	return newRankChangeCommand("promote", true, client, cfg, authService, loggerFactory)
+}
+
+func NewDemoteCommand(client *whatsmeow.Client, cfg *config.Config, authService auth.Auth, loggerFactory *logger.Factory) *RankChangeCommand {
+	return newRankChangeCommand("demote", false, client, cfg, authService, loggerFactory)
+}
+
+func newRankChangeCommand(name string, promote bool, client *whatsmeow.Client, cfg *config.Config, authService auth.Auth, loggerFactory *logger.Factory) *RankChangeCommand {
+	return &RankChangeCommand{
+		name:          name,
+		promote:       promote,
+		config:        cfg,
+		messageSender: message.NewMessageSender(client),
+		authService:   authService,
+		logger:        loggerFactory.GetLogger(name + "-command"),
+	}
+}
+
+func (rc *RankChangeCommand) Name() string {
+	return rc.name
+}
+
+func (rc *RankChangeCommand) Info() CommandInfo {
+	if rc.promote {
+		return CommandInfo{
+			Description: "Move a user one rank up, or to a given rank (optionally for a limited time)",
+			Usage:       "!promote @mention|<number> [rank [duration]]",
+			Examples:    []string{"!promote @Alice", "!promote 51999888777 admin", "!promote @Bob ta 3d"},
+		}
+	}
+
+	return CommandInfo{
+		Description: "Move a user one rank down, or to a given rank (optionally for a limited time)",
+		Usage:       "!demote @mention|<number> [rank [duration]]",
+		Examples:    []string{"!demote @Alice", "!demote 51999888777 user"},
+	}
+}
+
+func (rc *RankChangeCommand) Handle(ctx context.Context, msg *message.Message) error {
+	targetArg, rest := splitArgs(msg.Text)
+
+	target, err := resolveTargetUser(msg, targetArg)
+	if err != nil {
+		return reply(ctx, rc.messageSender, msg, "Usage: `"+rc.Info().Usage+"`", err)
+	}
+
+	// a duration only makes sense together with an explicit rank.
+	rest, expiresAt := splitExpiry(rest)
+	if expiresAt != nil && len(rest) == 0 {
+		return reply(ctx, rc.messageSender, msg, "Usage: `"+rc.Info().Usage+"`", ErrInvalidCommandInput)
+	}
+
+	actor := normalizeUserJID(msg.Sender).String()
+
+	var rankName string
+
+	if len(rest) > 0 {
+		rankName = strings.ToLower(rest[0])
+		err = rc.authService.SetUserRank(ctx, target.String(), rankName, expiresAt, actor)
+	} else {
+		var rank *auth.Rank
+
+		rank, err = rc.step(ctx, target.String(), actor)
+		if rank != nil {
+			rankName = rank.Name
+		}
+	}
+
+	if err != nil {
+		rc.logger.Warn("Rank change failed", map[string]interface{}{
+			"command": rc.name,
+			"target":  target.String(),
+			"rank":    rankName,
+			"actor":   actor,
+			"error":   err.Error(),
+		})
+
+		return reply(ctx, rc.messageSender, msg, authErrorMessage(err, rankName), err)
+	}
+
+	rc.logger.Info("Rank changed", map[string]interface{}{
+		"command":   rc.name,
+		"target":    target.String(),
+		"rank":      rankName,
+		"actor":     actor,
+		"expiresAt": expiresAt,
+	})
+
+	return reply(ctx, rc.messageSender, msg, fmt.Sprintf("%s is now *%s*%s.", target.User, rankName, util.UntilText(expiresAt)), nil)
+}
+
+func (rc *RankChangeCommand) step(ctx context.Context, userID, actorID string) (*auth.Rank, error) {
+	if rc.promote {
+		rank, err := rc.authService.PromoteUser(ctx, userID, actorID)
+		if err != nil {
+			return nil, fmt.Errorf("promote user: %w", err)
+		}
+
+		return rank, nil
+	}
+
+	rank, err := rc.authService.DemoteUser(ctx, userID, actorID)
+	if err != nil {
+		return nil, fmt.Errorf("demote user: %w", err)
+	}
+
+	return rank, nil
+}
@@ -0,0 +1,251 @@
+package commands
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+
+	"botex/pkg/auth"
+	"botex/pkg/config"
+	"botex/pkg/logger"
+	"botex/pkg/message"
+	"go.mau.fi/whatsmeow"
+)
+
+const rankUsage = "Usage: `!rank create <name> <level> [cmd,cmd]`, `!rank grant|revoke <name> <cmd,cmd>`, " +
+	"`!rank level <name> <level>`, `!rank delete <name>`, `!rank list` or `!rank show <name>`"
+
+var ErrUnknownSubcommand = errors.New("unknown subcommand")
+
+type RankCommand struct {
+	config        *config.Config
+	messageSender *message.MessageSender
+	authService   auth.Auth
+	logger        *logger.Logger
+}
+
+func NewRankCommand(client *whatsmeow.Client, cfg *config.Config, authService auth.Auth, loggerFactory *logger.Factory) *RankCommand {
+	return &RankCommand{
+		config:        cfg,
+		messageSender: message.NewMessageSender(client),
+		authService:   authService,
+		logger:        loggerFactory.GetLogger("rank-command"),
+	}
+}
+
+func (rc *RankCommand) Name() string {
+	return "rank"
+}
+
+func (rc *RankCommand) Info() CommandInfo {
+	return CommandInfo{
+		Description: "Create, edit, inspect and delete ranks",
+		Usage:       "!rank create|grant|revoke|level|delete|list|show ...",
+		Examples: []string{
+			"!rank list",
+			"!rank show admin",
+			"!rank create teacher 50 help,latex,register_user",
+			"!rank grant ta latex",
+			"!rank grant moderator admin.*,-admin.purge",
+			"!rank revoke ta register_user",
+			"!rank level ta 60",
+			"!rank delete ta",
+		},
+	}
+}
+
+func (rc *RankCommand) Handle(ctx context.Context, msg *message.Message) error {
+	sub, args := splitArgs(msg.Text)
+	actor := normalizeUserJID(msg.Sender).String()
+
+	var (
+		text string
+		err  error
+	)
+
+	switch strings.ToLower(sub) {
+	case "list":
+		text, err = rc.list(ctx)
+	case "show":
+		text, err = rc.show(ctx, args)
+	case "create":
+		text, err = rc.create(ctx, args, actor)
+	case "grant", "revoke":
+		text, err = rc.editCommands(ctx, args, actor, sub == "grant")
+	case "level":
+		text, err = rc.setLevel(ctx, args, actor)
+	case "delete":
+		text, err = rc.remove(ctx, args, actor)
+	default:
+		return reply(ctx, rc.messageSender, msg, rankUsage, fmt.Errorf("%w: %q", ErrUnknownSubcommand, sub))
+	}
+
+	if err != nil {
+		rc.logger.Warn("Rank command failed", map[string]interface{}{
+			"subcommand": sub,
+			"args":       args,
+			"actor":      actor,
+			"error":      err.Error(),
+		})
+
+		return reply(ctx, rc.messageSender, msg, rankErrorMessage(err, args), err)
+	}
+
+	return reply(ctx, rc.messageSender, msg, text, nil)
+}
+
+func (rc *RankCommand) list(ctx context.Context) (string, error) {
+	ranks, err := rc.authService.ListRanks(ctx)
+	if err != nil {
+		return "", fmt.Errorf("list ranks: %w", err)
+	}
+
+	var builder strings.Builder
+	builder.WriteString("*Ranks*\n\n")
+
+	for _, rank := range ranks {
+		builder.WriteString(fmt.Sprintf("• *%s* (level %d) - %s\n", rank.Name, rank.Level, auth.JoinCommands(rank.Commands)))
+	}
+
+	return builder.String(), nil
+}
+
+func (rc *RankCommand) show(ctx context.Context, args []string) (string, error) {
+	if len(args) < 1 {
+		return "", ErrInvalidCommandInput
+	}
+
+	rank, err := rc.authService.GetRank(ctx, strings.ToLower(args[0]))
+	if err != nil {
+		return "", fmt.Errorf("get rank: %w", err)
+	}
+
+	text := fmt.Sprintf("*%s*\nLevel: %d\nCommands: %s", rank.Name, rank.Level, auth.JoinCommands(rank.Commands))
+	if rank.Description != "" {
+		text += "\n" + rank.Description
+	}
+
+	return text, nil
+}
+
+func (rc *RankCommand) create(ctx context.Context, args []string, actor string) (string, error) {
+	if len(args) < 2 {
+		return "", ErrInvalidCommandInput
+	}
+
+	level, err := strconv.Atoi(args[1])
+	if err != nil {
+		return "", fmt.Errorf("%w: level %q", ErrInvalidCommandInput, args[1])
+	}
+
+	rank := &auth.Rank{
+		Name:  strings.ToLower(args[0]),
+		Level: level,
+	}
+
+	if len(args) > 2 {
+		rank.Commands = auth.ParseCommands(args[2])
+	}
+
+	if len(args) > 3 {
+		rank.Description = strings.Join(args[3:], " ")
+	}
+
+	err = rc.authService.CreateRank(ctx, rank, actor)
+	if err != nil {
+		return "", fmt.Errorf("create rank: %w", err)
+	}
+
+	return fmt.Sprintf("Rank *%s* created at level %d.", rank.Name, rank.Level), nil
+}
+
+func (rc *RankCommand) editCommands(ctx context.Context, args []string, actor string, grant bool) (string, error) {
+	if len(args) < 2 {
+		return "", ErrInvalidCommandInput
+	}
+
+	rank, err := rc.authService.GetRank(ctx, strings.ToLower(args[0]))
+	if err != nil {
+		return "", fmt.Errorf("get rank: %w", err)
+	}
+
+	changes := auth.ParseCommands(strings.Join(args[1:], ","))
+
+	commands := slices.Clone(rank.Commands)
+	if grant {
+		for _, cmd := range changes {
+			if !slices.Contains(commands, cmd) {
+				commands = append(commands, cmd)
+			}
+		}
+	} else {
+		commands = slices.DeleteFunc(commands, func(cmd string) bool {
+			return slices.Contains(changes, cmd)
+		})
+	}
+
+	err = rc.authService.UpdateRankCommands(ctx, rank.Name, commands, actor)
+	if err != nil {
+		return "", fmt.Errorf("update rank commands: %w", err)
+	}
+
+	return fmt.Sprintf("Rank *%s* commands: %s", rank.Name, auth.JoinCommands(commands)), nil
+}
+
+func (rc *RankCommand) setLevel(ctx context.Context, args []string, actor string) (string, error) {
+	if len(args) < 2 {
+		return "", ErrInvalidCommandInput
+	}
+
+	level, err := strconv.Atoi(args[1])
+	if err != nil {
+		return "", fmt.Errorf("%w: level %q", ErrInvalidCommandInput, args[1])
+	}
+
+	name := strings.ToLower(args[0])
+
+	err = rc.authService.SetRankLevel(ctx, name, level, actor)
+	if err != nil {
+		return "", fmt.Errorf("set rank level: %w", err)
+	}
+
+	return fmt.Sprintf("Rank *%s* moved to level %d.", name, level), nil
+}
+
+func (rc *RankCommand) remove(ctx context.Context, args []string, actor string) (string, error) {
+	if len(args) < 1 {
+		return "", ErrInvalidCommandInput
+	}
+
+	name := strings.ToLower(args[0])
+
+	err := rc.authService.DeleteRank(ctx, name, actor)
+	if err != nil {
+		return "", fmt.Errorf("delete rank: %w", err)
+	}
+
+	return fmt.Sprintf("Rank *%s* deleted.", name), nil
+}
+
+func rankErrorMessage(err error, args []string) string {
+	rankName := ""
+	if len(args) > 0 {
+		rankName = strings.ToLower(args[0])
+	}
+
+	switch {
+	case errors.Is(err, ErrInvalidCommandInput):
+		return rankUsage
+	case errors.Is(err, auth.ErrRankExists):
+		return fmt.Sprintf("Rank `%s` already exists.", rankName)
+	case errors.Is(err, auth.ErrRankInUse):
+		return fmt.Sprintf("Rank `%s` is still assigned to users. Move them to another rank first.", rankName)
+	case errors.Is(err, auth.ErrRankEscalation):
+		return "You can only manage ranks below your own, with commands your rank already has."
+	default:
+		return authErrorMessage(err, rankName)
+	}
+}
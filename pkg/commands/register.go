@@ -0,0 +1,257 @@
+package commands
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"botex/pkg/auth"
+	"botex/pkg/config"
+	"botex/pkg/logger"
+	"botex/pkg/message"
+	"botex/pkg/util"
+	"go.mau.fi/whatsmeow"
+	"go.mau.fi/whatsmeow/types"
+)
+
+const (
+	registerUserUsage  = "Usage: `!register_user @mention|<number> [rank] [duration]`"
+	registerGroupUsage = "Usage: `!register_group` inside the group, or `!register_group <group_jid>` in a DM"
+
+	unregisterGroupUsage = "Usage: `!unregister_group` inside the group, or `!unregister_group <group_jid>` in a DM"
+)
+
+var ErrGroupRequired = errors.New("group required")
+
+type RegisterUserCommand struct {
+	config        *config.Config
+	messageSender *message.MessageSender
+	authService   auth.Auth
+	logger        *logger.Logger
+}
+
+func NewRegisterUserCommand(client *whatsmeow.Client, cfg *config.Config, authService auth.Auth, loggerFactory *logger.Factory) *RegisterUserCommand {
+	return &RegisterUserCommand{
+		config:        cfg,
+		messageSender: message.NewMessageSender(client),
+		authService:   authService,
+		logger:        loggerFactory.GetLogger("register-user-command"),
+	}
+}
+
+func (rc *RegisterUserCommand) Name() string {
+	return "register_user"
+}
+
+func (rc *RegisterUserCommand) Info() CommandInfo {
+	return CommandInfo{
+		Description: "Register a user so they can use the bot",
+		Usage:       "!register_user @mention|<number> [rank] [duration]",
+		Examples: []string{
+			"!register_user @Alice",
+			"!register_user 51999888777 admin",
+			"!register_user @Bob user 7d",
+		},
+	}
+}
+
+func (rc *RegisterUserCommand) Handle(ctx context.Context, msg *message.Message) error {
+	targetArg, rest := splitArgs(msg.Text)
+
+	target, err := resolveTargetUser(msg, targetArg)
+	if err != nil {
+		return reply(ctx, rc.messageSender, msg, registerUserUsage, err)
+	}
+
+	rest, expiresAt := splitExpiry(rest)
+
+	rankName := rc.config.Auth.DefaultUserRank
+	if len(rest) > 0 {
+		rankName = strings.ToLower(rest[0])
+	}
+
+	issuer := normalizeUserJID(msg.Sender).String()
+
+	err = rc.authService.RegisterUser(ctx, target.String(), rankName, expiresAt, issuer)
+	if err != nil {
+		rc.logger.Warn("User registration failed", map[string]interface{}{
+			"target": target.String(),
+			"rank":   rankName,
+			"issuer": issuer,
+			"error":  err.Error(),
+		})
+
+		return reply(ctx, rc.messageSender, msg, authErrorMessage(err, rankName), err)
+	}
+
+	rc.logger.Info("User registered", map[string]interface{}{
+		"target":    target.String(),
+		"rank":      rankName,
+		"issuer":    issuer,
+		"expiresAt": expiresAt,
+	})
+
+	return reply(ctx, rc.messageSender, msg, fmt.Sprintf("Registered %s as *%s*%s.", target.User, rankName, util.UntilText(expiresAt)), nil)
+}
+
+type RegisterGroupCommand struct {
+	config        *config.Config
+	messageSender *message.MessageSender
+	authService   auth.Auth
+	logger        *logger.Logger
+}
+
+func NewRegisterGroupCommand(client *whatsmeow.Client, cfg *config.Config, authService auth.Auth, loggerFactory *logger.Factory) *RegisterGroupCommand {
+	return &RegisterGroupCommand{
+		config:        cfg,
+		messageSender: message.NewMessageSender(client),
+		authService:   authService,
+		logger:        loggerFactory.GetLogger("register-group-command"),
+	}
+}
+
+func (rc *RegisterGroupCommand) Name() string {
+	return "register_group"
+}
+
+func (rc *RegisterGroupCommand) Info() CommandInfo {
+	return CommandInfo{
+		Description: "Allow the bot to answer commands in a group",
+		Usage:       "!register_group [group_jid]",
+		Examples: []string{
+			"!register_group",
+			"!register_group 120363041234567890@g.us",
+		},
+	}
+}
+
+func (rc *RegisterGroupCommand) Handle(ctx context.Context, msg *message.Message) error {
+	groupID, err := resolveTargetGroup(msg, strings.TrimSpace(msg.Text))
+	if err != nil {
+		return reply(ctx, rc.messageSender, msg, registerGroupUsage, err)
+	}
+
+	issuer := normalizeUserJID(msg.Sender).String()
+
+	err = rc.authService.RegisterGroup(ctx, groupID.String(), issuer)
+	if err != nil {
+		rc.logger.Warn("Group registration failed", map[string]interface{}{
+			"group":  groupID.String(),
+			"issuer": issuer,
+			"error":  err.Error(),
+		})
+
+		return reply(ctx, rc.messageSender, msg, authErrorMessage(err, ""), err)
+	}
+
+	rc.logger.Info("Group registered", map[string]interface{}{
+		"group":  groupID.String(),
+		"issuer": issuer,
+	})
+
+	return reply(ctx, rc.messageSender, msg, "Group registered. Members with a rank can now use the bot here.", nil)
+}
+
+type UnregisterGroupCommand struct {
+	config        *config.Config
+	messageSender *message.MessageSender
+	authService   auth.Auth
+	logger        *logger.Logger
+}
+
+func NewUnregisterGroupCommand(client *whatsmeow.Client, cfg *config.Config, authService auth.Auth, loggerFactory *logger.Factory) *UnregisterGroupCommand {
+	return &UnregisterGroupCommand{
+		config:        cfg,
+		messageSender: message.NewMessageSender(client),
+		authService:   authService,
+		logger:        loggerFactory.GetLogger("unregister-group-command"),
+	}
+}
+
+func (uc *UnregisterGroupCommand) Name() string {
+	return "unregister_group"
+}
+
+func (uc *UnregisterGroupCommand) Info() CommandInfo {
+	return CommandInfo{
+		Description: "Stop the bot from answering commands in a group",
+		Usage:       "!unregister_group [group_jid]",
+		Examples: []string{
+			"!unregister_group",
+			"!unregister_group 120363041234567890@g.us",
+		},
+	}
+}
+
+func (uc *UnregisterGroupCommand) Handle(ctx context.Context, msg *message.Message) error {
+	groupID, err := resolveTargetGroup(msg, strings.TrimSpace(msg.Text))
+	if err != nil {
+		return reply(ctx, uc.messageSender, msg, unregisterGroupUsage, err)
+	}
+
+	actor := normalizeUserJID(msg.Sender).String()
+
+	err = uc.authService.DeactivateGroup(ctx, groupID.String(), actor)
+	if err != nil {
+		uc.logger.Warn("Group deactivation failed", map[string]interface{}{
+			"group": groupID.String(),
+			"actor": actor,
+			"error": err.Error(),
+		})
+
+		return reply(ctx, uc.messageSender, msg, authErrorMessage(err, ""), err)
+	}
+
+	uc.logger.Info("Group deactivated", map[string]interface{}{
+		"group": groupID.String(),
+		"actor": actor,
+	})
+
+	return reply(ctx, uc.messageSender, msg, "Group unregistered. The bot will ignore commands here.", nil)
+}
+
+// resolveTargetGroup uses the current chat when the command is sent inside a
+// group, or an explicit group JID when sent from a DM.
+func resolveTargetGroup(msg *message.Message, arg string) (types.JID, error) {
+	if arg == "" {
+		if !msg.IsGroup {
+			return types.EmptyJID, ErrGroupRequired
+		}
+
+		return msg.GroupID, nil
+	}
+
+	jid, err := types.ParseJID(arg)
+	if err != nil || jid.Server != types.GroupServer {
+		return types.EmptyJID, fmt.Errorf("%w: %q", ErrGroupRequired, arg)
+	}
+
+	return jid, nil
+}
+
+// authErrorMessage turns auth service errors into replies for the chat.
+func authErrorMessage(err error, rankName string) string {
+	switch {
+	case errors.Is(err, auth.ErrUserExists):
+		return "That user is already registered."
+	case errors.Is(err, auth.ErrGroupExists):
+		return "This group is already registered."
+	case errors.Is(err, auth.ErrGroupNotRegistered):
+		return "This group is not registered."
+	case errors.Is(err, auth.ErrNotBanned):
+		return "That user is neither banned nor deactivated."
+	case errors.Is(err, auth.ErrRankNotFound):
+		return fmt.Sprintf("Rank `%s` does not exist. Use one of the configured ranks.", rankName)
+	case errors.Is(err, auth.ErrUserNotFound):
+		return "That user is not registered."
+	case errors.Is(err, auth.ErrRankEscalation):
+		return "You can only manage users and ranks below your own rank."
+	case errors.Is(err, auth.ErrRankBoundary):
+		return "There is no rank further in that direction."
+	case errors.Is(err, auth.ErrInvalidInput):
+		return "Invalid input. Check the arguments and try again."
+	default:
+		return "The request failed due to an internal error."
+	}
+}
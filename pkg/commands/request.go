@@ -0,0 +1,353 @@
+package commands
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"botex/pkg/auth"
+	"botex/pkg/config"
+	"botex/pkg/logger"
+	"botex/pkg/message"
+	"go.mau.fi/whatsmeow"
+	"go.mau.fi/whatsmeow/types"
+)
+
+const (
+	requestAccessUsage = "Usage: `!request_access [reason]`"
+	approveUsage       = "Usage: `!approve <id> [rank]`, or `!approve` to list pending requests"
+	denyUsage          = "Usage: `!deny <id>`"
+)
+
+// RequestAccessCommand lets unregistered users ask to be registered. Every
+// member of the review rank (and above) gets a DM about the request. It is
+// open to everyone.
+type RequestAccessCommand struct {
+	config        *config.Config
+	messageSender *message.MessageSender
+	authService   auth.Auth
+	logger        *logger.Logger
+}
+
+func NewRequestAccessCommand(
+	client *whatsmeow.Client, cfg *config.Config, authService auth.Auth, loggerFactory *logger.Factory,
+) *RequestAccessCommand {
+	return &RequestAccessCommand{
+		config:        cfg,
+		messageSender: message.NewMessageSender(client),
+		authService:   authService,
+		logger:        loggerFactory.GetLogger("request-access-command"),
+	}
+}
+
+func (rc *RequestAccessCommand) Name() string {
+	return "request_access"
+}
+
+func (rc *RequestAccessCommand) Info() CommandInfo {
+	return CommandInfo{
+		Description: "Ask the admins to register you",
+		Usage:       "!request_access [reason]",
+		Examples:    []string{"!request_access", "!request_access I'm in the Tuesday lab group"},
+	}
+}
+
+func (rc *RequestAccessCommand) Handle(ctx context.Context, msg *message.Message) error {
+	sender := normalizeUserJID(msg.Sender).String()
+	reason := strings.TrimSpace(msg.Text)
+
+	request, err := rc.authService.RequestAccess(ctx, sender, reason)
+	if err != nil {
+		rc.logger.Warn("Access request failed", map[string]interface{}{
+			"sender": sender,
+			"error":  err.Error(),
+		})
+
+		text := requestErrorMessage(err, "")
+
+		switch {
+		case errors.Is(err, auth.ErrUserExists):
+			text = "You are already registered."
+		case errors.Is(err, auth.ErrInvalidInput):
+			text = requestAccessUsage + fmt.Sprintf(" (the reason can be at most %d characters)", auth.MaxRequestReasonLength)
+		}
+
+		return reply(ctx, rc.messageSender, msg, text, err)
+	}
+
+	notified := rc.notifyReviewers(ctx, request)
+
+	rc.logger.Info("Access requested", map[string]interface{}{
+		"id":       request.ID,
+		"user":     sender,
+		"notified": notified,
+	})
+
+	return reply(ctx, rc.messageSender, msg, fmt.Sprintf(
+		"Your request #%d was sent to the admins. You will get a message once it is decided.", request.ID), nil)
+}
+
+// notifyReviewers DMs every reviewer about the request and returns how many
+// were reached. Failures are logged; the request stays valid either way.
+func (rc *RequestAccessCommand) notifyReviewers(ctx context.Context, request *auth.AccessRequest) int {
+	reviewers, err := rc.authService.RequestReviewers(ctx, rc.config.Auth.RequestReviewRank)
+	if err != nil {
+		rc.logger.Error("Failed to look up request reviewers", map[string]interface{}{
+			"rank":  rc.config.Auth.RequestReviewRank,
+			"error": err.Error(),
+		})
+
+		return 0
+	}
+
+	text := fmt.Sprintf("*Access request #%d* from %s", request.ID, displayID(request.UserID))
+	if request.Reason != "" {
+		text += ": " + request.Reason
+	}
+
+	text += fmt.Sprintf("\n\n`!approve %d [rank]` or `!deny %d`", request.ID, request.ID)
+
+	notified := 0
+
+	for _, reviewer := range reviewers {
+		err = sendDirect(ctx, rc.messageSender, reviewer.ID, text)
+		if err != nil {
+			rc.logger.Warn("Failed to notify reviewer", map[string]interface{}{
+				"reviewer": reviewer.ID,
+				"error":    err.Error(),
+			})
+
+			continue
+		}
+
+		notified++
+	}
+
+	return notified
+}
+
+// ApproveCommand registers the user behind an access request. Without
+// arguments it lists the pending requests.
+type ApproveCommand struct {
+	config        *config.Config
+	messageSender *message.MessageSender
+	authService   auth.Auth
+	logger        *logger.Logger
+}
+
+func NewApproveCommand(client *whatsmeow.Client, cfg *config.Config, authService auth.Auth, loggerFactory *logger.Factory) *ApproveCommand {
+	return &ApproveCommand{
+		config:        cfg,
+		messageSender: message.NewMessageSender(client),
+		authService:   authService,
+		logger:        loggerFactory.GetLogger("approve-command"),
+	}
+}
+
+func (ac *ApproveCommand) Name() string {
+	return "approve"
+}
+
+func (ac *ApproveCommand) Info() CommandInfo {
+	return CommandInfo{
+		Description: "Approve an access request, registering the user",
+		Usage:       "!approve [<id> [rank]]",
+		Examples:    []string{"!approve", "!approve 12", "!approve 12 ta"},
+	}
+}
+
+func (ac *ApproveCommand) Handle(ctx context.Context, msg *message.Message) error {
+	args := strings.Fields(msg.Text)
+	if len(args) == 0 {
+		text, err := ac.list(ctx)
+		if err != nil {
+			return reply(ctx, ac.messageSender, msg, requestErrorMessage(err, ""), err)
+		}
+
+		return reply(ctx, ac.messageSender, msg, text, nil)
+	}
+
+	if len(args) > 2 {
+		return reply(ctx, ac.messageSender, msg, approveUsage, ErrInvalidCommandInput)
+	}
+
+	id, err := parseRequestID(args[0])
+	if err != nil {
+		return reply(ctx, ac.messageSender, msg, approveUsage, err)
+	}
+
+	rankName := ac.config.Auth.DefaultUserRank
+	if len(args) == 2 {
+		rankName = strings.ToLower(args[1])
+	}
+
+	actor := normalizeUserJID(msg.Sender).String()
+
+	request, err := ac.authService.ApproveRequest(ctx, id, rankName, actor)
+	if err != nil {
+		ac.logger.Warn("Access approval failed", map[string]interface{}{
+			"id":    id,
+			"rank":  rankName,
+			"actor": actor,
+			"error": err.Error(),
+		})
+
+		return reply(ctx, ac.messageSender, msg, requestErrorMessage(err, rankName), err)
+	}
+
+	ac.logger.Info("Access request approved", map[string]interface{}{
+		"id":    id,
+		"user":  request.UserID,
+		"rank":  rankName,
+		"actor": actor,
+	})
+
+	err = sendDirect(ctx, ac.messageSender, request.UserID, fmt.Sprintf(
+		"Your access request was approved. You are registered as *%s*. Send `!help` to see what you can do.", rankName))
+	if err != nil {
+		ac.logger.Warn("Failed to notify requester", map[string]interface{}{
+			"user":  request.UserID,
+			"error": err.Error(),
+		})
+	}
+
+	return reply(ctx, ac.messageSender, msg,
+		fmt.Sprintf("Request #%d approved: %s is now *%s*.", id, displayID(request.UserID), rankName), nil)
+}
+
+func (ac *ApproveCommand) list(ctx context.Context) (string, error) {
+	requests, err := ac.authService.ListPendingRequests(ctx)
+	if err != nil {
+		return "", fmt.Errorf("list access requests: %w", err)
+	}
+
+	if len(requests) == 0 {
+		return "No pending access requests.", nil
+	}
+
+	var builder strings.Builder
+	builder.WriteString("*Pending access requests*\n\n")
+
+	for _, request := range requests {
+		builder.WriteString(fmt.Sprintf("• #%d %s, %s", request.ID, displayID(request.UserID),
+			request.CreatedAt.Local().Format(time.DateTime)))
+
+		if request.Reason != "" {
+			builder.WriteString(": " + request.Reason)
+		}
+
+		builder.WriteString("\n")
+	}
+
+	return builder.String(), nil
+}
+
+// DenyCommand rejects an access request.
+type DenyCommand struct {
+	config        *config.Config
+	messageSender *message.MessageSender
+	authService   auth.Auth
+	logger        *logger.Logger
+}
+
+func NewDenyCommand(client *whatsmeow.Client, cfg *config.Config, authService auth.Auth, loggerFactory *logger.Factory) *DenyCommand {
+	return &DenyCommand{
+		config:        cfg,
+		messageSender: message.NewMessageSender(client),
+		authService:   authService,
+		logger:        loggerFactory.GetLogger("deny-command"),
+	}
+}
+
+func (dc *DenyCommand) Name() string {
+	return "deny"
+}
+
+func (dc *DenyCommand) Info() CommandInfo {
+	return CommandInfo{
+		Description: "Deny an access request",
+		Usage:       "!deny <id>",
+		Examples:    []string{"!deny 12"},
+	}
+}
+
+func (dc *DenyCommand) Handle(ctx context.Context, msg *message.Message) error {
+	id, err := parseRequestID(strings.TrimSpace(msg.Text))
+	if err != nil {
+		return reply(ctx, dc.messageSender, msg, denyUsage, err)
+	}
+
+	actor := normalizeUserJID(msg.Sender).String()
+
+	request, err := dc.authService.DenyRequest(ctx, id, actor)
+	if err != nil {
+		dc.logger.Warn("Access denial failed", map[string]interface{}{
+			"id":    id,
+			"actor": actor,
+			"error": err.Error(),
+		})
+
+		return reply(ctx, dc.messageSender, msg, requestErrorMessage(err, ""), err)
+	}
+
+	dc.logger.Info("Access request denied", map[string]interface{}{
+		"id":    id,
+		"user":  request.UserID,
+		"actor": actor,
+	})
+
+	err = sendDirect(ctx, dc.messageSender, request.UserID, "Your access request was denied.")
+	if err != nil {
+		dc.logger.Warn("Failed to notify requester", map[string]interface{}{
+			"user":  request.UserID,
+			"error": err.Error(),
+		})
+	}
+
+	return reply(ctx, dc.messageSender, msg,
+		fmt.Sprintf("Request #%d from %s denied.", id, displayID(request.UserID)), nil)
+}
+
+// parseRequestID accepts "12" as well as "#12".
+func parseRequestID(raw string) (int64, error) {
+	id, err := strconv.ParseInt(strings.TrimPrefix(raw, "#"), 10, 64)
+	if err != nil || id <= 0 {
+		return 0, fmt.Errorf("%w: request id %q", ErrInvalidCommandInput, raw)
+	}
+
+	return id, nil
+}
+
+// sendDirect sends text to a user's private chat.
+func sendDirect(ctx context.Context, sender *message.MessageSender, userID, text string) error {
+	recipient, err := types.ParseJID(userID)
+	if err != nil {
+		return fmt.Errorf("%w: %q", ErrInvalidTarget, userID)
+	}
+
+	err = sender.SendText(ctx, recipient, text)
+	if err != nil {
+		return fmt.Errorf("failed to send direct message: %w", err)
+	}
+
+	return nil
+}
+
+// requestErrorMessage turns access request errors into replies for the chat.
+func requestErrorMessage(err error, rankName string) string {
+	switch {
+	case errors.Is(err, auth.ErrRequestNotFound):
+		return "There is no access request with that id."
+	case errors.Is(err, auth.ErrRequestClosed):
+		return "That request was already decided or has expired."
+	case errors.Is(err, auth.ErrRequestPending):
+		return "You already have a pending request. The admins will get back to you."
+	case errors.Is(err, auth.ErrRequestTooSoon):
+		return "You asked recently. Please wait before asking again."
+	default:
+		return authErrorMessage(err, rankName)
+	}
+}
@@ -4,9 +4,13 @@ import (
 	"errors"
 	"fmt"
 	"os"
+	"slices"
+	"strconv"
+	"strings"
 	"time"
 
 	"botex/pkg/logger"
+	"botex/pkg/ratelimit"
 	"botex/pkg/util"
 	"github.com/joho/godotenv"
 )
@@ -25,6 +29,37 @@ const (
 	DefaultRateLimitPeriod               = 1 * time.Minute
 	DefaultRateLimitNotificationCooldown = 5 * time.Minute
 	DefaultRateLimitCleanupInterval      = 1 * time.Hour
+	DefaultRateLimitAlgorithm            = ratelimit.AlgorithmSlidingLog
+	DefaultRateLimitFlushInterval        = 1 * time.Minute
+	DefaultRateLimitExemptRanks          = "owner"
+	DefaultRateLimitPenaltyWindow        = 0
+	DefaultRateLimitPenaltyBanAfter      = 5
+	DefaultRateLimitPenaltyBanDuration   = 1 * time.Hour
+
+	// Auth defaults.
+	DefaultAuthUserRank     = "user"
+	DefaultAuthGroupInfoTTL = 10 * time.Minute
+	DefaultAuthCacheTTL     = 1 * time.Minute
+	DefaultAuthCacheSize    = 1000
+
+	DefaultAuthExpiryCheckInterval = 1 * time.Minute
+	DefaultAuthExpiryNotice        = 24 * time.Hour
+
+	DefaultAuthAuditRetention = 90 * 24 * time.Hour
+
+	DefaultAuthRequestTTL        = 72 * time.Hour
+	DefaultAuthRequestCooldown   = 24 * time.Hour
+	DefaultAuthRequestReviewRank = "admin"
+
+	DefaultAuthDenialMode     = DenialModeReply
+	DefaultAuthDenialCooldown = 1 * time.Minute
+
+	// How the bot answers commands from unregistered or banned senders and
+	// from unregistered groups.
+	DenialModeReply  = "reply"  // react with 🚫 and explain in the chat
+	DenialModeReact  = "react"  // only react with 🚫
+	DenialModeDirect = "dm"     // explain in a private message to the sender
+	DenialModeIgnore = "ignore" // say nothing
 
 	// Default timing configuration.
 	DefaultTimingLevel        = "disabled"
@@ -38,7 +73,22 @@ var (
 	ErrRateLimitPeriodMustBePositive        = errors.New("RateLimit.Period must be positive")
 	ErrRateLimitNotificationCooldownInvalid = errors.New("RateLimit.NotificationCooldown must be positive")
 	ErrRateLimitCleanupIntervalInvalid      = errors.New("RateLimit.CleanupInterval must be positive")
+	ErrRateLimitAlgorithmInvalid            = errors.New("RateLimit.Algorithm must be sliding_log, token_bucket or gcra")
+	ErrRateLimitFlushIntervalInvalid        = errors.New("RateLimit.FlushInterval must be positive")
+	ErrRateLimitPenaltyWindowInvalid        = errors.New("RateLimit.PenaltyWindow must be non-negative")
+	ErrRateLimitPenaltyBanAfterInvalid      = errors.New("RateLimit.PenaltyBanAfter must be non-negative")
+	ErrRateLimitPenaltyBanDurationInvalid   = errors.New("RateLimit.PenaltyBanDuration must be positive")
 	ErrTimingLogThresholdInvalid            = errors.New("Timing.LogThreshold must be non-negative")
+	ErrAuthGroupInfoTTLInvalid              = errors.New("Auth.GroupInfoTTL must be positive")
+	ErrAuthCacheTTLInvalid                  = errors.New("Auth.CacheTTL must be non-negative")
+	ErrAuthCacheSizeInvalid                 = errors.New("Auth.CacheSize must be positive")
+	ErrAuthExpiryCheckIntervalInvalid       = errors.New("Auth.ExpiryCheckInterval must be positive")
+	ErrAuthExpiryNoticeInvalid              = errors.New("Auth.ExpiryNotice must be non-negative")
+	ErrAuthAuditRetentionInvalid            = errors.New("Auth.AuditRetention must be non-negative")
+	ErrAuthRequestTTLInvalid                = errors.New("Auth.RequestTTL must be positive")
+	ErrAuthRequestCooldownInvalid           = errors.New("Auth.RequestCooldown must be non-negative")
+	ErrAuthDenialModeInvalid                = errors.New("Auth.DenialMode must be reply, react, dm or ignore")
+	ErrAuthDenialCooldownInvalid            = errors.New("Auth.DenialCooldown must be non-negative")
 )
 
 type Config struct {
@@ -52,6 +102,18 @@ type Config struct {
 		Period               time.Duration
 		NotificationCooldown time.Duration
 		CleanupInterval      time.Duration
+		Algorithm            string
+		Persist              bool
+		FlushInterval        time.Duration
+		Ranks                map[string]ratelimit.Limit
+		Groups               map[string]ratelimit.Limit
+		Costs                map[string]int
+		ExemptRanks          []string
+		GroupTotal           *ratelimit.Limit
+		GlobalTotal          *ratelimit.Limit
+		PenaltyWindow        time.Duration
+		PenaltyBanAfter      int
+		PenaltyBanDuration   time.Duration
 	}
 
 	Timing struct {
@@ -68,8 +130,23 @@ type Config struct {
 	Auth struct {
 		DatabasePath        string
 		DefaultUserRank     string
+		AutoRegister        bool
 		EnableWhatsAppAdmin bool
+		GroupInfoTTL        time.Duration
 		ValidateSchema      bool
+		CacheTTL            time.Duration
+		CacheSize           int
+		OwnerJIDs           []string
+		ClaimCode           bool
+		ExpiryCheckInterval time.Duration
+		ExpiryNotice        time.Duration
+		AuditRetention      time.Duration
+		RequestTTL          time.Duration
+		RequestCooldown     time.Duration
+		RequestReviewRank   string
+		DenialMode          string
+		DenialGroupModes    map[string]string
+		DenialCooldown      time.Duration
 	}
 }
 
@@ -92,6 +169,100 @@ func (e *envLoader) loadRateLimit() {
 	e.cfg.RateLimit.Period = util.GetEnvDuration("BOTEX_RATE_LIMIT_PERIOD", DefaultRateLimitPeriod)
 	e.cfg.RateLimit.NotificationCooldown = util.GetEnvDuration("BOTEX_RATE_LIMIT_NOTIFICATION_COOLDOWN", DefaultRateLimitNotificationCooldown)
 	e.cfg.RateLimit.CleanupInterval = util.GetEnvDuration("BOTEX_RATE_LIMIT_CLEANUP_INTERVAL", DefaultRateLimitCleanupInterval)
+	e.cfg.RateLimit.Algorithm = strings.ToLower(util.GetEnv("BOTEX_RATE_LIMIT_ALGORITHM", DefaultRateLimitAlgorithm))
+	e.cfg.RateLimit.Persist = util.GetEnvBool("BOTEX_RATE_LIMIT_PERSIST", true)
+	e.cfg.RateLimit.FlushInterval = util.GetEnvDuration("BOTEX_RATE_LIMIT_FLUSH_INTERVAL", DefaultRateLimitFlushInterval)
+	e.cfg.RateLimit.Ranks = parseLimits(util.GetEnvList("BOTEX_RATE_LIMIT_RANKS", ""), strings.ToLower)
+	e.cfg.RateLimit.Groups = parseLimits(util.GetEnvList("BOTEX_RATE_LIMIT_GROUPS", ""), strings.TrimSpace)
+	e.cfg.RateLimit.Costs = parseCosts(util.GetEnvList("BOTEX_RATE_LIMIT_COSTS", ""))
+	e.cfg.RateLimit.ExemptRanks = parseRanks(util.GetEnvList("BOTEX_RATE_LIMIT_EXEMPT_RANKS", DefaultRateLimitExemptRanks))
+	e.cfg.RateLimit.GroupTotal = parseOptionalLimit(util.GetEnv("BOTEX_RATE_LIMIT_GROUP_TOTAL", ""))
+	e.cfg.RateLimit.GlobalTotal = parseOptionalLimit(util.GetEnv("BOTEX_RATE_LIMIT_GLOBAL_TOTAL", ""))
+	e.cfg.RateLimit.PenaltyWindow = util.GetEnvDuration("BOTEX_RATE_LIMIT_PENALTY_WINDOW", DefaultRateLimitPenaltyWindow)
+	e.cfg.RateLimit.PenaltyBanAfter = util.GetEnvInt("BOTEX_RATE_LIMIT_PENALTY_BAN_AFTER", DefaultRateLimitPenaltyBanAfter)
+	e.cfg.RateLimit.PenaltyBanDuration = util.GetEnvDuration("BOTEX_RATE_LIMIT_PENALTY_BAN_DURATION", DefaultRateLimitPenaltyBanDuration)
+}
+
+// parseOptionalLimit returns nil when raw is empty. A malformed limit is kept
+// as zero so validation reports it.
+func parseOptionalLimit(raw string) *ratelimit.Limit {
+	if raw == "" {
+		return nil
+	}
+
+	limit, _ := ratelimit.ParseLimit(raw)
+
+	return &limit
+}
+
+// parseLimits reads "<key>=<requests>/<period>" entries. Malformed limits are
+// kept as zero so validation reports them.
+func parseLimits(entries []string, normalize func(string) string) map[string]ratelimit.Limit {
+	limits := make(map[string]ratelimit.Limit, len(entries))
+
+	for _, entry := range entries {
+		key, raw, _ := strings.Cut(entry, "=")
+		limit, _ := ratelimit.ParseLimit(raw)
+		limits[normalize(strings.TrimSpace(key))] = limit
+	}
+
+	return limits
+}
+
+// parseCosts reads "<command>=<cost>" entries. Malformed costs are kept as -1
+// so validation reports them.
+func parseCosts(entries []string) map[string]int {
+	costs := make(map[string]int, len(entries))
+
+	for _, entry := range entries {
+		command, raw, _ := strings.Cut(entry, "=")
+
+		cost, err := strconv.Atoi(strings.TrimSpace(raw))
+		if err != nil {
+			cost = -1
+		}
+
+		costs[strings.ToLower(strings.TrimSpace(command))] = cost
+	}
+
+	return costs
+}
+
+// parseRanks lowercases rank names, as ranks are stored.
+func parseRanks(entries []string) []string {
+	ranks := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		ranks = append(ranks, strings.ToLower(entry))
+	}
+
+	return ranks
+}
+
+// RateLimitPolicies assembles the rate limit policy table.
+func (c *Config) RateLimitPolicies() *ratelimit.Policies {
+	return &ratelimit.Policies{
+		Default:     ratelimit.Limit{Requests: c.RateLimit.Requests, Period: c.RateLimit.Period},
+		Ranks:       c.RateLimit.Ranks,
+		Groups:      c.RateLimit.Groups,
+		Costs:       c.RateLimit.Costs,
+		ExemptRanks: c.RateLimit.ExemptRanks,
+		GroupTotal:  c.RateLimit.GroupTotal,
+		GlobalTotal: c.RateLimit.GlobalTotal,
+	}
+}
+
+// PenaltyPolicy returns the policy for escalating penalties, or nil when they
+// are off (PenaltyWindow is 0).
+func (c *Config) PenaltyPolicy() *ratelimit.PenaltyPolicy {
+	if c.RateLimit.PenaltyWindow == 0 {
+		return nil
+	}
+
+	return &ratelimit.PenaltyPolicy{
+		Window:      c.RateLimit.PenaltyWindow,
+		BanAfter:    c.RateLimit.PenaltyBanAfter,
+		BanDuration: c.RateLimit.PenaltyBanDuration,
+	}
 }
 
 func (e *envLoader) loadTiming() {
@@ -101,9 +272,37 @@ func (e *envLoader) loadTiming() {
 
 func (e *envLoader) loadAuth() {
 	e.cfg.Auth.DatabasePath = util.GetEnv("BOTEX_AUTH_DB_PATH", e.cfg.DBPath)
-	e.cfg.Auth.DefaultUserRank = util.GetEnv("BOTEX_AUTH_DEFAULT_RANK", "basic")
-	e.cfg.Auth.EnableWhatsAppAdmin = util.GetEnvBool("BOTEX_AUTH_ENABLE_WHATSAPP_ADMIN", true)
+	e.cfg.Auth.DefaultUserRank = util.GetEnv("BOTEX_AUTH_DEFAULT_RANK", DefaultAuthUserRank)
+	e.cfg.Auth.AutoRegister = util.GetEnvBool("BOTEX_AUTH_AUTO_REGISTER", false)
+	e.cfg.Auth.EnableWhatsAppAdmin = util.GetEnvBool("BOTEX_AUTH_ENABLE_WHATSAPP_ADMIN", false)
+	e.cfg.Auth.GroupInfoTTL = util.GetEnvDuration("BOTEX_AUTH_GROUP_INFO_TTL", DefaultAuthGroupInfoTTL)
 	e.cfg.Auth.ValidateSchema = util.GetEnvBool("BOTEX_AUTH_VALIDATE_SCHEMA", true)
+	e.cfg.Auth.CacheTTL = util.GetEnvDuration("BOTEX_AUTH_CACHE_TTL", DefaultAuthCacheTTL)
+	e.cfg.Auth.CacheSize = util.GetEnvInt("BOTEX_AUTH_CACHE_SIZE", DefaultAuthCacheSize)
+	e.cfg.Auth.OwnerJIDs = util.GetEnvList("BOTEX_OWNER_JIDS", "")
+	e.cfg.Auth.ClaimCode = util.GetEnvBool("BOTEX_AUTH_CLAIM_CODE", true)
+	e.cfg.Auth.ExpiryCheckInterval = util.GetEnvDuration("BOTEX_AUTH_EXPIRY_CHECK_INTERVAL", DefaultAuthExpiryCheckInterval)
+	e.cfg.Auth.ExpiryNotice = util.GetEnvDuration("BOTEX_AUTH_EXPIRY_NOTICE", DefaultAuthExpiryNotice)
+	e.cfg.Auth.AuditRetention = util.GetEnvDuration("BOTEX_AUTH_AUDIT_RETENTION", DefaultAuthAuditRetention)
+	e.cfg.Auth.RequestTTL = util.GetEnvDuration("BOTEX_AUTH_REQUEST_TTL", DefaultAuthRequestTTL)
+	e.cfg.Auth.RequestCooldown = util.GetEnvDuration("BOTEX_AUTH_REQUEST_COOLDOWN", DefaultAuthRequestCooldown)
+	e.cfg.Auth.RequestReviewRank = util.GetEnv("BOTEX_AUTH_REQUEST_REVIEW_RANK", DefaultAuthRequestReviewRank)
+	e.cfg.Auth.DenialMode = strings.ToLower(util.GetEnv("BOTEX_AUTH_DENIAL_MODE", DefaultAuthDenialMode))
+	e.cfg.Auth.DenialGroupModes = parseGroupModes(util.GetEnvList("BOTEX_AUTH_DENIAL_GROUP_MODES", ""))
+	e.cfg.Auth.DenialCooldown = util.GetEnvDuration("BOTEX_AUTH_DENIAL_COOLDOWN", DefaultAuthDenialCooldown)
+}
+
+// parseGroupModes reads "<group JID>=<mode>" entries. Entries without a mode
+// are kept with an empty one so validation reports them.
+func parseGroupModes(entries []string) map[string]string {
+	modes := make(map[string]string, len(entries))
+
+	for _, entry := range entries {
+		group, mode, _ := strings.Cut(entry, "=")
+		modes[strings.TrimSpace(group)] = strings.ToLower(strings.TrimSpace(mode))
+	}
+
+	return modes
 }
 
 func (e *envLoader) loadAll() {
@@ -159,17 +358,120 @@ func (c *Config) Validate() error {
 		return ErrRateLimitCleanupIntervalInvalid
 	}
 
+	if c.RateLimit.FlushInterval <= 0 {
+		return ErrRateLimitFlushIntervalInvalid
+	}
+
+	if !slices.Contains(ratelimit.Algorithms, c.RateLimit.Algorithm) {
+		return fmt.Errorf("%w: %q", ErrRateLimitAlgorithmInvalid, c.RateLimit.Algorithm)
+	}
+
+	err := c.RateLimitPolicies().Validate()
+	if err != nil {
+		return fmt.Errorf("RateLimit policies: %w", err)
+	}
+
+	err = c.validatePenalties()
+	if err != nil {
+		return err
+	}
+
 	if c.Timing.LogThreshold < 0 {
 		return ErrTimingLogThresholdInvalid
 	}
 
+	return c.validateAuth()
+}
+
+func (c *Config) validatePenalties() error {
+	if c.RateLimit.PenaltyWindow < 0 {
+		return ErrRateLimitPenaltyWindowInvalid
+	}
+
+	if c.RateLimit.PenaltyBanAfter < 0 {
+		return ErrRateLimitPenaltyBanAfterInvalid
+	}
+
+	// the duration only matters when penalties can end in a ban.
+	if c.RateLimit.PenaltyWindow > 0 && c.RateLimit.PenaltyBanAfter > 0 && c.RateLimit.PenaltyBanDuration <= 0 {
+		return ErrRateLimitPenaltyBanDurationInvalid
+	}
+
+	return nil
+}
+
+func (c *Config) validateAuth() error {
+	if c.Auth.GroupInfoTTL <= 0 {
+		return ErrAuthGroupInfoTTLInvalid
+	}
+
+	if c.Auth.CacheTTL < 0 {
+		return ErrAuthCacheTTLInvalid
+	}
+
+	if c.Auth.CacheSize <= 0 {
+		return ErrAuthCacheSizeInvalid
+	}
+
+	if c.Auth.ExpiryCheckInterval <= 0 {
+		return ErrAuthExpiryCheckIntervalInvalid
+	}
+
+	if c.Auth.ExpiryNotice < 0 {
+		return ErrAuthExpiryNoticeInvalid
+	}
+
+	if c.Auth.AuditRetention < 0 {
+		return ErrAuthAuditRetentionInvalid
+	}
+
+	if c.Auth.RequestTTL <= 0 {
+		return ErrAuthRequestTTLInvalid
+	}
+
+	if c.Auth.RequestCooldown < 0 {
+		return ErrAuthRequestCooldownInvalid
+	}
+
+	err := validateDenialModes(c.Auth.DenialMode, c.Auth.DenialGroupModes)
+	if err != nil {
+		return err
+	}
+
+	if c.Auth.DenialCooldown < 0 {
+		return ErrAuthDenialCooldownInvalid
+	}
+
 	if c.Auth.DatabasePath == "" {
 		c.Auth.DatabasePath = c.DBPath
 	}
 
 	if c.Auth.DefaultUserRank == "" {
-		c.Auth.DefaultUserRank = "basic"
+		c.Auth.DefaultUserRank = DefaultAuthUserRank
 	}
 
 	return nil
 }
+
+func validateDenialModes(global string, groups map[string]string) error {
+	if !validDenialMode(global) {
+		return fmt.Errorf("%w: %q", ErrAuthDenialModeInvalid, global)
+	}
+
+	for group, mode := range groups {
+		if group == "" || !validDenialMode(mode) {
+			return fmt.Errorf("%w: %q for group %q", ErrAuthDenialModeInvalid, mode, group)
+		}
+	}
+
+	return nil
+}
+
+func validDenialMode(mode string) bool {
+	switch mode {
+	case DenialModeReply, DenialModeReact, DenialModeDirect, DenialModeIgnore:
+		return true
+	default:
+		return false
+	}
+}
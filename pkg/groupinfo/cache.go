@@ -0,0 +1,127 @@
+package groupinfo
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"botex/pkg/logger"
+	"go.mau.fi/whatsmeow"
+	"go.mau.fi/whatsmeow/types"
+	"go.mau.fi/whatsmeow/types/events"
+)
+
+type entry struct {
+	// admins holds every identity (phone JID and LID) of each admin.
+	admins    map[string]struct{}
+	fetchedAt time.Time
+}
+
+// Cache keeps the admin list of groups the bot is in, so permission checks
+// do not hit the WhatsApp servers on every command. Entries expire after ttl
+// and are dropped whenever WhatsApp reports a membership change.
+type Cache struct {
+	client  *whatsmeow.Client
+	ttl     time.Duration
+	logger  *logger.Logger
+	mu      sync.RWMutex
+	entries map[types.JID]*entry
+}
+
+func NewCache(client *whatsmeow.Client, ttl time.Duration, log *logger.Logger) *Cache {
+	return &Cache{
+		client:  client,
+		ttl:     ttl,
+		logger:  log,
+		mu:      sync.RWMutex{},
+		entries: make(map[types.JID]*entry),
+	}
+}
+
+// IsGroupAdmin implements auth.GroupAdminChecker. Fetch failures are logged
+// and reported as "not an admin".
+func (c *Cache) IsGroupAdmin(ctx context.Context, groupID, userID string) bool {
+	group, err := types.ParseJID(groupID)
+	if err != nil {
+		return false
+	}
+
+	user, err := types.ParseJID(userID)
+	if err != nil {
+		return false
+	}
+
+	admins, err := c.admins(ctx, group)
+	if err != nil {
+		c.logger.Warn("Failed to fetch group metadata", map[string]interface{}{
+			"group": groupID,
+			"error": err.Error(),
+		})
+
+		return false
+	}
+
+	_, isAdmin := admins[user.ToNonAD().String()]
+
+	return isAdmin
+}
+
+// HandleEvent keeps the cache in sync with WhatsApp. Register it with
+// client.AddEventHandler.
+func (c *Cache) HandleEvent(evt interface{}) {
+	switch e := evt.(type) {
+	case *events.GroupInfo:
+		if len(e.Promote) > 0 || len(e.Demote) > 0 || len(e.Join) > 0 || len(e.Leave) > 0 || e.Delete != nil {
+			c.Invalidate(e.JID)
+		}
+	case *events.JoinedGroup:
+		c.store(e.JID, &e.GroupInfo)
+	}
+}
+
+func (c *Cache) Invalidate(group types.JID) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	delete(c.entries, group)
+}
+
+func (c *Cache) admins(ctx context.Context, group types.JID) (map[string]struct{}, error) {
+	c.mu.RLock()
+	cached, ok := c.entries[group]
+	c.mu.RUnlock()
+
+	if ok && time.Since(cached.fetchedAt) <= c.ttl {
+		return cached.admins, nil
+	}
+
+	info, err := c.client.GetGroupInfo(ctx, group)
+	if err != nil {
+		return nil, fmt.Errorf("get group info: %w", err)
+	}
+
+	return c.store(group, info), nil
+}
+
+func (c *Cache) store(group types.JID, info *types.GroupInfo) map[string]struct{} {
+	admins := make(map[string]struct{})
+
+	for _, participant := range info.Participants {
+		if !participant.IsAdmin && !participant.IsSuperAdmin {
+			continue
+		}
+
+		for _, jid := range []types.JID{participant.JID, participant.PhoneNumber, participant.LID} {
+			if !jid.IsEmpty() {
+				admins[jid.ToNonAD().String()] = struct{}{}
+			}
+		}
+	}
+
+	c.mu.Lock()
+	c.entries[group] = &entry{admins: admins, fetchedAt: time.Now()}
+	c.mu.Unlock()
+
+	return admins
+}
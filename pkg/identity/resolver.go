@@ -0,0 +1,51 @@
+// Package identity maps the different ways WhatsApp addresses a person onto
+// one identity for the auth service.
+package identity
+
+import (
+	"context"
+
+	"botex/pkg/logger"
+	"go.mau.fi/whatsmeow/store"
+	"go.mau.fi/whatsmeow/types"
+)
+
+// Resolver implements auth.IdentityResolver with the LID to phone-number
+// mappings whatsmeow records in its store whenever a message or
+// notification carries both.
+type Resolver struct {
+	lids   store.LIDStore
+	logger *logger.Logger
+}
+
+func NewResolver(lids store.LIDStore, log *logger.Logger) *Resolver {
+	return &Resolver{
+		lids:   lids,
+		logger: log,
+	}
+}
+
+// PhoneNumberForLID returns the phone-number JID behind a LID, or "" when it
+// is not known (yet) or the lookup fails.
+func (r *Resolver) PhoneNumberForLID(ctx context.Context, lid string) string {
+	jid, err := types.ParseJID(lid)
+	if err != nil || jid.Server != types.HiddenUserServer {
+		return ""
+	}
+
+	phone, err := r.lids.GetPNForLID(ctx, jid)
+	if err != nil {
+		r.logger.Warn("Failed to look up phone number for LID", map[string]interface{}{
+			"lid":   lid,
+			"error": err.Error(),
+		})
+
+		return ""
+	}
+
+	if phone.IsEmpty() {
+		return ""
+	}
+
+	return phone.ToNonAD().String()
+}
@@ -56,6 +56,31 @@ func NewMessage(evt *events.Message) *Message {
 	return msg
 }
 
+// MentionedJIDs returns the users mentioned in the message, in the order
+// WhatsApp reports them. Entries that fail to parse are skipped.
+func (m *Message) MentionedJIDs() []types.JID {
+	if m.ExtendedText == nil {
+		return nil
+	}
+
+	raw := m.ExtendedText.GetContextInfo().GetMentionedJID()
+	if len(raw) == 0 {
+		return nil
+	}
+
+	mentions := make([]types.JID, 0, len(raw))
+	for _, entry := range raw {
+		jid, err := types.ParseJID(entry)
+		if err != nil {
+			continue
+		}
+
+		mentions = append(mentions, jid)
+	}
+
+	return mentions
+}
+
 func (m *Message) GetText() string {
 	if m.Text != "" {
 		return m.Text
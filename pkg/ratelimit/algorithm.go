@@ -0,0 +1,56 @@
+package ratelimit
+
+import (
+	"errors"
+	"fmt"
+
+	"go.mau.fi/whatsmeow/types"
+)
+
+const (
+	AlgorithmSlidingLog  = "sliding_log"
+	AlgorithmTokenBucket = "token_bucket"
+	AlgorithmGCRA        = "gcra"
+)
+
+var ErrUnknownAlgorithm = errors.New("unknown rate limit algorithm")
+
+// Algorithms lists the names NewAlgorithm accepts.
+var Algorithms = []string{AlgorithmSlidingLog, AlgorithmTokenBucket, AlgorithmGCRA}
+
+// Algorithm decides whether a request of some cost fits a limit, keeping its
+// own state per key. The limit is passed on every call because it depends on
+// who sends what where (see Policies); a key whose limit changes keeps the
+// budget it has used. Implementations are safe for concurrent use.
+type Algorithm interface {
+	Cleanable
+	Persistent
+
+	// Check charges cost against the key's budget when it fits. ResetAfter is
+	// how long until the request would have fit when it was denied, and how
+	// long until the budget is whole again when it was allowed.
+	Check(key types.JID, limit Limit, cost int) Result
+
+	// Peek returns what Check would, without charging anything.
+	Peek(key types.JID, limit Limit, cost int) Result
+}
+
+// NewAlgorithm returns the algorithm with the given name:
+//
+//	sliding_log   exact window, remembers every request (memory grows with
+//	              the limit)
+//	token_bucket  budget refills continuously; O(1) memory per key
+//	gcra          same behavior as a token bucket, stored as one timestamp
+//	              per key
+func NewAlgorithm(name string) (Algorithm, error) {
+	switch name {
+	case AlgorithmSlidingLog:
+		return NewSlidingLog(), nil
+	case AlgorithmTokenBucket:
+		return NewTokenBucket(), nil
+	case AlgorithmGCRA:
+		return NewGCRA(), nil
+	default:
+		return nil, fmt.Errorf("%w: %q", ErrUnknownAlgorithm, name)
+	}
+}
@@ -0,0 +1,248 @@
+package ratelimit
+
+import (
+	"strconv"
+	"testing"
+	"time"
+
+	"go.mau.fi/whatsmeow/types"
+)
+
+// benchmarkSenders is how many distinct senders each benchmark spreads its
+// requests over, so the per-key maps hold a realistic amount of state.
+const benchmarkSenders = 5000
+
+// durationSlack absorbs the time that passes between a check and the
+// assertion on its ResetAfter.
+const durationSlack = 100 * time.Millisecond
+
+func testJID(n int) types.JID {
+	return types.NewJID(strconv.Itoa(51900000000+n), types.DefaultUserServer)
+}
+
+func newTestAlgorithm(t *testing.T, name string) Algorithm {
+	t.Helper()
+
+	algorithm, err := NewAlgorithm(name)
+	if err != nil {
+		t.Fatalf("NewAlgorithm(%q): %v", name, err)
+	}
+
+	return algorithm
+}
+
+func assertDuration(t *testing.T, what string, got, want time.Duration) {
+	t.Helper()
+
+	if got < want-durationSlack || got > want+durationSlack {
+		t.Errorf("%s = %v, want %v", what, got, want)
+	}
+}
+
+func TestNewAlgorithmUnknown(t *testing.T) {
+	_, err := NewAlgorithm("leaky_bucket")
+	if err == nil {
+		t.Error("NewAlgorithm accepted an unknown name")
+	}
+}
+
+func TestAlgorithmsAllowExactlyTheLimit(t *testing.T) {
+	limit := Limit{Requests: 5, Period: time.Minute}
+
+	for _, name := range Algorithms {
+		t.Run(name, func(t *testing.T) {
+			algorithm := newTestAlgorithm(t, name)
+			user := testJID(1)
+
+			for i := range limit.Requests {
+				if !algorithm.Check(user, limit, DefaultCost).Allowed {
+					t.Fatalf("request %d of %d denied", i+1, limit.Requests)
+				}
+			}
+
+			if algorithm.Check(user, limit, DefaultCost).Allowed {
+				t.Error("request over the limit allowed")
+			}
+
+			if !algorithm.Check(testJID(2), limit, DefaultCost).Allowed {
+				t.Error("another sender was denied")
+			}
+		})
+	}
+}
+
+func TestAlgorithmsResetAfter(t *testing.T) {
+	limit := Limit{Requests: 5, Period: time.Minute}
+	perRequest := limit.Period / time.Duration(limit.Requests)
+
+	tests := []struct {
+		name string
+		// allowed is ResetAfter for the first request, denied for the one
+		// over the limit.
+		allowed time.Duration
+		denied  time.Duration
+	}{
+		// the first request stays in the window for a whole period, and a
+		// denied request fits once it has left.
+		{AlgorithmSlidingLog, limit.Period, limit.Period},
+		// the budget refills one request per Period/Requests.
+		{AlgorithmTokenBucket, perRequest, perRequest},
+		{AlgorithmGCRA, perRequest, perRequest},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			algorithm := newTestAlgorithm(t, tt.name)
+			user := testJID(1)
+
+			first := algorithm.Check(user, limit, DefaultCost)
+			assertDuration(t, "allowed ResetAfter", first.ResetAfter, tt.allowed)
+
+			for range limit.Requests - 1 {
+				algorithm.Check(user, limit, DefaultCost)
+			}
+
+			denied := algorithm.Check(user, limit, DefaultCost)
+			if denied.Allowed {
+				t.Fatal("request over the limit allowed")
+			}
+
+			assertDuration(t, "denied ResetAfter", denied.ResetAfter, tt.denied)
+		})
+	}
+}
+
+func TestAlgorithmsCost(t *testing.T) {
+	limit := Limit{Requests: 5, Period: time.Minute}
+
+	for _, name := range Algorithms {
+		t.Run(name, func(t *testing.T) {
+			algorithm := newTestAlgorithm(t, name)
+			user := testJID(1)
+
+			if !algorithm.Check(user, limit, 3).Allowed {
+				t.Fatal("cost 3 of 5 denied")
+			}
+
+			if algorithm.Check(user, limit, 3).Allowed {
+				t.Error("cost 3 allowed with 2 left")
+			}
+
+			if !algorithm.Check(user, limit, 2).Allowed {
+				t.Error("cost 2 denied with 2 left")
+			}
+
+			if algorithm.Check(user, limit, 1).Allowed {
+				t.Error("request allowed with nothing left")
+			}
+		})
+	}
+}
+
+func TestAlgorithmsPeekChargesNothing(t *testing.T) {
+	limit := Limit{Requests: 3, Period: time.Minute}
+
+	for _, name := range Algorithms {
+		t.Run(name, func(t *testing.T) {
+			algorithm := newTestAlgorithm(t, name)
+			user := testJID(1)
+
+			for range 2 * limit.Requests {
+				if !algorithm.Peek(user, limit, DefaultCost).Allowed {
+					t.Fatal("peek denied on a fresh budget")
+				}
+			}
+
+			for i := range limit.Requests {
+				if !algorithm.Check(user, limit, DefaultCost).Allowed {
+					t.Fatalf("request %d denied after peeking", i+1)
+				}
+			}
+
+			peeked := algorithm.Peek(user, limit, DefaultCost)
+			checked := algorithm.Check(user, limit, DefaultCost)
+
+			if peeked.Allowed || checked.Allowed {
+				t.Errorf("over the limit: peek allowed %v, check allowed %v", peeked.Allowed, checked.Allowed)
+			}
+
+			assertDuration(t, "peek ResetAfter", peeked.ResetAfter, checked.ResetAfter)
+		})
+	}
+}
+
+func TestAlgorithmsRefill(t *testing.T) {
+	limit := Limit{Requests: 2, Period: 50 * time.Millisecond}
+
+	for _, name := range Algorithms {
+		t.Run(name, func(t *testing.T) {
+			algorithm := newTestAlgorithm(t, name)
+			user := testJID(1)
+
+			for range limit.Requests {
+				algorithm.Check(user, limit, DefaultCost)
+			}
+
+			if algorithm.Check(user, limit, DefaultCost).Allowed {
+				t.Fatal("request over the limit allowed")
+			}
+
+			time.Sleep(limit.Period + 10*time.Millisecond)
+
+			for i := range limit.Requests {
+				if !algorithm.Check(user, limit, DefaultCost).Allowed {
+					t.Fatalf("request %d denied after a whole period", i+1)
+				}
+			}
+		})
+	}
+}
+
+func TestAlgorithmsCleanupKeepsLiveState(t *testing.T) {
+	limit := Limit{Requests: 1, Period: time.Minute}
+
+	for _, name := range Algorithms {
+		t.Run(name, func(t *testing.T) {
+			algorithm := newTestAlgorithm(t, name)
+			user := testJID(1)
+
+			algorithm.Check(user, limit, DefaultCost)
+			algorithm.Cleanup()
+
+			if algorithm.Check(user, limit, DefaultCost).Allowed {
+				t.Error("cleanup dropped a budget that was still in use")
+			}
+		})
+	}
+}
+
+func benchmarkAlgorithm(b *testing.B, algorithm Algorithm) {
+	b.Helper()
+
+	senders := make([]types.JID, benchmarkSenders)
+	for i := range senders {
+		senders[i] = testJID(i)
+	}
+
+	limit := Limit{Requests: 20, Period: time.Minute}
+
+	b.ReportAllocs()
+
+	i := 0
+	for b.Loop() {
+		algorithm.Check(senders[i%len(senders)], limit, DefaultCost)
+		i++
+	}
+}
+
+func BenchmarkSlidingLog(b *testing.B) {
+	benchmarkAlgorithm(b, NewSlidingLog())
+}
+
+func BenchmarkTokenBucket(b *testing.B) {
+	benchmarkAlgorithm(b, NewTokenBucket())
+}
+
+func BenchmarkGCRA(b *testing.B) {
+	benchmarkAlgorithm(b, NewGCRA())
+}
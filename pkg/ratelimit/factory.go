@@ -18,7 +18,7 @@ type Manager struct {
 }
 
 func NewManager(requests int, period time.Duration) *Manager {
-	limiter := NewLimiter(requests, period)
+	limiter := NewLimiter(NewSlidingLog(), requests, period)
 	notifier := NewNotifier(period)
 	cleaner := NewAutoCleaner(period / cleanupPeriodDivisor)
 
@@ -0,0 +1,87 @@
+package ratelimit
+
+import (
+	"sync"
+	"time"
+
+	"go.mau.fi/whatsmeow/types"
+)
+
+// GCRA (the generic cell rate algorithm) behaves like TokenBucket but stores
+// a single timestamp per key: the theoretical arrival time, when the key's
+// budget will be whole again. A request fits while that time stays within
+// one Period from now.
+type GCRA struct {
+	mu   sync.Mutex
+	tats map[types.JID]time.Time
+}
+
+func NewGCRA() *GCRA {
+	return &GCRA{
+		mu:   sync.Mutex{},
+		tats: make(map[types.JID]time.Time),
+	}
+}
+
+func (g *GCRA) Check(key types.JID, limit Limit, cost int) Result {
+	return g.check(key, limit, cost, true)
+}
+
+func (g *GCRA) Peek(key types.JID, limit Limit, cost int) Result {
+	return g.check(key, limit, cost, false)
+}
+
+func (g *GCRA) check(key types.JID, limit Limit, cost int, charge bool) Result {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	now := time.Now()
+	increment := limit.Period / time.Duration(limit.Requests) * time.Duration(cost)
+
+	tat := g.tats[key]
+	if tat.Before(now) {
+		tat = now
+	}
+
+	next := tat.Add(increment)
+
+	if over := next.Sub(now) - limit.Period; over > 0 {
+		return Result{Allowed: false, ResetAfter: over}
+	}
+
+	if charge {
+		g.tats[key] = next
+	}
+
+	return Result{Allowed: true, ResetAfter: next.Sub(now)}
+}
+
+func (g *GCRA) Cleanup() {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	now := time.Now()
+	for key, tat := range g.tats {
+		if !now.Before(tat) {
+			delete(g.tats, key)
+		}
+	}
+}
+
+func (g *GCRA) Snapshot() (map[string][]byte, error) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	now := time.Now()
+
+	return snapshotEntries(g.tats, now.Before)
+}
+
+func (g *GCRA) Restore(entries map[string][]byte) error {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	return restoreEntries(entries, func(key types.JID, tat time.Time) {
+		g.tats[key] = tat
+	})
+}
@@ -12,63 +12,82 @@ type Result struct {
 	ResetAfter time.Duration
 }
 
+// Scope is whose budget a request is counted against.
+type Scope string
+
+const (
+	ScopeUser   Scope = "user"   // the sender
+	ScopeGroup  Scope = "group"  // everyone in the group chat together
+	ScopeGlobal Scope = "global" // everyone, bot-wide
+)
+
+// Charge is one budget a request is counted against.
+type Charge struct {
+	Scope Scope
+	Key   types.JID
+	Limit Limit
+	Cost  int
+}
+
+// Limiter applies an Algorithm, with a default limit for callers that do
+// not resolve one per request.
 type Limiter struct {
+	// mu makes CheckAll's look-then-charge atomic.
 	mu          sync.Mutex
-	requests    map[types.JID][]time.Time
+	algorithm   Algorithm
 	maxRequests int
 	period      time.Duration
 }
 
-func NewLimiter(maxRequests int, period time.Duration) *Limiter {
+func NewLimiter(algorithm Algorithm, maxRequests int, period time.Duration) *Limiter {
 	return &Limiter{
 		mu:          sync.Mutex{},
-		requests:    make(map[types.JID][]time.Time),
+		algorithm:   algorithm,
 		maxRequests: maxRequests,
 		period:      period,
 	}
 }
 
+// Check counts one request of cost 1 against the limiter's own limit.
 func (l *Limiter) Check(user types.JID) Result {
+	return l.CheckLimit(user, Limit{Requests: l.maxRequests, Period: l.period}, 1)
+}
+
+// CheckLimit counts a request of the given cost against limit. The budget is
+// shared, so a user whose limit depends on where they write is charged for
+// all of their requests either way.
+func (l *Limiter) CheckLimit(user types.JID, limit Limit, cost int) Result {
 	l.mu.Lock()
 	defer l.mu.Unlock()
 
-	now := time.Now()
-	requests := l.requests[user]
-
-	// Filter valid requests and find earliest timestamp
-	var (
-		validRequests []time.Time
-		earliest      time.Time
-	)
-
-	for _, t := range requests {
-		if now.Sub(t) <= l.period {
-			validRequests = append(validRequests, t)
-			if earliest.IsZero() || t.Before(earliest) {
-				earliest = t
-			}
-		}
-	}
+	return l.algorithm.Check(user, limit, cost)
+}
+
+// CheckAll counts a request against several budgets: it is charged to all of
+// them when it fits every one, and to none otherwise. A denied request
+// returns the scope of the first budget it did not fit; an allowed one
+// returns the result of the first charge.
+func (l *Limiter) CheckAll(charges []Charge) (Result, Scope) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
 
-	// Calculate reset time
-	resetAfter := l.period
-	if len(validRequests) > 0 {
-		resetAfter = l.period - now.Sub(earliest)
-		if resetAfter < 0 {
-			resetAfter = 0
+	for _, charge := range charges {
+		result := l.algorithm.Peek(charge.Key, charge.Limit, charge.Cost)
+		if !result.Allowed {
+			return result, charge.Scope
 		}
 	}
 
-	allowed := len(validRequests) < l.maxRequests
-	if allowed {
-		validRequests = append(validRequests, now)
-		l.requests[user] = validRequests
-	}
+	result := Result{Allowed: true}
 
-	return Result{
-		Allowed:    allowed,
-		ResetAfter: resetAfter,
+	for i, charge := range charges {
+		charged := l.algorithm.Check(charge.Key, charge.Limit, charge.Cost)
+		if i == 0 {
+			result = charged
+		}
 	}
+
+	return result, ""
 }
 
 func (l *Limiter) Allow(user types.JID) bool {
@@ -76,23 +95,5 @@ func (l *Limiter) Allow(user types.JID) bool {
 }
 
 func (l *Limiter) Cleanup() {
-	l.mu.Lock()
-	defer l.mu.Unlock()
-
-	now := time.Now()
-	for user, requests := range l.requests {
-		var valid []time.Time
-
-		for _, t := range requests {
-			if now.Sub(t) <= l.period {
-				valid = append(valid, t)
-			}
-		}
-
-		if len(valid) == 0 {
-			delete(l.requests, user)
-		} else {
-			l.requests[user] = valid
-		}
-	}
+	l.algorithm.Cleanup()
 }
@@ -0,0 +1,114 @@
+package ratelimit
+
+import (
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"go.mau.fi/whatsmeow/types"
+)
+
+func TestLimiterCheckAllChargesAllOrNothing(t *testing.T) {
+	userLimit := Limit{Requests: 5, Period: time.Minute}
+	groupLimit := Limit{Requests: 2, Period: time.Minute}
+	group := types.NewJID("120363000000000000", types.GroupServer)
+
+	for _, name := range Algorithms {
+		t.Run(name, func(t *testing.T) {
+			algorithm := newTestAlgorithm(t, name)
+			limiter := NewLimiter(algorithm, userLimit.Requests, userLimit.Period)
+			user := testJID(1)
+
+			charges := []Charge{
+				{Scope: ScopeUser, Key: user, Limit: userLimit, Cost: DefaultCost},
+				{Scope: ScopeGroup, Key: group, Limit: groupLimit, Cost: DefaultCost},
+			}
+
+			for i := range groupLimit.Requests {
+				result, scope := limiter.CheckAll(charges)
+				if !result.Allowed {
+					t.Fatalf("request %d denied by %s", i+1, scope)
+				}
+			}
+
+			result, scope := limiter.CheckAll(charges)
+			if result.Allowed || scope != ScopeGroup {
+				t.Fatalf("CheckAll = allowed %v, scope %q; want denied by %q", result.Allowed, scope, ScopeGroup)
+			}
+
+			// the denied request must not have used the sender's budget.
+			left := userLimit.Requests - groupLimit.Requests
+			for i := range left {
+				if !algorithm.Check(user, userLimit, DefaultCost).Allowed {
+					t.Fatalf("sender's request %d of %d left denied", i+1, left)
+				}
+			}
+
+			if algorithm.Check(user, userLimit, DefaultCost).Allowed {
+				t.Error("sender's budget was not charged for the allowed requests")
+			}
+		})
+	}
+}
+
+func TestLimiterCheckAllReportsFirstDeniedScope(t *testing.T) {
+	limit := Limit{Requests: 1, Period: time.Minute}
+	limiter := NewLimiter(NewGCRA(), limit.Requests, limit.Period)
+	user := testJID(1)
+
+	charges := []Charge{
+		{Scope: ScopeUser, Key: user, Limit: limit, Cost: DefaultCost},
+		{Scope: ScopeGlobal, Key: globalKey, Limit: limit, Cost: DefaultCost},
+	}
+
+	result, scope := limiter.CheckAll(charges)
+	if !result.Allowed || scope != "" {
+		t.Fatalf("first request: allowed %v, scope %q", result.Allowed, scope)
+	}
+
+	_, scope = limiter.CheckAll(charges)
+	if scope != ScopeUser {
+		t.Errorf("scope %q, want %q", scope, ScopeUser)
+	}
+}
+
+func TestLimiterCheckAllConcurrent(t *testing.T) {
+	const senders = 200
+
+	global := Limit{Requests: 25, Period: time.Minute}
+	perUser := Limit{Requests: 5, Period: time.Minute}
+
+	for _, name := range Algorithms {
+		t.Run(name, func(t *testing.T) {
+			limiter := NewLimiter(newTestAlgorithm(t, name), perUser.Requests, perUser.Period)
+
+			var (
+				allowed atomic.Int64
+				wg      sync.WaitGroup
+			)
+
+			for i := range senders {
+				wg.Add(1)
+
+				go func() {
+					defer wg.Done()
+
+					result, _ := limiter.CheckAll([]Charge{
+						{Scope: ScopeUser, Key: testJID(i), Limit: perUser, Cost: DefaultCost},
+						{Scope: ScopeGlobal, Key: globalKey, Limit: global, Cost: DefaultCost},
+					})
+					if result.Allowed {
+						allowed.Add(1)
+					}
+				}()
+			}
+
+			wg.Wait()
+
+			if got := allowed.Load(); got != int64(global.Requests) {
+				t.Errorf("%d requests allowed, want exactly %d", got, global.Requests)
+			}
+		})
+	}
+}
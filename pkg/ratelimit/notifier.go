@@ -55,3 +55,23 @@ func (n *Notifier) Cleanup() {
 		}
 	}
 }
+
+func (n *Notifier) Snapshot() (map[string][]byte, error) {
+	n.mu.RLock()
+	defer n.mu.RUnlock()
+
+	now := time.Now()
+
+	return snapshotEntries(n.notifiedTime, func(lastNotify time.Time) bool {
+		return now.Sub(lastNotify) <= n.cooldown
+	})
+}
+
+func (n *Notifier) Restore(entries map[string][]byte) error {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	return restoreEntries(entries, func(user types.JID, lastNotify time.Time) {
+		n.notifiedTime[user] = lastNotify
+	})
+}
@@ -0,0 +1,245 @@
+package ratelimit
+
+import (
+	"cmp"
+	"context"
+	"slices"
+	"sync"
+	"time"
+
+	"go.mau.fi/whatsmeow/types"
+)
+
+// Banner bans users on behalf of Penalties. The auth service implements it;
+// the rate limiter stays free of the auth package.
+type Banner interface {
+	BanUser(ctx context.Context, userID, reason string, expiresAt *time.Time, actorID string) error
+
+	// Penalizable reports whether a user may get strikes at all. Users it
+	// spares (owners) only ever wait out the limit they hit.
+	Penalizable(ctx context.Context, userID string) bool
+}
+
+// PenaltyPolicy configures Penalties. A sender that hits their limit gets a
+// strike; strikes older than Window are forgiven one Window at a time.
+// BanAfter strikes within that time earn a ban of BanDuration (0 never bans).
+type PenaltyPolicy struct {
+	Window      time.Duration
+	BanAfter    int
+	BanDuration time.Duration
+}
+
+type offense struct {
+	Strikes    int       `json:"strikes"`
+	LastStrike time.Time `json:"last_strike"`
+	// BlockedUntil is when the sender may try again. Requests before then
+	// are turned away without consulting the limiter or adding strikes.
+	BlockedUntil time.Time `json:"blocked_until"`
+}
+
+// decay forgives one strike for every Window since the last one.
+func (o *offense) decay(now time.Time, window time.Duration) {
+	if o.Strikes == 0 {
+		return
+	}
+
+	forgiven := int(now.Sub(o.LastStrike) / window)
+	if forgiven <= 0 {
+		return
+	}
+
+	o.Strikes = max(o.Strikes-forgiven, 0)
+	o.LastStrike = o.LastStrike.Add(time.Duration(forgiven) * window)
+}
+
+// expired reports whether nothing is left to remember about the offense.
+func (o *offense) expired(now time.Time) bool {
+	return o.Strikes == 0 && !now.Before(o.BlockedUntil)
+}
+
+// Penalty is what a strike earned.
+type Penalty struct {
+	Strikes int
+	// BlockedFor is how long the sender has to wait: the reset time of the
+	// limit they hit, doubled for every earlier strike.
+	BlockedFor time.Duration
+	// Ban is set when the strikes reached PenaltyPolicy.BanAfter. The
+	// strikes are reset; the ban takes over.
+	Ban bool
+}
+
+// Offender is a sender Penalties is keeping track of.
+type Offender struct {
+	User         types.JID
+	Strikes      int
+	LastStrike   time.Time
+	BlockedUntil time.Time
+}
+
+// Penalties escalates the wait for senders that keep hitting their limit.
+type Penalties struct {
+	mu       sync.Mutex
+	offenses map[types.JID]*offense
+	policy   PenaltyPolicy
+}
+
+func NewPenalties(policy PenaltyPolicy) *Penalties {
+	return &Penalties{
+		mu:       sync.Mutex{},
+		offenses: make(map[types.JID]*offense),
+		policy:   policy,
+	}
+}
+
+func (p *Penalties) Policy() PenaltyPolicy {
+	return p.policy
+}
+
+// Blocked returns how long user still has to wait, or 0.
+func (p *Penalties) Blocked(user types.JID) time.Duration {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	o, ok := p.offenses[user]
+	if !ok {
+		return 0
+	}
+
+	return max(time.Until(o.BlockedUntil), 0)
+}
+
+// Strike records that user hit a limit that resets after resetAfter.
+func (p *Penalties) Strike(user types.JID, resetAfter time.Duration) Penalty {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	now := time.Now()
+
+	o, ok := p.offenses[user]
+	if !ok {
+		o = &offense{}
+		p.offenses[user] = o
+	}
+
+	o.decay(now, p.policy.Window)
+	o.Strikes++
+	o.LastStrike = now
+
+	if p.policy.BanAfter > 0 && o.Strikes >= p.policy.BanAfter {
+		delete(p.offenses, user)
+
+		return Penalty{Strikes: o.Strikes, BlockedFor: p.policy.BanDuration, Ban: true}
+	}
+
+	blockedFor := backoff(resetAfter, o.Strikes, p.policy.Window)
+	o.BlockedUntil = now.Add(blockedFor)
+
+	return Penalty{Strikes: o.Strikes, BlockedFor: blockedFor}
+}
+
+// backoff doubles base for every strike after the first, up to ceiling (or
+// base, when that is larger).
+func backoff(base time.Duration, strikes int, ceiling time.Duration) time.Duration {
+	wait := base
+
+	for range strikes - 1 {
+		if wait >= ceiling {
+			break
+		}
+
+		wait *= 2
+	}
+
+	return max(min(wait, ceiling), base)
+}
+
+// Block turns user away for d without adding a strike.
+func (p *Penalties) Block(user types.JID, d time.Duration) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	o, ok := p.offenses[user]
+	if !ok {
+		o = &offense{}
+		p.offenses[user] = o
+	}
+
+	o.BlockedUntil = time.Now().Add(d)
+}
+
+// Forgive drops user's strikes and block. It reports whether there were any.
+func (p *Penalties) Forgive(user types.JID) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	_, ok := p.offenses[user]
+	delete(p.offenses, user)
+
+	return ok
+}
+
+// List returns the current offenders, most strikes first.
+func (p *Penalties) List() []Offender {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	now := time.Now()
+	offenders := make([]Offender, 0, len(p.offenses))
+
+	for user, o := range p.offenses {
+		o.decay(now, p.policy.Window)
+		if o.expired(now) {
+			continue
+		}
+
+		offenders = append(offenders, Offender{
+			User:         user,
+			Strikes:      o.Strikes,
+			LastStrike:   o.LastStrike,
+			BlockedUntil: o.BlockedUntil,
+		})
+	}
+
+	slices.SortFunc(offenders, func(a, b Offender) int {
+		return cmp.Or(cmp.Compare(b.Strikes, a.Strikes), cmp.Compare(a.User.String(), b.User.String()))
+	})
+
+	return offenders
+}
+
+func (p *Penalties) Cleanup() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	now := time.Now()
+	for user, o := range p.offenses {
+		o.decay(now, p.policy.Window)
+		if o.expired(now) {
+			delete(p.offenses, user)
+		}
+	}
+}
+
+func (p *Penalties) Snapshot() (map[string][]byte, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	now := time.Now()
+
+	return snapshotEntries(p.offenses, func(o *offense) bool {
+		o.decay(now, p.policy.Window)
+
+		return !o.expired(now)
+	})
+}
+
+func (p *Penalties) Restore(entries map[string][]byte) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	return restoreEntries(entries, func(user types.JID, o *offense) {
+		if o != nil {
+			p.offenses[user] = o
+		}
+	})
+}
@@ -0,0 +1,177 @@
+package ratelimit
+
+import (
+	"testing"
+	"time"
+)
+
+func TestBackoff(t *testing.T) {
+	tests := []struct {
+		base    time.Duration
+		strikes int
+		ceiling time.Duration
+		want    time.Duration
+	}{
+		{10 * time.Second, 1, time.Hour, 10 * time.Second},
+		{10 * time.Second, 2, time.Hour, 20 * time.Second},
+		{10 * time.Second, 4, time.Hour, 80 * time.Second},
+		{10 * time.Second, 20, time.Minute, time.Minute},
+		// a limit that resets after the ceiling is never shortened.
+		{2 * time.Hour, 3, time.Hour, 2 * time.Hour},
+	}
+
+	for _, tt := range tests {
+		got := backoff(tt.base, tt.strikes, tt.ceiling)
+		if got != tt.want {
+			t.Errorf("backoff(%v, %d, %v) = %v, want %v", tt.base, tt.strikes, tt.ceiling, got, tt.want)
+		}
+	}
+}
+
+func TestPenaltiesStrikeEscalates(t *testing.T) {
+	penalties := NewPenalties(PenaltyPolicy{Window: time.Minute, BanAfter: 0, BanDuration: 0})
+	user := testJID(1)
+
+	for i, want := range []time.Duration{10 * time.Second, 20 * time.Second, 40 * time.Second, time.Minute, time.Minute} {
+		penalty := penalties.Strike(user, 10*time.Second)
+
+		if penalty.Strikes != i+1 || penalty.Ban {
+			t.Fatalf("strike %d: %+v", i+1, penalty)
+		}
+
+		if penalty.BlockedFor != want {
+			t.Errorf("strike %d blocked for %v, want %v", i+1, penalty.BlockedFor, want)
+		}
+	}
+
+	assertDuration(t, "Blocked", penalties.Blocked(user), time.Minute)
+
+	if penalties.Blocked(testJID(2)) != 0 {
+		t.Error("a sender without strikes is blocked")
+	}
+
+	if !penalties.Forgive(user) {
+		t.Error("Forgive found no strikes to drop")
+	}
+
+	if penalties.Blocked(user) != 0 {
+		t.Error("sender still blocked after Forgive")
+	}
+
+	if penalties.Forgive(user) {
+		t.Error("second Forgive reported strikes")
+	}
+}
+
+func TestPenaltiesBan(t *testing.T) {
+	policy := PenaltyPolicy{Window: time.Hour, BanAfter: 3, BanDuration: 24 * time.Hour}
+	penalties := NewPenalties(policy)
+	user := testJID(1)
+
+	for i := range policy.BanAfter - 1 {
+		if penalties.Strike(user, time.Second).Ban {
+			t.Fatalf("banned on strike %d of %d", i+1, policy.BanAfter)
+		}
+	}
+
+	penalty := penalties.Strike(user, time.Second)
+	if !penalty.Ban || penalty.Strikes != policy.BanAfter || penalty.BlockedFor != policy.BanDuration {
+		t.Fatalf("strike %d = %+v, want a ban for %v", policy.BanAfter, penalty, policy.BanDuration)
+	}
+
+	// the ban takes over; the strikes start again from nothing.
+	if offenders := penalties.List(); len(offenders) != 0 {
+		t.Errorf("offenders after the ban: %+v", offenders)
+	}
+
+	if penalty := penalties.Strike(user, time.Second); penalty.Strikes != 1 {
+		t.Errorf("first strike after the ban counted %d", penalty.Strikes)
+	}
+}
+
+func TestOffenseDecay(t *testing.T) {
+	now := time.Now()
+	window := time.Hour
+
+	o := &offense{Strikes: 3, LastStrike: now.Add(-150 * time.Minute), BlockedUntil: time.Time{}}
+	o.decay(now, window)
+
+	if o.Strikes != 1 {
+		t.Errorf("strikes after two windows = %d, want 1", o.Strikes)
+	}
+
+	// the part of a window that has not passed yet still counts.
+	if want := now.Add(-30 * time.Minute); !o.LastStrike.Equal(want) {
+		t.Errorf("last strike moved to %v, want %v", o.LastStrike, want)
+	}
+
+	if o.expired(now) {
+		t.Error("offense with a strike left expired")
+	}
+
+	o.decay(now.Add(time.Hour), window)
+
+	if o.Strikes != 0 || !o.expired(now.Add(time.Hour)) {
+		t.Errorf("offense not expired after its last window: %+v", o)
+	}
+
+	blocked := &offense{Strikes: 0, LastStrike: time.Time{}, BlockedUntil: now.Add(time.Minute)}
+	if blocked.expired(now) {
+		t.Error("offense expired while the sender is still blocked")
+	}
+}
+
+func TestPenaltiesList(t *testing.T) {
+	penalties := NewPenalties(PenaltyPolicy{Window: time.Hour, BanAfter: 0, BanDuration: 0})
+
+	penalties.Strike(testJID(1), time.Second)
+
+	for range 3 {
+		penalties.Strike(testJID(2), time.Second)
+	}
+
+	penalties.Strike(testJID(3), time.Second)
+	penalties.Strike(testJID(3), time.Second)
+
+	offenders := penalties.List()
+	if len(offenders) != 3 {
+		t.Fatalf("%d offenders, want 3", len(offenders))
+	}
+
+	for i, want := range []int{3, 2, 1} {
+		if offenders[i].Strikes != want {
+			t.Errorf("offender %d has %d strikes, want %d", i, offenders[i].Strikes, want)
+		}
+	}
+
+	if offenders[0].User != testJID(2) {
+		t.Errorf("first offender %s, want %s", offenders[0].User, testJID(2))
+	}
+}
+
+func TestPenaltiesSnapshotRestore(t *testing.T) {
+	policy := PenaltyPolicy{Window: time.Hour, BanAfter: 0, BanDuration: 0}
+	saved := NewPenalties(policy)
+	user := testJID(1)
+
+	saved.Strike(user, time.Minute)
+	saved.Strike(user, time.Minute)
+
+	entries, err := saved.Snapshot()
+	if err != nil {
+		t.Fatalf("Snapshot: %v", err)
+	}
+
+	restored := NewPenalties(policy)
+
+	err = restored.Restore(entries)
+	if err != nil {
+		t.Fatalf("Restore: %v", err)
+	}
+
+	assertDuration(t, "Blocked", restored.Blocked(user), saved.Blocked(user))
+
+	if penalty := restored.Strike(user, time.Minute); penalty.Strikes != 3 {
+		t.Errorf("strike after restore counted %d, want 3", penalty.Strikes)
+	}
+}
@@ -0,0 +1,155 @@
+package ratelimit
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"botex/pkg/logger"
+	"go.mau.fi/whatsmeow/types"
+)
+
+// flushTimeout bounds one periodic flush.
+const flushTimeout = 30 * time.Second
+
+// Persistent is state a Persister can save to a Store and load back.
+// Snapshot leaves out keys with nothing left to remember; Restore merges the
+// entries into the current state and skips the ones it cannot read.
+type Persistent interface {
+	Snapshot() (map[string][]byte, error)
+	Restore(entries map[string][]byte) error
+}
+
+// Persister saves Persistent components to a Store and loads them back on
+// start. It implements Cleanable, so an AutoCleaner can flush it
+// periodically.
+type Persister struct {
+	mu         sync.Mutex
+	store      Store
+	components map[string]Persistent
+	logger     *logger.Logger
+}
+
+func NewPersister(store Store, log *logger.Logger) *Persister {
+	return &Persister{
+		mu:         sync.Mutex{},
+		store:      store,
+		components: make(map[string]Persistent),
+		logger:     log,
+	}
+}
+
+// Register adds a component under a name that must stay the same across
+// restarts for its state to be found again.
+func (p *Persister) Register(name string, component Persistent) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.components[name] = component
+}
+
+// Load restores every registered component from the store. Unreadable
+// entries are skipped and reported; the rest is still restored.
+func (p *Persister) Load(ctx context.Context) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	var errs []error
+
+	for name, component := range p.components {
+		entries, err := p.store.Load(ctx, name)
+		if err != nil {
+			return fmt.Errorf("load %s: %w", name, err)
+		}
+
+		err = component.Restore(entries)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("restore %s: %w", name, err))
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// Flush saves every registered component to the store.
+func (p *Persister) Flush(ctx context.Context) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	for name, component := range p.components {
+		entries, err := component.Snapshot()
+		if err != nil {
+			return fmt.Errorf("snapshot %s: %w", name, err)
+		}
+
+		err = p.store.Save(ctx, name, entries)
+		if err != nil {
+			return fmt.Errorf("save %s: %w", name, err)
+		}
+	}
+
+	return nil
+}
+
+func (p *Persister) Cleanup() {
+	ctx, cancel := context.WithTimeout(context.Background(), flushTimeout)
+	defer cancel()
+
+	err := p.Flush(ctx)
+	if err != nil {
+		p.logger.Error("Failed to save rate limit state", map[string]interface{}{
+			"error": err.Error(),
+		})
+	}
+}
+
+// snapshotEntries encodes the per-key state that keep accepts.
+func snapshotEntries[T any](state map[types.JID]T, keep func(T) bool) (map[string][]byte, error) {
+	entries := make(map[string][]byte, len(state))
+
+	for key, value := range state {
+		if !keep(value) {
+			continue
+		}
+
+		data, err := json.Marshal(value)
+		if err != nil {
+			return nil, fmt.Errorf("encode %s: %w", key, err)
+		}
+
+		entries[key.String()] = data
+	}
+
+	return entries, nil
+}
+
+// restoreEntries decodes entries and hands each to set, skipping the ones
+// that cannot be read.
+func restoreEntries[T any](entries map[string][]byte, set func(types.JID, T)) error {
+	var errs []error
+
+	for rawKey, data := range entries {
+		key, err := types.ParseJID(rawKey)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("key %q: %w", rawKey, err))
+
+			continue
+		}
+
+		var value T
+
+		err = json.Unmarshal(data, &value)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("decode %s: %w", rawKey, err))
+
+			continue
+		}
+
+		set(key, value)
+	}
+
+	return errors.Join(errs...)
+}
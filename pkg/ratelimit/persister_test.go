@@ -0,0 +1,111 @@
+package ratelimit
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestPersisterRoundTrip(t *testing.T) {
+	limit := Limit{Requests: 2, Period: time.Minute}
+
+	for _, name := range Algorithms {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			store := NewMemoryStore()
+			user := testJID(1)
+
+			saved := newTestAlgorithm(t, name)
+			savedNotifier := NewNotifier(time.Minute)
+
+			for range limit.Requests {
+				saved.Check(user, limit, DefaultCost)
+			}
+
+			savedNotifier.ShouldNotify(user)
+
+			persister := NewPersister(store, nil)
+			persister.Register("algorithm", saved)
+			persister.Register("notifier", savedNotifier)
+
+			err := persister.Flush(ctx)
+			if err != nil {
+				t.Fatalf("Flush: %v", err)
+			}
+
+			// a restart: fresh state, loaded from the same store.
+			restored := newTestAlgorithm(t, name)
+			restoredNotifier := NewNotifier(time.Minute)
+
+			persister = NewPersister(store, nil)
+			persister.Register("algorithm", restored)
+			persister.Register("notifier", restoredNotifier)
+
+			err = persister.Load(ctx)
+			if err != nil {
+				t.Fatalf("Load: %v", err)
+			}
+
+			if restored.Check(user, limit, DefaultCost).Allowed {
+				t.Error("used budget was reset by the restart")
+			}
+
+			if !restored.Check(testJID(2), limit, DefaultCost).Allowed {
+				t.Error("sender without saved state was denied")
+			}
+
+			if restoredNotifier.ShouldNotify(user) {
+				t.Error("notification cooldown was reset by the restart")
+			}
+		})
+	}
+}
+
+func TestPersisterLoadSkipsBadEntries(t *testing.T) {
+	limit := Limit{Requests: 1, Period: time.Minute}
+	user := testJID(1)
+
+	tests := []struct {
+		name  string
+		key   string
+		value []byte
+	}{
+		{"unparsable key", "1.2.3@s.whatsapp.net", []byte("{}")},
+		{"unreadable value", testJID(2).String(), []byte("{garbage")},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			store := NewMemoryStore()
+
+			saved := NewGCRA()
+			saved.Check(user, limit, DefaultCost)
+
+			entries, err := saved.Snapshot()
+			if err != nil {
+				t.Fatalf("Snapshot: %v", err)
+			}
+
+			entries[tt.key] = tt.value
+
+			err = store.Save(ctx, "gcra", entries)
+			if err != nil {
+				t.Fatalf("Save: %v", err)
+			}
+
+			restored := NewGCRA()
+			persister := NewPersister(store, nil)
+			persister.Register("gcra", restored)
+
+			err = persister.Load(ctx)
+			if err == nil {
+				t.Error("Load reported no error for an unreadable entry")
+			}
+
+			if restored.Check(user, limit, DefaultCost).Allowed {
+				t.Error("readable entry was not restored")
+			}
+		})
+	}
+}
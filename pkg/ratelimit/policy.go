@@ -0,0 +1,130 @@
+package ratelimit
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"botex/pkg/util"
+)
+
+// DefaultCost is what a command costs when Policies has no entry for it.
+const DefaultCost = 1
+
+var ErrInvalidLimit = errors.New("invalid rate limit")
+
+// Limit is a budget of Requests (in cost units) per Period.
+type Limit struct {
+	Requests int
+	Period   time.Duration
+}
+
+// ParseLimit reads limits written as "<requests>/<period>", e.g. "20/1m" or
+// "100/1d".
+func ParseLimit(raw string) (Limit, error) {
+	rawRequests, rawPeriod, ok := strings.Cut(strings.TrimSpace(raw), "/")
+	if !ok {
+		return Limit{}, fmt.Errorf("%w: %q, expected <requests>/<period>", ErrInvalidLimit, raw)
+	}
+
+	requests, err := strconv.Atoi(rawRequests)
+	if err != nil || requests <= 0 {
+		return Limit{}, fmt.Errorf("%w: %q, requests must be a positive number", ErrInvalidLimit, raw)
+	}
+
+	period, err := util.ParseDuration(rawPeriod)
+	if err != nil {
+		return Limit{}, fmt.Errorf("%w: %q: %w", ErrInvalidLimit, raw, err)
+	}
+
+	return Limit{Requests: requests, Period: period}, nil
+}
+
+func (l Limit) String() string {
+	return fmt.Sprintf("%d/%s", l.Requests, l.Period)
+}
+
+// Policies decides which limit applies to a request and what it costs. A
+// rank's limit takes precedence over the group's, which takes precedence over
+// Default, so a TA keeps their allowance in a group with a tighter limit.
+// Ranks in ExemptRanks are not limited at all, and commands with cost 0 are
+// free.
+//
+// GroupTotal and GlobalTotal, when set, are budgets shared by everyone in a
+// group chat and by everyone using the bot; requests count against them in
+// addition to the sender's own limit.
+type Policies struct {
+	Default     Limit
+	Ranks       map[string]Limit
+	Groups      map[string]Limit
+	Costs       map[string]int
+	ExemptRanks []string
+	GroupTotal  *Limit
+	GlobalTotal *Limit
+}
+
+// Resolve returns the limit and cost for command run by a user of rank in
+// group (both may be empty). exempt is true when the request is not limited.
+func (p *Policies) Resolve(rank, group, command string) (Limit, int, bool) {
+	if rank != "" && slices.Contains(p.ExemptRanks, rank) {
+		return Limit{}, 0, true
+	}
+
+	cost, ok := p.Costs[command]
+	if !ok {
+		cost = DefaultCost
+	}
+
+	if cost == 0 {
+		return Limit{}, 0, true
+	}
+
+	if limit, ok := p.Ranks[rank]; ok && rank != "" {
+		return limit, cost, false
+	}
+
+	if limit, ok := p.Groups[group]; ok && group != "" {
+		return limit, cost, false
+	}
+
+	return p.Default, cost, false
+}
+
+// Validate checks that every limit is positive and that no command costs
+// more than the smallest budget, or it could never run.
+func (p *Policies) Validate() error {
+	smallest := p.Default
+
+	totals := make(map[string]Limit)
+	if p.GroupTotal != nil {
+		totals["group total"] = *p.GroupTotal
+	}
+
+	if p.GlobalTotal != nil {
+		totals["global total"] = *p.GlobalTotal
+	}
+
+	for _, limits := range []map[string]Limit{p.Ranks, p.Groups, totals} {
+		for key, limit := range limits {
+			if limit.Requests <= 0 || limit.Period <= 0 {
+				return fmt.Errorf("%w: %q for %q", ErrInvalidLimit, limit, key)
+			}
+
+			if limit.Requests < smallest.Requests {
+				smallest = limit
+			}
+		}
+	}
+
+	for command, cost := range p.Costs {
+		if cost < 0 || cost > smallest.Requests {
+			return fmt.Errorf("%w: cost %d for %q must be between 0 and %d (the smallest budget, %s)",
+				ErrInvalidLimit, cost, command, smallest.Requests, smallest)
+		}
+	}
+
+	return nil
+}
@@ -16,39 +16,81 @@ var (
 	ErrServiceNotRunning = errors.New("rate limit service not running")
 )
 
+// globalKey is the key the bot-wide budget is kept under.
+var globalKey = types.NewJID("global", "ratelimit")
+
+// RateLimitError reports a request that did not fit. Scope says whose budget
+// ran out: the sender's, their group's or the whole bot's. Strikes counts the
+// sender's recent offenses when penalties are on, and Banned is set when this
+// one earned them a ban.
 type RateLimitError struct {
 	User       types.JID
+	Scope      Scope
 	ResetAfter time.Duration
 	Notify     bool
+	Strikes    int
+	Banned     bool
 }
 
 func (e *RateLimitError) Error() string {
-	return fmt.Sprintf("rate limit exceeded for %s (reset in %v)", e.User, e.ResetAfter)
+	return fmt.Sprintf("%s rate limit exceeded for %s (reset in %v)", e.Scope, e.User, e.ResetAfter)
 }
 
 type RateLimitService struct {
 	limiter  *Limiter
 	notifier *Notifier
 	cleaner  *AutoCleaner
+	policies *Policies
 	logger   *logger.Logger
 	running  bool
+
+	// penalties, when set, escalate the wait for repeat offenders and ban
+	// them through banner, as banActor.
+	penalties *Penalties
+	banner    Banner
+	banActor  string
+
+	identities Identities
+}
+
+// Identities maps a sender to the ID their own budget, notices and strikes
+// are kept under, so a person is one sender whichever device or address
+// (phone number or LID) they write from. The auth service implements it.
+type Identities interface {
+	CanonicalID(ctx context.Context, userID string) string
 }
 
 func NewRateLimitService(
 	limiter *Limiter,
 	notifier *Notifier,
 	cleaner *AutoCleaner,
+	policies *Policies,
 	log *logger.Logger,
 ) *RateLimitService {
 	return &RateLimitService{
 		limiter:  limiter,
 		notifier: notifier,
 		cleaner:  cleaner,
+		policies: policies,
 		logger:   log,
 		running:  false,
 	}
 }
 
+// SetPenalties turns on escalating penalties. It must be called before
+// Start.
+func (s *RateLimitService) SetPenalties(penalties *Penalties, banner Banner, banActor string) {
+	s.penalties = penalties
+	s.banner = banner
+	s.banActor = banActor
+}
+
+// SetIdentities maps senders to one identity per person. Without it, senders
+// are only stripped of their device part.
+func (s *RateLimitService) SetIdentities(identities Identities) {
+	s.identities = identities
+}
+
 func (s *RateLimitService) Start() error {
 	if s.running {
 		return nil
@@ -56,6 +98,11 @@ func (s *RateLimitService) Start() error {
 
 	s.cleaner.Register(s.limiter)
 	s.cleaner.Register(s.notifier)
+
+	if s.penalties != nil {
+		s.cleaner.Register(s.penalties)
+	}
+
 	s.running = true
 
 	return nil
@@ -70,28 +117,152 @@ func (s *RateLimitService) Stop() {
 	s.running = false
 }
 
-func (s *RateLimitService) Check(ctx context.Context, msg *message.Message) error {
+// Check charges the sender for running command, under the policy for their
+// rank (as resolved by the permission check, "" when unregistered) and the
+// chat the message came from, and charges the group and bot-wide budgets
+// when they are set. The request only counts when it fits all of them.
+// Budgets, notices and strikes are kept per person (see Identities).
+func (s *RateLimitService) Check(ctx context.Context, msg *message.Message, command, userRank string) error {
 	if !s.running {
 		return ErrServiceNotRunning
 	}
 
-	result := s.limiter.Check(msg.Sender)
+	group := ""
+	if msg.IsGroup {
+		group = msg.GroupID.String()
+	}
+
+	limit, cost, exempt := s.policies.Resolve(userRank, group, command)
+	if exempt {
+		return nil
+	}
+
+	sender := s.userKey(ctx, msg.Sender)
+
+	if s.penalties != nil {
+		blocked := s.penalties.Blocked(sender)
+		if blocked > 0 {
+			return &RateLimitError{
+				User:       msg.Sender,
+				Scope:      ScopeUser,
+				ResetAfter: blocked,
+				Notify:     s.notifier.ShouldNotify(sender),
+			}
+		}
+	}
+
+	result, scope := s.limiter.CheckAll(s.charges(msg, sender, limit, cost))
 	if result.Allowed {
-		s.notifier.Clear(msg.Sender)
+		s.notifier.Clear(sender)
 
 		return nil
 	}
 
-	shouldNotify := s.notifier.ShouldNotify(msg.Sender)
+	rateErr := &RateLimitError{
+		User:       msg.Sender,
+		Scope:      scope,
+		ResetAfter: result.ResetAfter,
+	}
+
+	// only the sender's own limit counts against them; a busy group or bot
+	// is not their fault.
+	if scope == ScopeUser && s.penalties != nil {
+		s.penalize(ctx, sender, rateErr)
+	}
+
+	rateErr.Notify = s.notifier.ShouldNotify(notifyKey(msg, sender, scope)) || rateErr.Banned
 	s.logger.Warn("Rate limit exceeded", map[string]interface{}{
 		"sender":     msg.Sender,
-		"resetAfter": result.ResetAfter,
-		"notify":     shouldNotify,
+		"scope":      scope,
+		"command":    command,
+		"rank":       userRank,
+		"limit":      limit.String(),
+		"cost":       cost,
+		"resetAfter": rateErr.ResetAfter,
+		"strikes":    rateErr.Strikes,
+		"banned":     rateErr.Banned,
+		"notify":     rateErr.Notify,
 	})
 
-	return &RateLimitError{
-		User:       msg.Sender,
-		ResetAfter: result.ResetAfter,
-		Notify:     shouldNotify,
+	return rateErr
+}
+
+// penalize gives the sender a strike and applies what it earned to rateErr,
+// unless the banner spares them. A ban that fails is logged and the sender is
+// blocked for the ban duration instead. Exempt ranks never get here.
+func (s *RateLimitService) penalize(ctx context.Context, user types.JID, rateErr *RateLimitError) {
+	if !s.banner.Penalizable(ctx, user.String()) {
+		return
+	}
+
+	penalty := s.penalties.Strike(user, rateErr.ResetAfter)
+	rateErr.Strikes = penalty.Strikes
+	rateErr.ResetAfter = penalty.BlockedFor
+
+	if !penalty.Ban {
+		return
+	}
+
+	expiresAt := time.Now().Add(penalty.BlockedFor)
+	reason := fmt.Sprintf("rate limit: %d strikes", penalty.Strikes)
+
+	err := s.banner.BanUser(ctx, user.String(), reason, &expiresAt, s.banActor)
+	if err != nil {
+		s.logger.Error("Failed to ban repeat rate limit offender", map[string]interface{}{
+			"sender":  user,
+			"strikes": penalty.Strikes,
+			"error":   err.Error(),
+		})
+		s.penalties.Block(user, penalty.BlockedFor)
+
+		return
+	}
+
+	s.logger.Warn("Banned repeat rate limit offender", map[string]interface{}{
+		"sender":    user,
+		"strikes":   penalty.Strikes,
+		"expiresAt": expiresAt,
+	})
+
+	rateErr.Banned = true
+}
+
+// userKey returns the key of sender's own budget, notices and strikes.
+func (s *RateLimitService) userKey(ctx context.Context, sender types.JID) types.JID {
+	sender = sender.ToNonAD()
+	if s.identities == nil {
+		return sender
+	}
+
+	canonical, err := types.ParseJID(s.identities.CanonicalID(ctx, sender.String()))
+	if err != nil {
+		return sender
+	}
+
+	return canonical
+}
+
+// charges lists the budgets a request is counted against, the sender's first.
+func (s *RateLimitService) charges(msg *message.Message, sender types.JID, limit Limit, cost int) []Charge {
+	charges := []Charge{{Scope: ScopeUser, Key: sender, Limit: limit, Cost: cost}}
+
+	if msg.IsGroup && s.policies.GroupTotal != nil {
+		charges = append(charges, Charge{Scope: ScopeGroup, Key: msg.GroupID, Limit: *s.policies.GroupTotal, Cost: cost})
 	}
+
+	if s.policies.GlobalTotal != nil {
+		charges = append(charges, Charge{Scope: ScopeGlobal, Key: globalKey, Limit: *s.policies.GlobalTotal, Cost: cost})
+	}
+
+	return charges
+}
+
+// notifyKey spaces out notices per sender for their own limit, and per chat
+// when a shared budget ran out, so a busy group hears about it once.
+func notifyKey(msg *message.Message, sender types.JID, scope Scope) types.JID {
+	if scope == ScopeUser {
+		return sender
+	}
+
+	return msg.Recipient
 }
@@ -0,0 +1,153 @@
+package ratelimit
+
+import (
+	"sync"
+	"time"
+
+	"go.mau.fi/whatsmeow/types"
+)
+
+// request is one allowed request and how much of the budget it used.
+type request struct {
+	At   time.Time `json:"at"`
+	Cost int       `json:"cost"`
+}
+
+// SlidingLog remembers every allowed request and counts those inside the
+// window. It is exact, but each key holds up to Requests entries and every
+// check scans them.
+type SlidingLog struct {
+	mu       sync.Mutex
+	requests map[types.JID][]request
+	// retention is the longest period any check has used; requests are
+	// remembered that long so every limit sees them.
+	retention time.Duration
+}
+
+func NewSlidingLog() *SlidingLog {
+	return &SlidingLog{
+		mu:       sync.Mutex{},
+		requests: make(map[types.JID][]request),
+	}
+}
+
+func (s *SlidingLog) Check(key types.JID, limit Limit, cost int) Result {
+	return s.check(key, limit, cost, true)
+}
+
+func (s *SlidingLog) Peek(key types.JID, limit Limit, cost int) Result {
+	return s.check(key, limit, cost, false)
+}
+
+func (s *SlidingLog) check(key types.JID, limit Limit, cost int, charge bool) Result {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.retention = max(s.retention, limit.Period)
+
+	now := time.Now()
+
+	// Keep what the longest limit still needs, and sum up this limit's window
+	var (
+		kept   []request
+		window []request
+		used   int
+	)
+
+	for _, r := range s.requests[key] {
+		age := now.Sub(r.At)
+		if age <= s.retention {
+			kept = append(kept, r)
+		}
+
+		if age <= limit.Period {
+			window = append(window, r)
+			used += r.Cost
+		}
+	}
+
+	allowed := used+cost <= limit.Requests
+	if allowed && charge {
+		kept = append(kept, request{At: now, Cost: cost})
+	}
+
+	if len(kept) == 0 {
+		delete(s.requests, key)
+	} else {
+		s.requests[key] = kept
+	}
+
+	return Result{
+		Allowed:    allowed,
+		ResetAfter: windowResetAfter(window, used, cost, limit, now, allowed),
+	}
+}
+
+// windowResetAfter is how long until enough of the window has left it for a
+// denied request to fit. An allowed request is itself in the window for a
+// whole period.
+func windowResetAfter(window []request, used, cost int, limit Limit, now time.Time, allowed bool) time.Duration {
+	if allowed {
+		return limit.Period
+	}
+
+	freed := 0
+
+	for _, r := range window {
+		freed += r.Cost
+		if used-freed+cost <= limit.Requests {
+			return max(limit.Period-now.Sub(r.At), 0)
+		}
+	}
+
+	return limit.Period
+}
+
+func (s *SlidingLog) Cleanup() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// until the first check, restored requests may belong to any limit.
+	if s.retention == 0 {
+		return
+	}
+
+	now := time.Now()
+	for key, requests := range s.requests {
+		var valid []request
+
+		for _, r := range requests {
+			if now.Sub(r.At) <= s.retention {
+				valid = append(valid, r)
+			}
+		}
+
+		if len(valid) == 0 {
+			delete(s.requests, key)
+		} else {
+			s.requests[key] = valid
+		}
+	}
+}
+
+func (s *SlidingLog) Snapshot() (map[string][]byte, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+
+	return snapshotEntries(s.requests, func(requests []request) bool {
+		return s.retention == 0 || now.Sub(requests[len(requests)-1].At) <= s.retention
+	})
+}
+
+func (s *SlidingLog) Restore(entries map[string][]byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return restoreEntries(entries, func(key types.JID, requests []request) {
+		if len(requests) > 0 {
+			s.requests[key] = requests
+		}
+	})
+}
@@ -0,0 +1,129 @@
+package ratelimit
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"maps"
+	"sync"
+)
+
+// Store keeps rate limiting state across restarts. State is saved per
+// component ("limiter:gcra", "notifier", ...) as one opaque entry per key;
+// Save replaces everything the component had stored.
+type Store interface {
+	Load(ctx context.Context, component string) (map[string][]byte, error)
+	Save(ctx context.Context, component string, entries map[string][]byte) error
+}
+
+// MemoryStore keeps state for the lifetime of the process only.
+type MemoryStore struct {
+	mu         sync.Mutex
+	components map[string]map[string][]byte
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		mu:         sync.Mutex{},
+		components: make(map[string]map[string][]byte),
+	}
+}
+
+func (m *MemoryStore) Load(_ context.Context, component string) (map[string][]byte, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return maps.Clone(m.components[component]), nil
+}
+
+func (m *MemoryStore) Save(_ context.Context, component string, entries map[string][]byte) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.components[component] = maps.Clone(entries)
+
+	return nil
+}
+
+// SQLiteStore keeps state in the rate_limit_state table of the bot's
+// database, which the auth migrations create.
+type SQLiteStore struct {
+	db *sql.DB
+}
+
+func NewSQLiteStore(db *sql.DB) *SQLiteStore {
+	return &SQLiteStore{db: db}
+}
+
+func (s *SQLiteStore) Load(ctx context.Context, component string) (entries map[string][]byte, err error) {
+	rows, err := s.db.QueryContext(ctx, `SELECT key, state FROM rate_limit_state WHERE component = ?`, component)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load rate limit state: %w", err)
+	}
+
+	defer func() {
+		cerr := rows.Close()
+		if cerr != nil && err == nil {
+			err = fmt.Errorf("failed to close rows: %w", cerr)
+		}
+	}()
+
+	entries = make(map[string][]byte)
+
+	for rows.Next() {
+		var (
+			key   string
+			state []byte
+		)
+
+		scanErr := rows.Scan(&key, &state)
+		if scanErr != nil {
+			return nil, fmt.Errorf("failed to scan rate limit state: %w", scanErr)
+		}
+
+		entries[key] = state
+	}
+
+	err = rows.Err()
+	if err != nil {
+		return nil, fmt.Errorf("failed to iterate rate limit state: %w", err)
+	}
+
+	return entries, nil
+}
+
+func (s *SQLiteStore) Save(ctx context.Context, component string, entries map[string][]byte) (err error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin saving rate limit state: %w", err)
+	}
+
+	defer func() {
+		if err != nil {
+			rerr := tx.Rollback()
+			if rerr != nil {
+				err = fmt.Errorf("%w (rollback: %w)", err, rerr)
+			}
+		}
+	}()
+
+	_, err = tx.ExecContext(ctx, `DELETE FROM rate_limit_state WHERE component = ?`, component)
+	if err != nil {
+		return fmt.Errorf("failed to clear rate limit state: %w", err)
+	}
+
+	for key, state := range entries {
+		_, err = tx.ExecContext(ctx, `INSERT INTO rate_limit_state (component, key, state) VALUES (?, ?, ?)`,
+			component, key, string(state))
+		if err != nil {
+			return fmt.Errorf("failed to save rate limit state: %w", err)
+		}
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return fmt.Errorf("failed to commit rate limit state: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,110 @@
+package ratelimit
+
+import (
+	"sync"
+	"time"
+
+	"go.mau.fi/whatsmeow/types"
+)
+
+type bucket struct {
+	Tokens  float64   `json:"tokens"`
+	Updated time.Time `json:"updated"`
+	// Full is when the bucket will have refilled, after which it can be
+	// dropped: a missing bucket is a full one.
+	Full time.Time `json:"full"`
+}
+
+// TokenBucket gives every key a bucket of Requests tokens that refills at
+// Requests per Period. Bursts up to the whole budget are allowed.
+type TokenBucket struct {
+	mu      sync.Mutex
+	buckets map[types.JID]*bucket
+}
+
+func NewTokenBucket() *TokenBucket {
+	return &TokenBucket{
+		mu:      sync.Mutex{},
+		buckets: make(map[types.JID]*bucket),
+	}
+}
+
+func (t *TokenBucket) Check(key types.JID, limit Limit, cost int) Result {
+	return t.check(key, limit, cost, true)
+}
+
+func (t *TokenBucket) Peek(key types.JID, limit Limit, cost int) Result {
+	return t.check(key, limit, cost, false)
+}
+
+func (t *TokenBucket) check(key types.JID, limit Limit, cost int, charge bool) Result {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	now := time.Now()
+	capacity := float64(limit.Requests)
+	perToken := limit.Period / time.Duration(limit.Requests)
+
+	// work on a copy so a peek leaves the bucket alone.
+	b := bucket{Tokens: capacity, Updated: now}
+	if stored, ok := t.buckets[key]; ok {
+		b = *stored
+	}
+
+	refilled := float64(now.Sub(b.Updated)) / float64(perToken)
+	b.Tokens = min(b.Tokens+refilled, capacity)
+	b.Updated = now
+
+	allowed := b.Tokens >= float64(cost)
+	if !allowed {
+		return Result{Allowed: false, ResetAfter: tokensDuration(float64(cost)-b.Tokens, perToken)}
+	}
+
+	b.Tokens -= float64(cost)
+	b.Full = now.Add(tokensDuration(capacity-b.Tokens, perToken))
+
+	if charge {
+		t.buckets[key] = &b
+	}
+
+	return Result{Allowed: true, ResetAfter: b.Full.Sub(now)}
+}
+
+// tokensDuration is how long it takes to refill n tokens.
+func tokensDuration(n float64, perToken time.Duration) time.Duration {
+	return time.Duration(n * float64(perToken))
+}
+
+func (t *TokenBucket) Cleanup() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	now := time.Now()
+	for key, b := range t.buckets {
+		if !now.Before(b.Full) {
+			delete(t.buckets, key)
+		}
+	}
+}
+
+func (t *TokenBucket) Snapshot() (map[string][]byte, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	now := time.Now()
+
+	return snapshotEntries(t.buckets, func(b *bucket) bool {
+		return now.Before(b.Full)
+	})
+}
+
+func (t *TokenBucket) Restore(entries map[string][]byte) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	return restoreEntries(entries, func(key types.JID, b *bucket) {
+		if b != nil {
+			t.buckets[key] = b
+		}
+	})
+}
@@ -0,0 +1,35 @@
+package timing
+
+import (
+	"maps"
+	"sync"
+)
+
+// Counters is a set of named, monotonically increasing counters such as
+// cache hits and misses. It is safe for concurrent use.
+type Counters struct {
+	mu     sync.Mutex
+	values map[string]uint64
+}
+
+func NewCounters() *Counters {
+	return &Counters{
+		values: make(map[string]uint64),
+	}
+}
+
+// Inc adds one to the named counter, creating it on first use.
+func (c *Counters) Inc(name string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.values[name]++
+}
+
+// Snapshot returns a copy of every counter's current value.
+func (c *Counters) Snapshot() map[string]uint64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return maps.Clone(c.values)
+}
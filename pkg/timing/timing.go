@@ -26,18 +26,50 @@ type Config struct {
 }
 
 type Tracker struct {
-	config Config
-	logger *logger.Logger
+	config   Config
+	logger   *logger.Logger
+	counters *Counters
 }
 
 // NewTracker creates a new performance tracker with the given configuration.
 func NewTracker(config Config, log *logger.Logger) *Tracker {
 	return &Tracker{
-		config: config,
-		logger: log,
+		config:   config,
+		logger:   log,
+		counters: NewCounters(),
 	}
 }
 
+// IncCounter increments a named counter. Counters are kept whatever the
+// tracking level, so they can be read at any time through Counters.
+func (t *Tracker) IncCounter(name string) {
+	t.counters.Inc(name)
+}
+
+// Counters returns the current value of every counter.
+func (t *Tracker) Counters() map[string]uint64 {
+	return t.counters.Snapshot()
+}
+
+// LogCounters writes all counters to the log unless tracking is disabled.
+func (t *Tracker) LogCounters() {
+	if t.config.Level == Disabled {
+		return
+	}
+
+	values := t.counters.Snapshot()
+	if len(values) == 0 {
+		return
+	}
+
+	fields := make(map[string]interface{}, len(values))
+	for name, value := range values {
+		fields[name] = value
+	}
+
+	t.logger.Info("Performance counters", fields)
+}
+
 // Track executes the given function and records its execution time if tracking is enabled.
 func (t *Tracker) Track(ctx context.Context, operation string, level Level, operationFunc func(context.Context) error) error {
 	if t.config.Level == Disabled || (t.config.Level < level && t.config.Level != Debug) {
@@ -0,0 +1,63 @@
+package util
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var ErrInvalidDuration = errors.New("invalid duration")
+
+// ParseDuration extends time.ParseDuration with day ("7d") and week ("2w")
+// units, which are what people actually type for bans and invites.
+func ParseDuration(raw string) (time.Duration, error) {
+	raw = strings.ToLower(strings.TrimSpace(raw))
+	if raw == "" {
+		return 0, ErrInvalidDuration
+	}
+
+	units := map[byte]time.Duration{
+		'd': 24 * time.Hour,
+		'w': 7 * 24 * time.Hour,
+	}
+
+	if unit, ok := units[raw[len(raw)-1]]; ok {
+		count, err := strconv.Atoi(raw[:len(raw)-1])
+		if err != nil || count <= 0 {
+			return 0, fmt.Errorf("%w: %q", ErrInvalidDuration, raw)
+		}
+
+		return time.Duration(count) * unit, nil
+	}
+
+	duration, err := time.ParseDuration(raw)
+	if err != nil || duration <= 0 {
+		return 0, fmt.Errorf("%w: %q", ErrInvalidDuration, raw)
+	}
+
+	return duration, nil
+}
+
+// ParseExpiry turns a duration from now ("3d", "12h") into an expiry time.
+func ParseExpiry(raw string) (*time.Time, error) {
+	duration, err := ParseDuration(raw)
+	if err != nil {
+		return nil, err
+	}
+
+	expiresAt := time.Now().Add(duration)
+
+	return &expiresAt, nil
+}
+
+// UntilText describes an optional expiry for replies: " until <time>", or
+// nothing when there is none.
+func UntilText(expiresAt *time.Time) string {
+	if expiresAt == nil {
+		return ""
+	}
+
+	return " until " + expiresAt.Format(time.DateTime)
+}
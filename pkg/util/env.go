@@ -64,6 +64,21 @@ func GetEnvDuration(key string, defaultValue time.Duration) time.Duration {
 	return defaultValue
 }
 
+// GetEnvList splits a comma-separated variable, dropping empty items. An
+// unset variable reads as defaultValue; a variable set to "" as no items.
+func GetEnvList(key, defaultValue string) []string {
+	var values []string
+
+	for _, item := range strings.Split(GetEnv(key, defaultValue), ",") {
+		item = strings.TrimSpace(item)
+		if item != "" {
+			values = append(values, item)
+		}
+	}
+
+	return values
+}
+
 func GetEnvBool(key string, defaultValue bool) bool {
 	str := GetEnv(key, "")
 	if str == "" {
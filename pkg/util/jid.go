@@ -0,0 +1,61 @@
+package util
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"go.mau.fi/whatsmeow/types"
+)
+
+// MinPhoneDigits and MaxPhoneDigits bound the phone numbers ParseUserJID
+// accepts.
+const (
+	MinPhoneDigits = 6
+	MaxPhoneDigits = 15
+)
+
+var ErrInvalidUserID = errors.New("invalid user ID")
+
+// ParseUserJID accepts either a full JID or a phone number in any common
+// format ("+51 999-888-777", "51999888777") and returns the user without
+// its device part, so the same person always maps to the same users.user_id
+// row. Group JIDs are rejected.
+func ParseUserJID(raw string) (types.JID, error) {
+	raw = strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(raw), "@"))
+	if raw == "" {
+		return types.EmptyJID, ErrInvalidUserID
+	}
+
+	if strings.Contains(raw, "@") {
+		jid, err := types.ParseJID(raw)
+		if err != nil {
+			return types.EmptyJID, fmt.Errorf("%w: %w", ErrInvalidUserID, err)
+		}
+
+		if jid.User == "" || jid.Server == types.GroupServer {
+			return types.EmptyJID, fmt.Errorf("%w: %q", ErrInvalidUserID, raw)
+		}
+
+		return jid.ToNonAD(), nil
+	}
+
+	digits := make([]rune, 0, len(raw))
+
+	for _, r := range raw {
+		switch {
+		case r >= '0' && r <= '9':
+			digits = append(digits, r)
+		case r == '+' || r == '-' || r == ' ' || r == '(' || r == ')':
+			continue
+		default:
+			return types.EmptyJID, fmt.Errorf("%w: %q", ErrInvalidUserID, raw)
+		}
+	}
+
+	if len(digits) < MinPhoneDigits || len(digits) > MaxPhoneDigits {
+		return types.EmptyJID, fmt.Errorf("%w: %q", ErrInvalidUserID, raw)
+	}
+
+	return types.NewJID(string(digits), types.DefaultUserServer), nil
+}
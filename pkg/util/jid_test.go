@@ -0,0 +1,54 @@
+package util
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestParseUserJID(t *testing.T) {
+	tests := []struct {
+		raw  string
+		want string
+	}{
+		{"51999888777", "51999888777@s.whatsapp.net"},
+		{"+51 999-888-777", "51999888777@s.whatsapp.net"},
+		{"(51) 999 888 777", "51999888777@s.whatsapp.net"},
+		{"@51999888777", "51999888777@s.whatsapp.net"},
+		{" 51999888777@s.whatsapp.net ", "51999888777@s.whatsapp.net"},
+		{"51999888777:12@s.whatsapp.net", "51999888777@s.whatsapp.net"},
+		{"123456789012345@lid", "123456789012345@lid"},
+		{"123456", "123456@s.whatsapp.net"},
+	}
+
+	for _, tt := range tests {
+		jid, err := ParseUserJID(tt.raw)
+		if err != nil {
+			t.Errorf("ParseUserJID(%q): %v", tt.raw, err)
+
+			continue
+		}
+
+		if got := jid.String(); got != tt.want {
+			t.Errorf("ParseUserJID(%q) = %s, want %s", tt.raw, got, tt.want)
+		}
+	}
+}
+
+func TestParseUserJIDRejects(t *testing.T) {
+	for _, raw := range []string{
+		"",
+		"@",
+		"1",
+		"12345",
+		"1234567890123456",
+		"51999abc777",
+		"120363012345678901@g.us",
+		"@s.whatsapp.net",
+		"1.2.3@s.whatsapp.net",
+	} {
+		_, err := ParseUserJID(raw)
+		if !errors.Is(err, ErrInvalidUserID) {
+			t.Errorf("ParseUserJID(%q) = %v, want ErrInvalidUserID", raw, err)
+		}
+	}
+}